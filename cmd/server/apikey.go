@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/5ucr4m/go-socket/internal/api"
+)
+
+// runAPIKey gera uma nova chave de API e a entrada correspondente de API_KEYS
+//
+//	server apikey <nome> <escopos>   (ex.: server apikey orders "publish:order:*|send:*|read:order:*")
+func runAPIKey(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "uso: server apikey <nome> <escopo1|escopo2>")
+		os.Exit(2)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		log.Fatalf("❌ Erro ao gerar chave: %v", err)
+	}
+	key := "gsk_" + hex.EncodeToString(raw)
+
+	entry := fmt.Sprintf("%s:%s:%s", args[0], api.HashAPIKey(key), args[1])
+	if _, err := api.ParseAPIKeys([]string{entry}); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	fmt.Printf("Chave (guarde agora, não é armazenada): %s\n", key)
+	fmt.Printf("Entrada para API_KEYS: %s\n", entry)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,7 +10,11 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/5ucr4m/go-socket/internal/api"
+	"github.com/5ucr4m/go-socket/internal/auth"
+	"github.com/5ucr4m/go-socket/internal/backplane"
 	"github.com/5ucr4m/go-socket/internal/config"
+	"github.com/5ucr4m/go-socket/internal/persistence"
 	"github.com/5ucr4m/go-socket/internal/pubsub"
 	"github.com/gorilla/websocket"
 )
@@ -16,14 +22,52 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// Permitir todas as origens (ajustar em produção para domínios específicos)
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	// CheckOrigin é definido em main a partir de ALLOWED_ORIGINS
+	// Token enviado como new WebSocket(url, ["bearer", token])
+	Subprotocols: []string{auth.BearerProtocol},
+}
+
+// reauthenticator adapta o Authenticator ao evento reauth
+type reauthenticator struct {
+	authenticator auth.Authenticator
+}
+
+// Reauthenticate verifica o token novo enviado pelo cliente
+func (r reauthenticator) Reauthenticate(token string) (pubsub.Credential, error) {
+	session, err := r.authenticator.Refresh(token)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
 // serveWs faz o upgrade da conexão HTTP para WebSocket
-func serveWs(hub *pubsub.Hub, w http.ResponseWriter, r *http.Request) {
+// Com authenticator configurado, a credencial é obrigatória e define a identidade do cliente
+func serveWs(hub *pubsub.Hub, origins *auth.OriginPolicy, authenticator auth.Authenticator, ipHeader string, w http.ResponseWriter, r *http.Request) {
+	// Origem verificada antes da autenticação: páginas de terceiros não usam
+	// os cookies dos nossos usuários nem chegam ao serviço de autenticação
+	if !origins.Allow(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	var session *auth.Session
+	if authenticator != nil {
+		var err error
+		session, err = authenticator.Authenticate(r)
+		if err != nil {
+			var rejected *auth.RejectedError
+			if errors.As(err, &rejected) {
+				log.Printf("Upgrade recusado para %s: %v", r.RemoteAddr, err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			log.Printf("Erro ao autenticar %s: %v", r.RemoteAddr, err)
+			http.Error(w, "Authentication unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Erro ao fazer upgrade: %v", err)
@@ -32,6 +76,10 @@ func serveWs(hub *pubsub.Hub, w http.ResponseWriter, r *http.Request) {
 
 	// Cria novo cliente usando o construtor
 	client := pubsub.NewClient(hub, conn)
+	client.SetRemoteIP(auth.ClientIP(r, ipHeader))
+	if session != nil {
+		client.SetCredential(session)
+	}
 
 	// Registra o novo cliente no hub
 	hub.Register(client)
@@ -43,6 +91,12 @@ func serveWs(hub *pubsub.Hub, w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	// Geração de chaves de API (server apikey ...)
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		runAPIKey(os.Args[2:])
+		return
+	}
+
 	log.Println("🚀 Go-Socket Server iniciando...")
 
 	// Carrega configurações
@@ -51,53 +105,188 @@ func main() {
 	log.Printf("📋 Configurações:")
 	log.Printf("   - Instance ID: %s", cfg.InstanceID)
 	log.Printf("   - Server Port: %s", cfg.ServerPort)
-	log.Printf("   - Redis URL: %s", cfg.RedisURL)
+	log.Printf("   - Backplane: %s", cfg.Backplane)
+	log.Printf("   - Redis URL: %s", cfg.RedisDisplayURL())
 
 	// Cria e inicia o Hub com Redis
 	var hub *pubsub.Hub
 	var err error
 
-	if cfg.RedisURL != "" {
+	switch cfg.Backplane {
+	case "postgres":
+		log.Println("🐘 Inicializando Hub com PostgreSQL LISTEN/NOTIFY...")
+		bp, err := backplane.NewPostgres(cfg.PostgresURL, cfg.InstanceID)
+		if err != nil {
+			log.Fatalf("❌ Erro ao criar backplane PostgreSQL: %v", err)
+		}
+		hub, err = pubsub.NewHubWithBackplane(bp, cfg.InstanceID)
+		if err != nil {
+			log.Fatalf("❌ Erro ao criar Hub com PostgreSQL: %v", err)
+		}
+		defer hub.Close()
+
+	case "memory":
+		log.Println("🧠 Inicializando Hub com backplane em memória (nó único)...")
+		hub, err = pubsub.NewHubWithBackplane(backplane.NewMemory(nil, cfg.InstanceID), cfg.InstanceID)
+		if err != nil {
+			log.Fatalf("❌ Erro ao criar Hub em memória: %v", err)
+		}
+		defer hub.Close()
+
+	case "redis":
+		if !cfg.RedisEnabled() {
+			log.Println("⚠️  Redis não configurado, usando Hub local (sem escalabilidade)")
+			hub = pubsub.NewHub()
+			break
+		}
 		log.Println("📡 Inicializando Hub com Redis...")
-		hub, err = pubsub.NewHubWithRedis(cfg.RedisURL, cfg.InstanceID)
+		hub, err = pubsub.NewHubWithRedis(cfg.Redis(), cfg.InstanceID, cfg.StreamRetention())
 		if err != nil {
 			log.Fatalf("❌ Erro ao criar Hub com Redis: %v", err)
 		}
 		defer hub.Close()
+
+	default:
+		log.Fatalf("❌ BACKPLANE desconhecido: %q (use redis, postgres ou memory)", cfg.Backplane)
+	}
+
+	// Política de edição de mensagens
+	hub.RoomManager().SetEditPolicy(pubsub.EditPolicy{
+		Window:         cfg.EditWindow,
+		ModeratorRoles: cfg.ModeratorRoles,
+	})
+
+	// Política de acesso às salas (subscribe, publish, presence, typing, edit)
+	if cfg.RoomPolicyFile != "" {
+		policy, err := pubsub.LoadRoomPolicy(cfg.RoomPolicyFile)
+		if err != nil {
+			log.Fatalf("❌ Erro ao carregar política de salas: %v", err)
+		}
+		hub.RoomManager().SetRoomPolicy(policy)
+		log.Printf("🛡️  Política de salas carregada: %d regra(s), padrão %s", len(policy.Rules), policy.Default)
+	}
+
+	// Mensagens persistidas (revisões, histórico e busca) direto do PostgreSQL
+	// Sem PostgreSQL o servidor continua funcionando apenas com a memória
+	repo, err := persistence.NewMessageRepository(cfg.PostgresURL)
+	if err != nil {
+		log.Printf("⚠️  PostgreSQL indisponível, consultas limitadas à memória: %v", err)
 	} else {
-		log.Println("⚠️  Redis não configurado, usando Hub local (sem escalabilidade)")
-		hub = pubsub.NewHub()
+		defer repo.Close()
+		if err := repo.SetSearchLanguage(cfg.SearchLanguage); err != nil {
+			log.Printf("⚠️  %v; usando configuração padrão", err)
+		}
+		hub.RoomManager().SetMessageArchive(repo)
+	}
+
+	// Autenticação do WebSocket: callback HTTP, JWT ou, sem nenhum dos dois,
+	// identidade informada pelo cliente
+	var authenticator auth.Authenticator
+	verifier, err := auth.NewVerifier(cfg.JWT())
+	if err != nil {
+		log.Fatalf("❌ Erro ao carregar chaves JWT: %v", err)
+	}
+	switch {
+	case cfg.AuthCallbackURL != "":
+		authenticator = auth.NewCallbackAuthenticator(cfg.AuthCallback())
+		log.Printf("🔐 Autenticação por callback habilitada no WebSocket (%s, cache %s)", cfg.AuthCallbackURL, cfg.AuthCallbackTTL)
+	case verifier != nil:
+		authenticator = auth.NewJWTAuthenticator(verifier, cfg.JWTCookie)
+		log.Println("🔐 Autenticação JWT habilitada no WebSocket")
+	default:
+		log.Println("⚠️  Autenticação não configurada, identidade informada pelo cliente")
+	}
+	if authenticator != nil {
+		hub.RoomManager().SetReauthenticator(reauthenticator{authenticator}, cfg.TokenExpiryWarning)
+	}
+
+	// Origens que podem abrir o WebSocket (vazio = apenas a mesma origem)
+	origins, err := auth.NewOriginPolicy(cfg.AllowedOrigins, cfg.OriginRequireHost)
+	if err != nil {
+		log.Fatalf("❌ Erro em ALLOWED_ORIGINS: %v", err)
+	}
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		allowed, _ := origins.Check(r)
+		return allowed
+	}
+	expvar.Publish("websocket_origin_rejections", expvar.Func(func() any {
+		return origins.Rejections()
+	}))
+	log.Printf("🌐 Origens permitidas: %v (exigir Host: %v)", cfg.AllowedOrigins, cfg.OriginRequireHost)
+
+	// Limites de taxa dos eventos (buckets no Redis quando disponível)
+	rateLimits, err := pubsub.ParseRateLimits(cfg.RateLimits)
+	if err != nil {
+		log.Fatalf("❌ Erro em RATE_LIMITS: %v", err)
+	}
+	hub.RoomManager().SetRateLimitPolicy(pubsub.RateLimitPolicy{
+		Limits:          rateLimits,
+		MaxViolations:   cfg.RateLimitMaxViolations,
+		ViolationWindow: cfg.RateLimitWindow,
+	})
+	if len(rateLimits) > 0 {
+		log.Printf("🚦 %d limite(s) de taxa, desconexão após %d recusa(s) em %v", len(rateLimits), cfg.RateLimitMaxViolations, cfg.RateLimitWindow)
 	}
 
 	go hub.Run()
 
-	// Configura rotas
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		serveWs(hub, w, r)
+	// Configura rotas (mux próprio: o DefaultServeMux expõe /debug/vars do expvar)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		serveWs(hub, origins, authenticator, cfg.ClientIPHeader, w, r)
 	})
 
+	// API HTTP (salas, histórico, presença, busca e publicação por serviços)
+	apiKeys, err := api.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		log.Fatalf("❌ Erro ao carregar API_KEYS: %v", err)
+	}
+	log.Printf("🔑 %d chave(s) de API carregada(s)", len(apiKeys))
+	api.NewServer(hub.RoomManager(), apiKeys, cfg.InstanceID).Register(mux)
+
 	// Rota de health check
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
+	// Em modo degradado (Redis fora) a instância continua atendendo clientes locais,
+	// mas responde 503 para o load balancer direcionar novas conexões às demais
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		health := hub.Health()
+		if health["status"] == "degraded" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(fmt.Sprintf("DEGRADED - Instance: %s (backplane: %v, buffered: %v)",
+				cfg.InstanceID, health["backplane"], health["bufferedMessages"])))
+			return
+		}
 		w.Write([]byte(fmt.Sprintf("OK - Instance: %s", cfg.InstanceID)))
 	})
 
 	// Servir arquivos estáticos (para o cliente React)
 	fs := http.FileServer(http.Dir("./examples/client/dist"))
-	http.Handle("/", fs)
+	mux.Handle("/", fs)
 
 	addr := ":" + cfg.ServerPort
 	log.Printf("✅ Servidor WebSocket pronto em http://localhost%s", addr)
 	log.Printf("📡 Endpoint WebSocket: ws://localhost%s/ws", addr)
 
 	// Inicia servidor em goroutine
-	server := &http.Server{Addr: addr}
+	server := &http.Server{Addr: addr, Handler: mux}
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("❌ Erro ao iniciar servidor: %v", err)
 		}
 	}()
 
+	// Métricas (expvar) apenas no listener interno
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/debug/vars", expvar.Handler())
+		metricsServer := &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("❌ Erro ao iniciar servidor de métricas: %v", err)
+			}
+		}()
+		log.Printf("📊 Métricas em http://%s/debug/vars", cfg.MetricsAddr)
+	}
+
 	// Aguarda sinal de interrupção
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/config"
+	"github.com/5ucr4m/go-socket/internal/redis"
+)
+
+// Quantidade padrão de entradas listadas ou devolvidas por execução
+const defaultDLQCount = 100
+
+// runDLQ executa os subcomandos de inspeção da DLQ
+//
+//	worker dlq list [quantidade]
+//	worker dlq redrive [all [quantidade] | id...]
+func runDLQ(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		dlqUsage()
+	}
+
+	redisClient, err := redis.NewClient(cfg.Redis())
+	if err != nil {
+		log.Fatalf("❌ Erro ao conectar no Redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "list":
+		letters, err := redis.ListDeadLetters(ctx, redisClient, parseDLQCount(args[1:]))
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+
+		log.Printf("📭 %d entradas em %s", len(letters), redis.DeadLetterStream)
+		for _, letter := range letters {
+			values, _ := json.Marshal(letter.Values)
+			fmt.Printf("%s\torigem=%s\ttentativas=%d\tworker=%s\tem=%s\terro=%q\n\t%s\n",
+				letter.ID, letter.SourceID, letter.Attempts, letter.Consumer,
+				letter.FailedAt.Format(time.RFC3339), letter.Error, values)
+		}
+
+	case "redrive":
+		if len(args) < 2 {
+			dlqUsage()
+		}
+
+		var redriven int
+		if args[1] == "all" {
+			redriven, err = redis.RedriveDeadLetters(ctx, redisClient, parseDLQCount(args[2:]))
+		} else {
+			redriven, err = redis.RedriveDeadLetters(ctx, redisClient, 0, args[1:]...)
+		}
+		if err != nil {
+			log.Printf("❌ %v", err)
+		}
+		log.Printf("🔁 %d entradas devolvidas para %s", redriven, redis.MessagesStream)
+
+	default:
+		dlqUsage()
+	}
+}
+
+// parseDLQCount lê a quantidade opcional de entradas
+func parseDLQCount(args []string) int64 {
+	if len(args) == 0 {
+		return defaultDLQCount
+	}
+	count, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || count <= 0 {
+		log.Fatalf("❌ Quantidade inválida: %s", args[0])
+	}
+	return count
+}
+
+// dlqUsage imprime o uso dos subcomandos e encerra
+func dlqUsage() {
+	fmt.Fprintln(os.Stderr, "uso: worker dlq list [quantidade]")
+	fmt.Fprintln(os.Stderr, "     worker dlq redrive all [quantidade]")
+	fmt.Fprintln(os.Stderr, "     worker dlq redrive <id>...")
+	os.Exit(2)
+}
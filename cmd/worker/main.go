@@ -17,11 +17,19 @@ func main() {
 	// Carrega configurações
 	cfg := config.Load()
 
+	// Subcomandos de manutenção da DLQ (worker dlq ...)
+	if len(os.Args) > 1 && os.Args[1] == "dlq" {
+		runDLQ(cfg, os.Args[2:])
+		return
+	}
+
 	log.Printf("📋 Configurações:")
 	log.Printf("   - Worker ID: %s", cfg.WorkerID)
-	log.Printf("   - Redis URL: %s", cfg.RedisURL)
+	log.Printf("   - Redis URL: %s", cfg.RedisDisplayURL())
 	log.Printf("   - Batch Size: %d", cfg.BatchSize)
 	log.Printf("   - Batch Timeout: %s", cfg.BatchTimeout)
+	log.Printf("   - Search Language: %s", cfg.SearchLanguage)
+	log.Printf("   - Claim: a cada %s (ocioso > %s, máx. %d entregas)", cfg.ClaimInterval, cfg.ClaimMinIdle, cfg.MaxDeliveries)
 
 	// Inicializa repository do PostgreSQL
 	log.Println("🗄️  Conectando ao PostgreSQL...")
@@ -31,13 +39,27 @@ func main() {
 	}
 	defer repo.Close()
 
-	// Inicializa consumer do Redis Streams
+	// Novas mensagens são indexadas para busca com esta configuração de idioma
+	if err := repo.SetSearchLanguage(cfg.SearchLanguage); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	// Conecta ao Redis (nó único, Sentinel ou Cluster)
 	log.Println("📡 Conectando ao Redis Streams...")
-	consumer, err := redis.NewStreamConsumer(redis.ConsumerConfig{
-		RedisURL:     cfg.RedisURL,
-		ConsumerID:   cfg.WorkerID,
-		BatchSize:    int64(cfg.BatchSize),
-		BatchTimeout: cfg.BatchTimeout,
+	redisClient, err := redis.NewClient(cfg.Redis())
+	if err != nil {
+		log.Fatalf("❌ Erro ao conectar no Redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	// Inicializa consumer do Redis Streams
+	consumer, err := redis.NewStreamConsumer(redisClient, redis.ConsumerConfig{
+		ConsumerID:    cfg.WorkerID,
+		BatchSize:     int64(cfg.BatchSize),
+		BatchTimeout:  cfg.BatchTimeout,
+		ClaimInterval: cfg.ClaimInterval,
+		ClaimMinIdle:  cfg.ClaimMinIdle,
+		MaxDeliveries: int64(cfg.MaxDeliveries),
 	}, repo)
 	if err != nil {
 		log.Fatalf("❌ Erro ao criar consumer: %v", err)
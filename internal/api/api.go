@@ -0,0 +1,55 @@
+// Package api expõe endpoints HTTP sobre o estado das salas e o histórico persistido
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/5ucr4m/go-socket/internal/pubsub"
+)
+
+// Server agrupa os handlers HTTP da API
+type Server struct {
+	rooms *pubsub.RoomManager
+
+	// Chaves de serviço aceitas pela API (leitura e publicação)
+	keys []APIKey
+
+	// Instância que responde (salas e estatísticas são locais a ela)
+	instanceID string
+}
+
+// NewServer cria a API sobre o gerenciador de salas do Hub
+// Sem chaves, todas as rotas respondem 401
+func NewServer(rooms *pubsub.RoomManager, keys []APIKey, instanceID string) *Server {
+	return &Server{rooms: rooms, keys: keys, instanceID: instanceID}
+}
+
+// Register registra as rotas da API no mux informado
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/rooms", s.handleListRooms)
+	mux.HandleFunc("GET /api/rooms/{name}", s.handleGetRoom)
+	mux.HandleFunc("GET /api/rooms/{name}/messages", s.handleRoomMessages)
+	mux.HandleFunc("GET /api/rooms/{name}/presence", s.handleRoomPresence)
+	mux.HandleFunc("GET /api/search", s.handleSearch)
+	mux.HandleFunc("POST /api/rooms/{name}/publish", s.handlePublish)
+	mux.HandleFunc("POST /api/users/{id}/send", s.handleSend)
+}
+
+// writeJSON serializa a resposta com o status informado
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("[API] Erro ao serializar resposta: %v", err)
+	}
+}
+
+// writeError responde com {"code", "error"}
+func writeError(w http.ResponseWriter, status int, code, reason string) {
+	writeJSON(w, status, map[string]string{
+		"code":  code,
+		"error": reason,
+	})
+}
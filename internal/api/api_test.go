@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/pubsub"
+	"github.com/gorilla/websocket"
+)
+
+// Chave de teste: publica nas salas order:* e lê qualquer sala
+const testSecret = "segredo"
+
+// frameTimeout é o tempo máximo de espera por um frame WebSocket
+const frameTimeout = 2 * time.Second
+
+// newTestServer sobe a API e o endpoint /ws sobre o mesmo Hub
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	keys, err := ParseAPIKeys([]string{"svc:" + HashAPIKey(testSecret) + ":publish:order:*|send:*|read:*"})
+	if err != nil {
+		t.Fatalf("ParseAPIKeys: %v", err)
+	}
+
+	hub := pubsub.NewHub()
+	go hub.Run()
+
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	NewServer(hub.RoomManager(), keys, "test").Register(mux)
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := pubsub.NewClient(hub, conn)
+		hub.Register(client)
+		go client.WritePump()
+		go client.ReadPump()
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// dialClient abre uma conexão WebSocket (sem autenticação do servidor) e
+// envia os eventos informados
+func dialClient(t *testing.T, server *httptest.Server, events ...string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	for _, event := range events {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(event)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+	return conn
+}
+
+// waitFrame aguarda um frame do tipo informado, ignorando os demais
+func waitFrame(t *testing.T, conn *websocket.Conn, frameType string) map[string]interface{} {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(frameTimeout))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("frame %s não recebido: %v", frameType, err)
+		}
+		// O writePump pode agrupar frames separados por quebra de linha
+		for _, line := range strings.Split(string(data), "\n") {
+			var frame map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &frame); err != nil {
+				t.Fatalf("frame inválido: %v", err)
+			}
+			if frame["type"] == frameType {
+				return frame
+			}
+		}
+	}
+}
+
+// joinRoom conecta um cliente com o usuário informado e o inscreve na sala
+// A lista de presença, respondida depois do subscribe, confirma a inscrição
+func joinRoom(t *testing.T, server *httptest.Server, room, user string) *websocket.Conn {
+	t.Helper()
+
+	conn := dialClient(t, server,
+		`{"type":"subscribe","room":"`+room+`","user":`+user+`}`,
+		`{"type":"presence","room":"`+room+`"}`)
+	waitFrame(t, conn, "presence_list")
+	return conn
+}
+
+// post envia uma requisição autenticada com o cabeçalho informado
+func post(t *testing.T, server *httptest.Server, path, header, value, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("resposta inválida: %v", err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestPublishAuthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		value      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"sem chave", "/api/rooms/order:1/publish", "", "", http.StatusUnauthorized, "unauthorized"},
+		{"chave desconhecida", "/api/rooms/order:1/publish", "X-API-Key", "outro", http.StatusUnauthorized, "unauthorized"},
+		{"bearer desconhecido", "/api/rooms/order:1/publish", "Authorization", "Bearer outro", http.StatusUnauthorized, "unauthorized"},
+		{"sala fora do escopo", "/api/rooms/geral/publish", "X-API-Key", testSecret, http.StatusForbidden, "forbidden"},
+		{"chave válida", "/api/rooms/order:1/publish", "X-API-Key", testSecret, http.StatusAccepted, ""},
+		{"chave válida como bearer", "/api/rooms/order:1/publish", "Authorization", "Bearer " + testSecret, http.StatusAccepted, ""},
+		{"mensagem direta para usuário offline", "/api/users/ninguem/send", "X-API-Key", testSecret, http.StatusNotFound, "user_offline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+
+			status, body := post(t, server, tt.path, tt.header, tt.value, `{"payload":{"status":"pago"}}`)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.wantStatus, body)
+			}
+			if tt.wantCode != "" && body["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", body["code"], tt.wantCode)
+			}
+			if id, _ := body["messageId"].(string); tt.wantStatus == http.StatusAccepted && id == "" {
+				t.Error("resposta sem messageId")
+			}
+		})
+	}
+}
+
+func TestPublishMarksSystemSender(t *testing.T) {
+	server := newTestServer(t)
+	reader := joinRoom(t, server, "order:1", `{"id":"leitor"}`)
+
+	// O corpo tenta escolher o remetente; vale o da chave
+	status, body := post(t, server, "/api/rooms/order:1/publish", "X-API-Key", testSecret,
+		`{"payload":{"status":"pago"},"user":{"id":"system:admin","system":true}}`)
+	if status != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 (%v)", status, body)
+	}
+
+	frame := waitFrame(t, reader, "message")
+	if frame["messageId"] != body["messageId"] {
+		t.Errorf("messageId = %v, want %v", frame["messageId"], body["messageId"])
+	}
+	user, _ := frame["user"].(map[string]interface{})
+	if user["id"] != pubsub.SystemIDPrefix+"svc" || user["system"] != true {
+		t.Errorf("user = %v, want o usuário de sistema da chave svc", user)
+	}
+}
+
+func TestClientCannotSpoofSystemUser(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+	}{
+		{"id reservado", `{"id":"system:svc"}`},
+		{"marca system", `{"id":"svc","system":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			reader := joinRoom(t, server, "order:1", `{"id":"leitor"}`)
+
+			spoofer := joinRoom(t, server, "order:1", tt.user)
+			if err := spoofer.WriteMessage(websocket.TextMessage,
+				[]byte(`{"type":"publish","room":"order:1","payload":"pago"}`)); err != nil {
+				t.Fatalf("WriteMessage: %v", err)
+			}
+
+			frame := waitFrame(t, reader, "message")
+			user, _ := frame["user"].(map[string]interface{})
+			if id, _ := user["id"].(string); strings.HasPrefix(id, pubsub.SystemIDPrefix) || user["system"] == true {
+				t.Errorf("cliente publicou como serviço: user = %v", user)
+			}
+			metadata, _ := frame["metadata"].(map[string]interface{})
+			if metadata["system"] == true {
+				t.Errorf("mensagem de cliente marcada como sistema: metadata = %v", metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Ações que podem ser concedidas a uma chave de API
+const (
+	ScopePublish = "publish" // POST /api/rooms/{name}/publish
+	ScopeSend    = "send"    // POST /api/users/{id}/send
+	ScopeRead    = "read"    // GET /api/rooms*, /api/search
+)
+
+// APIKey é uma chave de serviço; apenas o hash SHA-256 da chave fica na configuração
+type APIKey struct {
+	Name   string
+	Hash   []byte
+	Scopes []string // "ação:padrão", ex.: "publish:order:*", "send:*", "read:order:*"
+}
+
+// ParseAPIKeys lê entradas no formato "nome:sha256hex:escopo1|escopo2"
+func ParseAPIKeys(entries []string) ([]APIKey, error) {
+	keys := make([]APIKey, 0, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("chave de API inválida %q: use nome:sha256hex:escopos", entry)
+		}
+
+		hash, err := hex.DecodeString(parts[1])
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("chave de API %q: hash SHA-256 inválido", parts[0])
+		}
+
+		scopes := strings.Split(parts[2], "|")
+		for _, scope := range scopes {
+			action, _, _ := strings.Cut(scope, ":")
+			if action != ScopePublish && action != ScopeSend && action != ScopeRead {
+				return nil, fmt.Errorf("chave de API %q: escopo desconhecido %q", parts[0], scope)
+			}
+		}
+
+		keys = append(keys, APIKey{
+			Name:   parts[0],
+			Hash:   hash,
+			Scopes: scopes,
+		})
+	}
+	return keys, nil
+}
+
+// HashAPIKey retorna o hash hexadecimal a ser usado na configuração
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Allows indica se a chave pode executar a ação sobre o alvo (sala ou usuário)
+// Escopo sem padrão ("publish") vale para qualquer alvo
+func (k *APIKey) Allows(action, target string) bool {
+	for _, scope := range k.Scopes {
+		scopeAction, pattern, hasPattern := strings.Cut(scope, ":")
+		if scopeAction != action {
+			continue
+		}
+		if !hasPattern || pattern == "*" {
+			return true
+		}
+		if matched, err := path.Match(pattern, target); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope indica se a chave tem a ação para algum alvo
+func (k *APIKey) HasScope(action string) bool {
+	for _, scope := range k.Scopes {
+		if scopeAction, _, _ := strings.Cut(scope, ":"); scopeAction == action {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate encontra a chave enviada em "Authorization: Bearer" ou "X-API-Key"
+func (s *Server) authenticate(r *http.Request) *APIKey {
+	key := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if key == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	for i := range s.keys {
+		if subtle.ConstantTimeCompare(sum[:], s.keys[i].Hash) == 1 {
+			return &s.keys[i]
+		}
+	}
+	return nil
+}
+
+// authorize autentica a requisição e verifica o escopo; responde com 401/403 em caso de falha
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, action, target string) (*APIKey, bool) {
+	key, ok := s.authorizeAny(w, r, action)
+	if !ok {
+		return nil, false
+	}
+	if !key.Allows(action, target) {
+		writeError(w, http.StatusForbidden, "forbidden", "Chave de API sem permissão para "+action+" em "+target)
+		return nil, false
+	}
+	return key, true
+}
+
+// authorizeAny autentica a requisição e exige a ação para pelo menos um alvo
+// Usado por rotas que filtram os alvos depois (listagem e busca)
+func (s *Server) authorizeAny(w http.ResponseWriter, r *http.Request, action string) (*APIKey, bool) {
+	key := s.authenticate(r)
+	if key == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Chave de API ausente ou inválida")
+		return nil, false
+	}
+	if !key.HasScope(action) {
+		writeError(w, http.StatusForbidden, "forbidden", "Chave de API sem permissão para "+action)
+		return nil, false
+	}
+	return key, true
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/5ucr4m/go-socket/internal/pubsub"
+)
+
+// Tamanho máximo do corpo das requisições de publicação
+const maxPublishBody = 64 * 1024
+
+// publishRequest é o corpo de publish e send, no mesmo formato do evento WebSocket
+type publishRequest struct {
+	Payload interface{} `json:"payload"`
+}
+
+// handlePublish publica uma mensagem de sistema em uma sala
+// POST /api/rooms/{name}/publish {"payload": {...}}
+func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
+	roomName := r.PathValue("name")
+	key, ok := s.authorize(w, r, ScopePublish, roomName)
+	if !ok {
+		return
+	}
+
+	req, ok := decodePublish(w, r)
+	if !ok {
+		return
+	}
+
+	msg, err := s.rooms.PublishSystem(roomName, req.Payload, pubsub.SystemUser(key.Name))
+	if err != nil {
+		log.Printf("[API] Erro ao publicar na sala %s (chave %s): %v", roomName, key.Name, err)
+		writeError(w, http.StatusInternalServerError, "publish_failed", "Erro ao publicar mensagem")
+		return
+	}
+
+	log.Printf("[API] Mensagem %s publicada na sala %s pela chave %s", msg.ID, roomName, key.Name)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"messageId": msg.ID,
+		"room":      roomName,
+		"createdAt": msg.CreatedAt,
+	})
+}
+
+// handleSend envia uma mensagem direta de sistema para um usuário
+// POST /api/users/{id}/send {"payload": {...}}
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	key, ok := s.authorize(w, r, ScopeSend, userID)
+	if !ok {
+		return
+	}
+
+	req, ok := decodePublish(w, r)
+	if !ok {
+		return
+	}
+
+	delivered, err := s.rooms.SendSystemMessage(userID, req.Payload, pubsub.SystemUser(key.Name))
+	if err != nil {
+		log.Printf("[API] Erro ao enviar para %s (chave %s): %v", userID, key.Name, err)
+		writeError(w, http.StatusInternalServerError, "send_failed", "Erro ao enviar mensagem")
+		return
+	}
+	if !delivered {
+		writeError(w, http.StatusNotFound, "user_offline", "Usuário não encontrado ou offline")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"userId":    userID,
+		"delivered": true,
+	})
+}
+
+// decodePublish lê e valida o corpo de uma publicação
+func decodePublish(w http.ResponseWriter, r *http.Request) (*publishRequest, bool) {
+	var req publishRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPublishBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "Corpo JSON inválido")
+		return nil, false
+	}
+	if req.Payload == nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "Campo payload obrigatório")
+		return nil, false
+	}
+	return &req, true
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/5ucr4m/go-socket/internal/pubsub"
+)
+
+// handleListRooms lista as salas ativas nesta instância que a chave pode ler
+// Não é uma visão do cluster: cada instância conhece apenas as próprias salas
+// GET /api/rooms
+func (s *Server) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.authorizeAny(w, r, ScopeRead)
+	if !ok {
+		return
+	}
+
+	rooms := make([]map[string]interface{}, 0)
+	for _, stats := range s.rooms.ListRooms() {
+		if roomName, _ := stats["room"].(string); key.Allows(ScopeRead, roomName) {
+			rooms = append(rooms, stats)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"instance": s.instanceID,
+		"rooms":    rooms,
+	})
+}
+
+// handleGetRoom retorna as estatísticas de uma sala nesta instância
+// (assinantes e histórico em memória locais; 404 se a sala não está ativa aqui)
+// GET /api/rooms/{name}
+func (s *Server) handleGetRoom(w http.ResponseWriter, r *http.Request) {
+	roomName, ok := s.readableRoom(w, r)
+	if !ok {
+		return
+	}
+
+	stats := s.rooms.GetRoomStats(roomName)
+	if stats == nil {
+		writeError(w, http.StatusNotFound, "room_not_found", "Sala não está ativa nesta instância")
+		return
+	}
+	stats["room"] = roomName
+	stats["instance"] = s.instanceID
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleRoomMessages retorna uma página de histórico no formato do frame history_page
+// GET /api/rooms/{name}/messages?before=id&after=id&limit=50
+func (s *Server) handleRoomMessages(w http.ResponseWriter, r *http.Request) {
+	roomName, ok := s.readableRoom(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	query := pubsub.HistoryQuery{
+		Before: params.Get("before"),
+		After:  params.Get("after"),
+	}
+	if query.Before != "" && query.After != "" {
+		writeError(w, http.StatusBadRequest, "invalid_cursor", "Use apenas before ou after")
+		return
+	}
+
+	var err error
+	if query.Limit, err = intParam(params.Get("limit")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_limit", "Parâmetro limit inválido")
+		return
+	}
+
+	page, err := s.rooms.GetHistoryPage(roomName, query)
+	if err != nil {
+		log.Printf("[API] Erro ao buscar histórico da sala %s: %v", roomName, err)
+		writeError(w, http.StatusServiceUnavailable, "history_unavailable", "Histórico indisponível")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pubsub.HistoryPageFrame(roomName, query, page))
+}
+
+// handleRoomPresence retorna a presença no formato do frame presence_list
+// GET /api/rooms/{name}/presence
+func (s *Server) handleRoomPresence(w http.ResponseWriter, r *http.Request) {
+	roomName, ok := s.readableRoom(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":         "presence_list",
+		"room":         roomName,
+		"presenceList": s.rooms.GetPresence(roomName),
+	})
+}
+
+// readableRoom extrai o nome da sala da rota e exige uma chave com escopo read na sala
+func (s *Server) readableRoom(w http.ResponseWriter, r *http.Request) (string, bool) {
+	roomName := r.PathValue("name")
+	if _, ok := s.authorize(w, r, ScopeRead, roomName); !ok {
+		return "", false
+	}
+	return roomName, true
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/5ucr4m/go-socket/internal/pubsub"
+)
+
+// handleSearch busca mensagens persistidas
+// GET /api/search?q=termos&room=a&room=b&user=id&from=2024-01-01&to=2024-02-01&limit=20&offset=0
+// room é obrigatório (repetido ou separado por vírgula); salas fora do escopo
+// read da chave de API são ignoradas
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.authorizeAny(w, r, ScopeRead)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+
+	req := pubsub.SearchRequest{
+		Query:  strings.TrimSpace(params.Get("q")),
+		Author: params.Get("user"),
+	}
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, "invalid_search", "Parâmetro q obrigatório")
+		return
+	}
+
+	for _, value := range params["room"] {
+		for _, roomName := range strings.Split(value, ",") {
+			if roomName = strings.TrimSpace(roomName); roomName != "" {
+				req.Rooms = append(req.Rooms, roomName)
+			}
+		}
+	}
+	if len(req.Rooms) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_search", "Parâmetro room obrigatório")
+		return
+	}
+
+	var err error
+	if req.From, err = pubsub.ParseSearchTime(params.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_search", "Parâmetro from inválido")
+		return
+	}
+	if req.To, err = pubsub.ParseSearchTime(params.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_search", "Parâmetro to inválido")
+		return
+	}
+	if req.Limit, err = intParam(params.Get("limit")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_search", "Parâmetro limit inválido")
+		return
+	}
+	if req.Offset, err = intParam(params.Get("offset")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_search", "Parâmetro offset inválido")
+		return
+	}
+
+	page, err := s.rooms.SearchMessages(func(roomName string) bool {
+		return key.Allows(ScopeRead, roomName)
+	}, req)
+	if err != nil {
+		log.Printf("[API] Erro na busca: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "search_unavailable", "Busca indisponível")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// intParam converte um parâmetro numérico opcional (vazio = 0)
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reservedIDPrefix identifica os serviços que publicam pela API HTTP
+// (pubsub.SystemUser); credenciais de usuários não podem usá-lo
+const reservedIDPrefix = "system:"
+
+// Authenticator identifica o usuário a partir do upgrade do WebSocket
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Session, error)
+
+	// Refresh verifica um token novo enviado pelo evento reauth
+	Refresh(token string) (*Session, error)
+}
+
+// RejectedError indica credenciais recusadas (responder 401, não 503)
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "credenciais recusadas: " + e.Reason
+}
+
+// ChannelAuthorizer decide, fora do servidor, o acesso a uma sala
+type ChannelAuthorizer interface {
+	AuthorizeChannel(session *Session, roomName, action string) (bool, error)
+}
+
+// Session é a identidade de uma conexão autenticada
+type Session struct {
+	// Perfil do usuário (userInfo do cliente)
+	User map[string]interface{}
+
+	// Fim da validade da credencial (zero = não expira)
+	ExpiresAt time.Time
+
+	// Padrões de sala liberados ("order:123", "public:*"); nil = sem restrição
+	// própria, além da política de salas e do authorizer
+	Rooms []string
+
+	// Decisões de sala fora de Rooms (nil = negar)
+	authorizer ChannelAuthorizer
+
+	// Cabeçalhos repassados ao authorizer
+	header http.Header
+
+	// Decisões já tomadas, válidas por ttl
+	ttl       time.Duration
+	mu        sync.Mutex
+	decisions map[string]decision
+}
+
+// decision é uma autorização de sala em cache
+type decision struct {
+	allowed bool
+	expires time.Time
+}
+
+// checkUser recusa perfis que se passam por um serviço
+// A marca "system" é exclusiva das mensagens de serviços e é descartada
+func checkUser(user map[string]interface{}) error {
+	if id, _ := user["id"].(string); strings.HasPrefix(id, reservedIDPrefix) {
+		return &RejectedError{Reason: "id de usuário reservado: " + id}
+	}
+	delete(user, "system")
+	return nil
+}
+
+// UserInfo retorna o perfil do usuário da sessão
+func (s *Session) UserInfo() map[string]interface{} {
+	return s.User
+}
+
+// Expiry retorna o fim da validade da credencial (zero = não expira)
+func (s *Session) Expiry() time.Time {
+	return s.ExpiresAt
+}
+
+// AuthorizeRoom indica se a sessão pode executar a ação na sala
+// Implementa pubsub.RoomAuthorizer
+func (s *Session) AuthorizeRoom(roomName, action string) (bool, error) {
+	for _, pattern := range s.Rooms {
+		if matched, err := path.Match(pattern, roomName); err == nil && matched {
+			return true, nil
+		}
+	}
+
+	if s.authorizer == nil {
+		return s.Rooms == nil, nil
+	}
+
+	key := action + "|" + roomName
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.decisions[key]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.allowed, nil
+	}
+
+	allowed, err := s.authorizer.AuthorizeChannel(s, roomName, action)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if s.decisions == nil {
+		s.decisions = make(map[string]decision)
+	}
+	s.decisions[key] = decision{allowed: allowed, expires: now.Add(s.ttl)}
+	s.mu.Unlock()
+
+	return allowed, nil
+}
+
+// JWTAuthenticator autentica pelo token JWT do upgrade
+type JWTAuthenticator struct {
+	verifier   *Verifier
+	cookieName string
+}
+
+// NewJWTAuthenticator cria um Authenticator sobre o verificador de tokens
+func NewJWTAuthenticator(verifier *Verifier, cookieName string) *JWTAuthenticator {
+	return &JWTAuthenticator{verifier: verifier, cookieName: cookieName}
+}
+
+// Authenticate verifica o token e monta a sessão a partir das claims
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Session, error) {
+	claims, err := a.verifier.AuthenticateRequest(r, a.cookieName)
+	if err != nil {
+		return nil, &RejectedError{Reason: err.Error()}
+	}
+	return claimsSession(claims)
+}
+
+// Refresh verifica o token novo e monta a sessão a partir das claims
+func (a *JWTAuthenticator) Refresh(token string) (*Session, error) {
+	claims, err := a.verifier.Verify(token)
+	if err != nil {
+		return nil, &RejectedError{Reason: err.Error()}
+	}
+	return claimsSession(claims)
+}
+
+// claimsSession monta a sessão de um token verificado (exp define a validade)
+func claimsSession(claims Claims) (*Session, error) {
+	session := &Session{User: claims.UserInfo()}
+	if err := checkUser(session.User); err != nil {
+		return nil, err
+	}
+	if exp, ok := claims.Time("exp"); ok {
+		session.ExpiresAt = exp
+	}
+	return session, nil
+}
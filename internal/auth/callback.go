@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tamanho máximo da resposta do serviço de autenticação
+const maxCallbackResponse = 1 << 20
+
+// CallbackConfig define o serviço HTTP que autentica as conexões
+type CallbackConfig struct {
+	URL        string        // Recebe os cabeçalhos do upgrade e retorna {"user": {...}, "rooms": [...]}
+	ChannelURL string        // Decide salas fora de "rooms" (opcional; sem ele são negadas)
+	TTL        time.Duration // Validade das sessões e decisões em cache
+	Timeout    time.Duration // Timeout de cada chamada
+
+	// Cabeçalho com o IP real do cliente definido pelo proxy reverso (vazio = endereço da conexão)
+	ClientIPHeader string
+}
+
+// CallbackAuthenticator repassa o upgrade para um serviço HTTP
+//
+// POST URL com os cabeçalhos e cookies do upgrade:
+//
+//	200 {"user": {"id": "u1", ...}, "rooms": ["user:u1", "order:*"], "expiresAt": "2024-01-01T00:00:00Z"}
+//	401/403 recusa a conexão
+//
+// POST ChannelURL com os mesmos cabeçalhos e {"room", "action", "user"}:
+//
+//	200 {"allowed": true|false}
+type CallbackAuthenticator struct {
+	config CallbackConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	sessions  map[string]cachedSession
+	lastSweep time.Time
+}
+
+// cachedSession é uma sessão autenticada em cache
+type cachedSession struct {
+	session *Session
+	expires time.Time
+}
+
+// callbackResponse é a resposta do serviço de autenticação
+type callbackResponse struct {
+	User      map[string]interface{} `json:"user"`
+	Rooms     []string               `json:"rooms"`
+	ExpiresAt *time.Time             `json:"expiresAt,omitempty"` // Validade da credencial (opcional)
+}
+
+// Cabeçalhos do upgrade que não são repassados ao serviço
+var skippedHeaders = map[string]bool{
+	"Connection":               true,
+	"Upgrade":                  true,
+	"Host":                     true,
+	"Content-Length":           true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+}
+
+// NewCallbackAuthenticator cria o autenticador por callback HTTP
+func NewCallbackAuthenticator(config CallbackConfig) *CallbackAuthenticator {
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &CallbackAuthenticator{
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		sessions: make(map[string]cachedSession),
+	}
+}
+
+// Authenticate autentica o upgrade, reutilizando a sessão em cache das mesmas credenciais
+func (a *CallbackAuthenticator) Authenticate(r *http.Request) (*Session, error) {
+	return a.authenticateHeader(forwardedHeader(r, a.config.ClientIPHeader))
+}
+
+// Refresh autentica um token novo, enviado ao serviço como "Authorization: Bearer"
+func (a *CallbackAuthenticator) Refresh(token string) (*Session, error) {
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token)
+	return a.authenticateHeader(header)
+}
+
+// authenticateHeader consulta o serviço (ou o cache) com os cabeçalhos informados
+func (a *CallbackAuthenticator) authenticateHeader(header http.Header) (*Session, error) {
+	key := sessionKey(header)
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.sessions[key]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.session, nil
+	}
+
+	var profile callbackResponse
+	if err := a.call(a.config.URL, header, nil, &profile); err != nil {
+		return nil, err
+	}
+	if profile.User == nil {
+		return nil, &RejectedError{Reason: "serviço de autenticação não retornou o usuário"}
+	}
+	if err := checkUser(profile.User); err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		User:   profile.User,
+		Rooms:  profile.Rooms,
+		header: header,
+		ttl:    a.config.TTL,
+	}
+	if profile.ExpiresAt != nil {
+		session.ExpiresAt = *profile.ExpiresAt
+	}
+	if a.config.ChannelURL != "" {
+		session.authorizer = a
+	}
+
+	// Sessão em cache nunca sobrevive à credencial
+	expires := now.Add(a.config.TTL)
+	if !session.ExpiresAt.IsZero() && session.ExpiresAt.Before(expires) {
+		expires = session.ExpiresAt
+	}
+
+	a.mu.Lock()
+	a.sessions[key] = cachedSession{session: session, expires: expires}
+	a.sweep(now)
+	a.mu.Unlock()
+
+	return session, nil
+}
+
+// AuthorizeChannel pergunta ao serviço se a sessão pode usar a sala
+func (a *CallbackAuthenticator) AuthorizeChannel(session *Session, roomName, action string) (bool, error) {
+	body := map[string]interface{}{
+		"room":   roomName,
+		"action": action,
+		"user":   session.User,
+	}
+
+	var result struct {
+		Allowed bool `json:"allowed"`
+	}
+	if err := a.call(a.config.ChannelURL, session.header, body, &result); err != nil {
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			return false, nil
+		}
+		return false, err
+	}
+	return result.Allowed, nil
+}
+
+// call faz o POST ao serviço e decodifica a resposta
+func (a *CallbackAuthenticator) call(url string, header http.Header, body interface{}, target interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("erro ao serializar chamada de autenticação: %w", err)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("erro ao criar chamada de autenticação: %w", err)
+	}
+	req.Header = header.Clone()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("serviço de autenticação indisponível: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &RejectedError{Reason: resp.Status}
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("serviço de autenticação respondeu %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxCallbackResponse)).Decode(target); err != nil {
+		return fmt.Errorf("resposta inválida do serviço de autenticação: %w", err)
+	}
+	return nil
+}
+
+// sweep remove sessões expiradas (no máximo uma vez por TTL)
+// Deve ser chamado com a.mu travado
+func (a *CallbackAuthenticator) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < a.config.TTL {
+		return
+	}
+	a.lastSweep = now
+
+	removed := 0
+	for key, cached := range a.sessions {
+		if now.After(cached.expires) {
+			delete(a.sessions, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		log.Printf("[Auth] %d sessão(ões) expirada(s) removida(s) do cache", removed)
+	}
+}
+
+// forwardedHeader copia os cabeçalhos do upgrade para o serviço de autenticação
+// A URI original (com query string) e o IP do cliente vão em X-Original-URI e
+// X-Forwarded-For; o X-Forwarded-For enviado pelo cliente é substituído
+func forwardedHeader(r *http.Request, ipHeader string) http.Header {
+	header := make(http.Header)
+	for name, values := range r.Header {
+		if skippedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		header[name] = append([]string(nil), values...)
+	}
+
+	header.Set("X-Original-URI", r.URL.RequestURI())
+	header.Set("X-Forwarded-For", ClientIP(r, ipHeader))
+	return header
+}
+
+// sessionKey identifica exatamente os cabeçalhos repassados ao serviço
+// O serviço pode autenticar por qualquer um deles (inclusive o IP), então
+// só reaproveita a sessão quem envia o mesmo conjunto
+func sessionKey(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sum := sha256.New()
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(sum, "%d:%s%d:%s", len(name), name, len(value), value)
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// callbackService simula o serviço de autenticação e o de autorização de salas
+type callbackService struct {
+	mu sync.Mutex
+
+	// Resposta do endpoint de autenticação
+	status int
+	body   string
+
+	// Resposta do endpoint de salas
+	channelStatus  int
+	channelAllowed bool
+
+	// Chamadas recebidas (cabeçalhos e corpo)
+	authCalls    []http.Header
+	channelCalls []map[string]interface{}
+	channelHdrs  []http.Header
+}
+
+func (s *callbackService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/channel" {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.channelCalls = append(s.channelCalls, body)
+		s.channelHdrs = append(s.channelHdrs, r.Header.Clone())
+		if s.channelStatus != http.StatusOK {
+			w.WriteHeader(s.channelStatus)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"allowed": s.channelAllowed})
+		return
+	}
+
+	s.authCalls = append(s.authCalls, r.Header.Clone())
+	w.WriteHeader(s.status)
+	w.Write([]byte(s.body))
+}
+
+func (s *callbackService) calls() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.authCalls), len(s.channelCalls)
+}
+
+// newCallbackService sobe o serviço respondendo 200 com o usuário u1
+func newCallbackService(t *testing.T, ttl time.Duration) (*callbackService, *CallbackAuthenticator) {
+	t.Helper()
+
+	service := &callbackService{
+		status:         http.StatusOK,
+		body:           `{"user":{"id":"u1"},"rooms":["user:u1","order:*"]}`,
+		channelStatus:  http.StatusOK,
+		channelAllowed: true,
+	}
+	server := httptest.NewServer(service)
+	t.Cleanup(server.Close)
+
+	authenticator := NewCallbackAuthenticator(CallbackConfig{
+		URL:        server.URL + "/auth",
+		ChannelURL: server.URL + "/channel",
+		TTL:        ttl,
+		Timeout:    time.Second,
+	})
+	return service, authenticator
+}
+
+// callbackRequest monta um upgrade de WebSocket com cookie de sessão
+func callbackRequest(cookie string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/ws?room=geral", nil)
+	r.RemoteAddr = "203.0.113.7:4321"
+	r.Header.Set("Cookie", "session="+cookie)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	return r
+}
+
+func TestCallbackAuthenticateStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantRejected bool
+		wantErr      bool
+	}{
+		{"200 com usuário", http.StatusOK, `{"user":{"id":"u1"}}`, false, false},
+		{"401 recusa", http.StatusUnauthorized, ``, true, true},
+		{"403 recusa", http.StatusForbidden, ``, true, true},
+		{"500 indisponível", http.StatusInternalServerError, ``, false, true},
+		{"503 indisponível", http.StatusServiceUnavailable, ``, false, true},
+		{"200 sem usuário", http.StatusOK, `{"rooms":[]}`, true, true},
+		{"200 com id reservado", http.StatusOK, `{"user":{"id":"system:ops"}}`, true, true},
+		{"200 com corpo inválido", http.StatusOK, `{`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, authenticator := newCallbackService(t, time.Minute)
+			service.status = tt.status
+			service.body = tt.body
+
+			session, err := authenticator.Authenticate(callbackRequest("abc"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			var rejected *RejectedError
+			if errors.As(err, &rejected) != tt.wantRejected {
+				t.Errorf("err = %v, want RejectedError %v", err, tt.wantRejected)
+			}
+			if err == nil && session.User["id"] != "u1" {
+				t.Errorf("usuário = %v, want u1", session.User)
+			}
+		})
+	}
+}
+
+func TestCallbackForwardsUpgradeHeaders(t *testing.T) {
+	service, authenticator := newCallbackService(t, time.Minute)
+
+	if _, err := authenticator.Authenticate(callbackRequest("abc")); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	header := service.authCalls[0]
+	if got := header.Get("Cookie"); got != "session=abc" {
+		t.Errorf("Cookie = %q, want session=abc", got)
+	}
+	if got := header.Get("X-Original-URI"); got != "/ws?room=geral" {
+		t.Errorf("X-Original-URI = %q, want /ws?room=geral", got)
+	}
+	// O X-Forwarded-For do cliente é substituído pelo endereço da conexão
+	if got := header.Values("X-Forwarded-For"); len(got) != 1 || got[0] != "203.0.113.7" {
+		t.Errorf("X-Forwarded-For = %v, want [203.0.113.7]", got)
+	}
+	for _, name := range []string{"Upgrade", "Sec-Websocket-Key"} {
+		if got := header.Get(name); got != "" {
+			t.Errorf("%s repassado ao serviço: %q", name, got)
+		}
+	}
+}
+
+func TestCallbackSessionCache(t *testing.T) {
+	t.Run("mesmas credenciais reutilizam a sessão", func(t *testing.T) {
+		service, authenticator := newCallbackService(t, time.Minute)
+
+		first, err := authenticator.Authenticate(callbackRequest("abc"))
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		second, err := authenticator.Authenticate(callbackRequest("abc"))
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if first != second {
+			t.Error("sessão em cache não reutilizada")
+		}
+		if _, err := authenticator.Authenticate(callbackRequest("outro")); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if auth, _ := service.calls(); auth != 2 {
+			t.Errorf("serviço chamado %d vezes, want 2", auth)
+		}
+	})
+
+	t.Run("sessão expira com o TTL", func(t *testing.T) {
+		service, authenticator := newCallbackService(t, 20*time.Millisecond)
+
+		for i := 0; i < 2; i++ {
+			if _, err := authenticator.Authenticate(callbackRequest("abc")); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+		if auth, _ := service.calls(); auth != 2 {
+			t.Errorf("serviço chamado %d vezes, want 2", auth)
+		}
+	})
+
+	t.Run("credencial mais curta que o TTL limita o cache", func(t *testing.T) {
+		service, authenticator := newCallbackService(t, time.Minute)
+		expiresAt := time.Now().Add(20 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+		service.body = `{"user":{"id":"u1"},"expiresAt":"` + expiresAt + `"}`
+
+		for i := 0; i < 2; i++ {
+			if _, err := authenticator.Authenticate(callbackRequest("abc")); err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			time.Sleep(30 * time.Millisecond)
+		}
+		if auth, _ := service.calls(); auth != 2 {
+			t.Errorf("serviço chamado %d vezes, want 2", auth)
+		}
+	})
+
+	t.Run("recusa não fica em cache", func(t *testing.T) {
+		service, authenticator := newCallbackService(t, time.Minute)
+		service.status = http.StatusUnauthorized
+
+		for i := 0; i < 2; i++ {
+			if _, err := authenticator.Authenticate(callbackRequest("abc")); err == nil {
+				t.Fatal("credencial recusada aceita")
+			}
+		}
+		if auth, _ := service.calls(); auth != 2 {
+			t.Errorf("serviço chamado %d vezes, want 2", auth)
+		}
+	})
+}
+
+func TestCallbackRefreshSendsBearer(t *testing.T) {
+	service, authenticator := newCallbackService(t, time.Minute)
+
+	if _, err := authenticator.Refresh("novo-token"); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if got := service.authCalls[0].Get("Authorization"); got != "Bearer novo-token" {
+		t.Errorf("Authorization = %q, want Bearer novo-token", got)
+	}
+}
+
+func TestCallbackAuthorizeRoom(t *testing.T) {
+	tests := []struct {
+		name          string
+		room          string
+		channelStatus int
+		allowed       bool
+		want          bool
+		wantErr       bool
+		wantChannel   int
+	}{
+		{"sala da lista", "user:u1", http.StatusOK, false, true, false, 0},
+		{"padrão da lista", "order:42", http.StatusOK, false, true, false, 0},
+		{"fora da lista, permitida pelo serviço", "geral", http.StatusOK, true, true, false, 1},
+		{"fora da lista, negada pelo serviço", "geral", http.StatusOK, false, false, false, 1},
+		{"serviço recusa com 403", "geral", http.StatusForbidden, true, false, false, 1},
+		{"serviço indisponível", "geral", http.StatusInternalServerError, true, false, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, authenticator := newCallbackService(t, time.Minute)
+			service.channelStatus = tt.channelStatus
+			service.channelAllowed = tt.allowed
+
+			session, err := authenticator.Authenticate(callbackRequest("abc"))
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			got, err := session.AuthorizeRoom(tt.room, "subscribe")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("AuthorizeRoom = %v, %v; want %v (erro %v)", got, err, tt.want, tt.wantErr)
+			}
+			if _, channel := service.calls(); channel != tt.wantChannel {
+				t.Fatalf("serviço de salas chamado %d vezes, want %d", channel, tt.wantChannel)
+			}
+			if tt.wantChannel == 0 {
+				return
+			}
+
+			body := service.channelCalls[0]
+			user, _ := body["user"].(map[string]interface{})
+			if body["room"] != tt.room || body["action"] != "subscribe" || user["id"] != "u1" {
+				t.Errorf("corpo = %v, want sala %s, ação subscribe e usuário u1", body, tt.room)
+			}
+			if got := service.channelHdrs[0].Get("Cookie"); got != "session=abc" {
+				t.Errorf("Cookie = %q, want os cabeçalhos do upgrade", got)
+			}
+		})
+	}
+}
+
+func TestCallbackAuthorizeRoomCache(t *testing.T) {
+	service, authenticator := newCallbackService(t, time.Minute)
+
+	session, err := authenticator.Authenticate(callbackRequest("abc"))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	// A decisão fica em cache por ação e sala
+	for _, action := range []string{"subscribe", "subscribe", "publish"} {
+		if allowed, err := session.AuthorizeRoom("geral", action); err != nil || !allowed {
+			t.Fatalf("AuthorizeRoom(%s) = %v, %v", action, allowed, err)
+		}
+	}
+	if _, channel := service.calls(); channel != 2 {
+		t.Errorf("serviço de salas chamado %d vezes, want 2", channel)
+	}
+
+	// Falhas do serviço não ficam em cache
+	service.channelStatus = http.StatusInternalServerError
+	for i := 0; i < 2; i++ {
+		if _, err := session.AuthorizeRoom("outra", "subscribe"); err == nil {
+			t.Fatal("falha do serviço de salas sem erro")
+		}
+	}
+	if _, channel := service.calls(); channel != 4 {
+		t.Errorf("serviço de salas chamado %d vezes, want 4", channel)
+	}
+}
+
+func TestCallbackWithoutChannelURL(t *testing.T) {
+	service, authenticator := newCallbackService(t, time.Minute)
+	authenticator.config.ChannelURL = ""
+
+	session, err := authenticator.Authenticate(callbackRequest("abc"))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if allowed, err := session.AuthorizeRoom("geral", "subscribe"); err != nil || allowed {
+		t.Errorf("AuthorizeRoom = %v, %v; want negada sem consultar o serviço", allowed, err)
+	}
+	if _, channel := service.calls(); channel != 0 {
+		t.Errorf("serviço de salas chamado %d vezes", channel)
+	}
+}
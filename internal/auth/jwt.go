@@ -0,0 +1,333 @@
+// Package auth verifica credenciais de clientes WebSocket
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Claims são as claims de um token já verificado
+type Claims map[string]interface{}
+
+// VerifierConfig define as chaves aceitas e as validações de um token
+type VerifierConfig struct {
+	Secret        string        // Segredo HS256
+	PublicKeyFile string        // Chave pública RSA (PEM) para RS256
+	JWKSFile      string        // JWKS local com chaves RSA (RS256) e/ou oct (HS256)
+	Issuer        string        // iss esperado (opcional)
+	Audience      string        // aud esperado (opcional)
+	Leeway        time.Duration // Tolerância de relógio para exp/nbf
+	OptionalExp   bool          // Aceita tokens sem exp (por padrão exp é obrigatório)
+}
+
+// Verifier valida tokens JWT assinados com HS256 ou RS256
+type Verifier struct {
+	hmacKeys map[string][]byte         // Por kid ("" = chave padrão)
+	rsaKeys  map[string]*rsa.PublicKey // Por kid ("" = chave padrão)
+	issuer   string
+	audience string
+	leeway   time.Duration
+
+	// Tokens sem exp valeriam para sempre: só aceitos se configurado
+	optionalExp bool
+}
+
+// NewVerifier carrega as chaves configuradas
+// Retorna nil (autenticação desabilitada) se nenhuma chave foi configurada
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	if config.Secret == "" && config.PublicKeyFile == "" && config.JWKSFile == "" {
+		return nil, nil
+	}
+
+	v := &Verifier{
+		hmacKeys: make(map[string][]byte),
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		leeway:   config.Leeway,
+
+		optionalExp: config.OptionalExp,
+	}
+
+	if config.Secret != "" {
+		v.hmacKeys[""] = []byte(config.Secret)
+	}
+
+	if config.PublicKeyFile != "" {
+		key, err := loadRSAPublicKey(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys[""] = key
+	}
+
+	if config.JWKSFile != "" {
+		if err := v.loadJWKS(config.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// Verify valida assinatura, sub, exp, nbf, iss e aud e retorna as claims
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token malformado")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("cabeçalho inválido: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("assinatura inválida: %w", err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	switch header.Alg {
+	case "HS256":
+		key, ok := lookupKey(v.hmacKeys, header.Kid)
+		if !ok {
+			return nil, fmt.Errorf("nenhuma chave HS256 para kid %q", header.Kid)
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, fmt.Errorf("assinatura inválida")
+		}
+
+	case "RS256":
+		key, ok := lookupKey(v.rsaKeys, header.Kid)
+		if !ok {
+			return nil, fmt.Errorf("nenhuma chave RS256 para kid %q", header.Kid)
+		}
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, fmt.Errorf("assinatura inválida")
+		}
+
+	default:
+		return nil, fmt.Errorf("algoritmo não suportado: %q", header.Alg)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims inválidas: %w", err)
+	}
+
+	if err := v.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validateClaims verifica sujeito, validade temporal, emissor e audiência
+func (v *Verifier) validateClaims(claims Claims, now time.Time) error {
+	// sub é o id do cliente; sem ele a conexão não teria identidade
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return fmt.Errorf("token sem sub")
+	}
+
+	exp, hasExp := claims.Time("exp")
+	if !hasExp && !v.optionalExp {
+		return fmt.Errorf("token sem exp")
+	}
+	if hasExp && now.After(exp.Add(v.leeway)) {
+		return fmt.Errorf("token expirado")
+	}
+	if nbf, ok := claims.Time("nbf"); ok && now.Add(v.leeway).Before(nbf) {
+		return fmt.Errorf("token ainda não é válido")
+	}
+
+	if v.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return fmt.Errorf("emissor inválido: %q", iss)
+		}
+	}
+
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return fmt.Errorf("audiência inválida")
+	}
+
+	return nil
+}
+
+// Time lê uma claim NumericDate (segundos desde a época)
+func (c Claims) Time(name string) (time.Time, bool) {
+	value, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(value), 0), true
+}
+
+// hasAudience indica se aud (string ou lista) contém a audiência esperada
+func (c Claims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, item := range aud {
+			if item == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UserInfo monta o userInfo do cliente a partir das claims
+// id vem de sub; username de preferred_username, username ou name
+// Demais claims (roles, email, ...) são copiadas, exceto as registradas do JWT
+func (c Claims) UserInfo() map[string]interface{} {
+	user := make(map[string]interface{}, len(c))
+	for name, value := range c {
+		switch name {
+		case "sub", "iss", "aud", "exp", "nbf", "iat", "jti":
+			continue
+		}
+		user[name] = value
+	}
+
+	if sub, ok := c["sub"].(string); ok {
+		user["id"] = sub
+	}
+	for _, name := range []string{"preferred_username", "username", "name"} {
+		if username, ok := c[name].(string); ok && username != "" {
+			user["username"] = username
+			break
+		}
+	}
+	return user
+}
+
+// loadJWKS carrega chaves RSA e oct de um arquivo JWKS
+func (v *Verifier) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erro ao ler JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("erro ao parsear JWKS: %w", err)
+	}
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		switch jwk.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				return fmt.Errorf("JWKS: módulo inválido (kid %q): %w", jwk.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				return fmt.Errorf("JWKS: expoente inválido (kid %q): %w", jwk.Kid, err)
+			}
+			exponent := 0
+			for _, b := range e {
+				exponent = exponent<<8 | int(b)
+			}
+			key := &rsa.PublicKey{E: exponent}
+			key.N = new(big.Int).SetBytes(n)
+			v.rsaKeys[jwk.Kid] = key
+
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+			if err != nil {
+				return fmt.Errorf("JWKS: segredo inválido (kid %q): %w", jwk.Kid, err)
+			}
+			v.hmacKeys[jwk.Kid] = secret
+		}
+	}
+
+	if len(v.rsaKeys) == 0 && len(v.hmacKeys) == 0 {
+		return fmt.Errorf("JWKS sem chaves de assinatura: %s", path)
+	}
+	return nil
+}
+
+// loadRSAPublicKey lê uma chave pública RSA em PEM (PKIX ou PKCS#1)
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler chave pública: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("chave pública sem bloco PEM: %s", path)
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao parsear chave pública: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("chave pública não é RSA: %s", path)
+	}
+	return key, nil
+}
+
+// lookupKey busca a chave pelo kid; kid desconhecido usa a chave padrão
+// (segredo ou PEM) e token sem kid aceita a única chave de um JWKS
+func lookupKey[K any](keys map[string]K, kid string) (K, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if key, ok := keys[""]; ok {
+		return key, true
+	}
+	var zero K
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return zero, false
+}
+
+// decodeSegment decodifica um segmento base64url de JSON
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "segredo-de-teste"
+
+// signToken monta um JWT com o cabeçalho e as claims informados
+// key é []byte (HS256) ou *rsa.PrivateKey (RS256)
+func signToken(t *testing.T, header, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+
+	encode := func(value interface{}) string {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("json: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(header) + "." + encode(claims)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("rsa: %v", err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims retorna claims aceitas pelo verificador padrão
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "u1",
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	}
+}
+
+// withClaims copia validClaims aplicando alterações (nil remove a claim)
+func withClaims(changes map[string]interface{}) map[string]interface{} {
+	claims := validClaims()
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+// writeFile grava um arquivo temporário do teste
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("arquivo: %v", err)
+	}
+	return path
+}
+
+func TestVerifyHS256Claims(t *testing.T) {
+	verifier, err := NewVerifier(VerifierConfig{
+		Secret:   testSecret,
+		Issuer:   "https://auth.exemplo.com",
+		Audience: "gosocket",
+		Leeway:   30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	now := time.Now()
+	base := map[string]interface{}{"iss": "https://auth.exemplo.com", "aud": "gosocket"}
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		merged := withClaims(base)
+		for name, value := range changes {
+			if value == nil {
+				delete(merged, name)
+				continue
+			}
+			merged[name] = value
+		}
+		return merged
+	}
+	hs256 := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+
+	tests := []struct {
+		name    string
+		header  map[string]interface{}
+		claims  map[string]interface{}
+		key     interface{}
+		wantErr bool
+	}{
+		{"válido", hs256, claims(nil), []byte(testSecret), false},
+		{"aud em lista", hs256, claims(map[string]interface{}{"aud": []interface{}{"outro", "gosocket"}}), []byte(testSecret), false},
+		{"expirado dentro da tolerância", hs256, claims(map[string]interface{}{"exp": float64(now.Add(-10 * time.Second).Unix())}), []byte(testSecret), false},
+		{"expirado", hs256, claims(map[string]interface{}{"exp": float64(now.Add(-time.Minute).Unix())}), []byte(testSecret), true},
+		{"sem exp", hs256, claims(map[string]interface{}{"exp": nil}), []byte(testSecret), true},
+		{"sem sub", hs256, claims(map[string]interface{}{"sub": nil}), []byte(testSecret), true},
+		{"sub vazio", hs256, claims(map[string]interface{}{"sub": ""}), []byte(testSecret), true},
+		{"nbf no futuro", hs256, claims(map[string]interface{}{"nbf": float64(now.Add(time.Minute).Unix())}), []byte(testSecret), true},
+		{"nbf dentro da tolerância", hs256, claims(map[string]interface{}{"nbf": float64(now.Add(10 * time.Second).Unix())}), []byte(testSecret), false},
+		{"emissor errado", hs256, claims(map[string]interface{}{"iss": "https://outro"}), []byte(testSecret), true},
+		{"sem emissor", hs256, claims(map[string]interface{}{"iss": nil}), []byte(testSecret), true},
+		{"audiência errada", hs256, claims(map[string]interface{}{"aud": "outro"}), []byte(testSecret), true},
+		{"audiência fora da lista", hs256, claims(map[string]interface{}{"aud": []interface{}{"a", "b"}}), []byte(testSecret), true},
+		{"segredo errado", hs256, claims(nil), []byte("outro"), true},
+		{"alg none", map[string]interface{}{"alg": "none"}, claims(nil), []byte(testSecret), true},
+		{"alg HS512", map[string]interface{}{"alg": "HS512"}, claims(nil), []byte(testSecret), true},
+		{"RS256 sem chave RSA", map[string]interface{}{"alg": "RS256"}, claims(nil), []byte(testSecret), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signToken(t, tt.header, tt.claims, tt.key)
+			_, err := verifier.Verify(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() erro = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyOptionalExp(t *testing.T) {
+	verifier, err := NewVerifier(VerifierConfig{Secret: testSecret, OptionalExp: true})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	token := signToken(t, map[string]interface{}{"alg": "HS256"}, withClaims(map[string]interface{}{"exp": nil}), []byte(testSecret))
+	if _, err := verifier.Verify(token); err != nil {
+		t.Errorf("token sem exp recusado com OptionalExp: %v", err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	verifier, err := NewVerifier(VerifierConfig{Secret: testSecret})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	valid := signToken(t, map[string]interface{}{"alg": "HS256"}, validClaims(), []byte(testSecret))
+	tests := []string{
+		"",
+		"a.b",
+		"a.b.c.d",
+		"!!!" + valid[strings.Index(valid, "."):],
+		valid[:strings.LastIndex(valid, ".")] + ".!!!",
+		valid + "x",
+	}
+	for _, token := range tests {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("Verify(%q) sem erro", token)
+		}
+	}
+}
+
+func TestVerifyRS256(t *testing.T) {
+	defaultKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&defaultKey.PublicKey)
+	if err != nil {
+		t.Fatalf("x509: %v", err)
+	}
+	pemFile := writeFile(t, "public.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"kty": "RSA",
+				"kid": "2024",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(rotatedKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rotatedKey.E)).Bytes()),
+			},
+			{
+				"kty": "oct",
+				"kid": "hs",
+				"k":   base64.RawURLEncoding.EncodeToString([]byte("segredo-jwks")),
+			},
+			{
+				"kty": "RSA",
+				"kid": "enc",
+				"use": "enc",
+				"n":   base64.RawURLEncoding.EncodeToString(otherKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(otherKey.E)).Bytes()),
+			},
+		},
+	})
+	jwksFile := writeFile(t, "jwks.json", jwks)
+
+	verifier, err := NewVerifier(VerifierConfig{PublicKeyFile: pemFile, JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  map[string]interface{}
+		key     interface{}
+		wantErr bool
+	}{
+		{"chave PEM sem kid", map[string]interface{}{"alg": "RS256"}, defaultKey, false},
+		{"kid desconhecido usa a chave PEM", map[string]interface{}{"alg": "RS256", "kid": "x"}, defaultKey, false},
+		{"kid do JWKS", map[string]interface{}{"alg": "RS256", "kid": "2024"}, rotatedKey, false},
+		{"kid do JWKS com outra chave", map[string]interface{}{"alg": "RS256", "kid": "2024"}, defaultKey, true},
+		{"chave de cifragem ignorada", map[string]interface{}{"alg": "RS256", "kid": "enc"}, otherKey, true},
+		{"oct do JWKS", map[string]interface{}{"alg": "HS256", "kid": "hs"}, []byte("segredo-jwks"), false},
+		{"HS256 assinado com o módulo público", map[string]interface{}{"alg": "HS256", "kid": "2024"}, rotatedKey.N.Bytes(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signToken(t, tt.header, validClaims(), tt.key)
+			_, err := verifier.Verify(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() erro = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookupKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   map[string]string
+		kid    string
+		want   string
+		wantOK bool
+	}{
+		{"kid exato", map[string]string{"a": "A", "b": "B"}, "a", "A", true},
+		{"kid desconhecido usa a padrão", map[string]string{"": "D", "a": "A"}, "x", "D", true},
+		{"kid desconhecido sem padrão", map[string]string{"a": "A", "b": "B"}, "x", "", false},
+		{"sem kid com chave única", map[string]string{"a": "A"}, "", "A", true},
+		{"sem kid com várias chaves", map[string]string{"a": "A", "b": "B"}, "", "", false},
+		{"sem chaves", map[string]string{}, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookupKey(tt.keys, tt.kid)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("lookupKey(%q) = %q, %v; want %q, %v", tt.kid, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClaimsSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  Claims
+		wantErr bool
+	}{
+		{"usuário comum", Claims{"sub": "u1", "preferred_username": "ana", "roles": []interface{}{"admin"}}, false},
+		{"id reservado de serviço", Claims{"sub": "system:pedidos"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := claimsSession(tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("claimsSession() erro = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if session.User["id"] != tt.claims["sub"] {
+				t.Errorf("id = %v, want %v", session.User["id"], tt.claims["sub"])
+			}
+		})
+	}
+
+	session, err := claimsSession(Claims{"sub": "u1", "system": true, "exp": float64(1700000000)})
+	if err != nil {
+		t.Fatalf("claimsSession: %v", err)
+	}
+	if _, ok := session.User["system"]; ok {
+		t.Error("marca system do token mantida no perfil")
+	}
+	if !session.ExpiresAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("ExpiresAt = %v, want exp do token", session.ExpiresAt)
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Máximo de origens distintas contadas separadamente; as demais vão para "other"
+// (o cabeçalho Origin é controlado pelo cliente)
+const maxTrackedOrigins = 100
+
+// OriginPolicy decide quais páginas podem abrir o WebSocket
+//
+// Sem Origin (clientes nativos, serviços) o upgrade é aceito: só navegadores
+// enviam cookies automaticamente. A mesma origem do Host é sempre aceita.
+// Entradas: "https://app.exemplo.com" (exata), "https://*.exemplo.com"
+// (qualquer subdomínio) ou "*" (qualquer origem)
+type OriginPolicy struct {
+	exact       map[string]bool
+	wildcards   []originWildcard
+	allowAll    bool
+	requireHost bool
+
+	mu         sync.Mutex
+	rejections map[string]int64
+}
+
+// originWildcard é uma entrada "scheme://*.dominio"
+type originWildcard struct {
+	scheme string
+	suffix string // ".dominio[:porta]"
+}
+
+// NewOriginPolicy valida a allowlist
+// requireHost exige, além da allowlist, que a origem seja o próprio Host
+func NewOriginPolicy(allowed []string, requireHost bool) (*OriginPolicy, error) {
+	policy := &OriginPolicy{
+		exact:       make(map[string]bool),
+		requireHost: requireHost,
+		rejections:  make(map[string]int64),
+	}
+
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(entry), "/"))
+		if entry == "*" {
+			policy.allowAll = true
+			continue
+		}
+
+		scheme, host, ok := strings.Cut(entry, "://")
+		if !ok || scheme == "" || host == "" || strings.Contains(host, "/") {
+			return nil, fmt.Errorf("origem inválida %q: use scheme://host[:porta]", entry)
+		}
+
+		if suffix, isWildcard := strings.CutPrefix(host, "*."); isWildcard {
+			// Porta padrão removida como nas origens recebidas ("https://*.x.com:443")
+			policy.wildcards = append(policy.wildcards, originWildcard{scheme: scheme, suffix: "." + normalizeHost(scheme, suffix)})
+			continue
+		}
+		policy.exact[scheme+"://"+normalizeHost(scheme, host)] = true
+	}
+
+	return policy, nil
+}
+
+// Check avalia a origem do upgrade sem registrar a decisão
+// Usado como Upgrader.CheckOrigin
+func (p *OriginPolicy) Check(r *http.Request) (bool, string) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true, ""
+	}
+
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false, "origem malformada"
+	}
+	host := normalizeHost(u.Scheme, u.Host)
+
+	sameHost := matchesHost(u.Scheme, host, r.Host)
+	if p.requireHost && !sameHost {
+		return false, "origem diferente do Host"
+	}
+	if sameHost || p.allowAll || p.exact[u.Scheme+"://"+host] {
+		return true, ""
+	}
+
+	for _, wildcard := range p.wildcards {
+		if u.Scheme == wildcard.scheme && strings.HasSuffix(host, wildcard.suffix) && len(host) > len(wildcard.suffix) {
+			return true, ""
+		}
+	}
+	return false, "origem fora da allowlist"
+}
+
+// Allow avalia a origem e registra rejeições por origem (métrica e log)
+func (p *OriginPolicy) Allow(r *http.Request) bool {
+	allowed, reason := p.Check(r)
+	if allowed {
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+
+	p.mu.Lock()
+	key := origin
+	if _, tracked := p.rejections[key]; !tracked && len(p.rejections) >= maxTrackedOrigins {
+		key = "other"
+	}
+	p.rejections[key]++
+	count := p.rejections[key]
+	p.mu.Unlock()
+
+	// Loga a primeira rejeição de cada origem e depois a cada 100
+	if count == 1 || count%100 == 0 {
+		log.Printf("[Origin] Upgrade recusado: origin=%q host=%q ip=%s motivo=%s (total: %d)",
+			origin, r.Host, r.RemoteAddr, reason, count)
+	}
+	return false
+}
+
+// Rejections retorna o número de upgrades recusados por origem
+func (p *OriginPolicy) Rejections() map[string]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(p.rejections))
+	for origin, count := range p.rejections {
+		snapshot[origin] = count
+	}
+	return snapshot
+}
+
+// matchesHost compara a origem com o Host do upgrade
+// Host sem porta (proxy com $host) compara apenas o nome
+func matchesHost(scheme, originHost, requestHost string) bool {
+	requestHost = strings.ToLower(requestHost)
+	if _, _, err := net.SplitHostPort(requestHost); err != nil {
+		name := originHost
+		if hostname, _, err := net.SplitHostPort(originHost); err == nil {
+			name = hostname
+		}
+		return name == requestHost
+	}
+	return originHost == normalizeHost(scheme, requestHost)
+}
+
+// normalizeHost remove a porta padrão do scheme
+func normalizeHost(scheme, host string) string {
+	switch {
+	case (scheme == "http" || scheme == "ws") && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	case (scheme == "https" || scheme == "wss") && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// upgradeRequest monta um upgrade com Host e Origin (vazio = sem Origin)
+func upgradeRequest(host, origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://"+host+"/ws", nil)
+	r.Host = host
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	return r
+}
+
+func TestOriginPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		requireHost bool
+		host        string
+		origin      string
+		want        bool
+	}{
+		{"sem Origin (cliente nativo)", nil, false, "chat.exemplo.com", "", true},
+		{"mesma origem", nil, false, "chat.exemplo.com", "https://chat.exemplo.com", true},
+		{"mesma origem com porta padrão", nil, false, "chat.exemplo.com:443", "https://chat.exemplo.com", true},
+		{"Host sem porta compara só o nome", nil, false, "localhost", "http://localhost:3000", true},
+		{"outra origem sem allowlist", nil, false, "chat.exemplo.com", "https://evil.com", false},
+		{"origem malformada", nil, false, "chat.exemplo.com", "://", false},
+		{"exata", []string{"https://app.exemplo.com"}, false, "api", "https://app.exemplo.com", true},
+		{"exata sem diferenciar maiúsculas", []string{"https://App.Exemplo.com/"}, false, "api", "https://APP.exemplo.com", true},
+		{"exata com porta padrão", []string{"https://app.exemplo.com:443"}, false, "api", "https://app.exemplo.com", true},
+		{"exata com scheme diferente", []string{"https://app.exemplo.com"}, false, "api", "http://app.exemplo.com", false},
+		{"exata com porta diferente", []string{"https://app.exemplo.com"}, false, "api", "https://app.exemplo.com:8443", false},
+		{"subdomínio", []string{"https://*.exemplo.com"}, false, "api", "https://a.b.exemplo.com", true},
+		{"curinga não cobre o domínio raiz", []string{"https://*.exemplo.com"}, false, "api", "https://exemplo.com", false},
+		{"curinga não cobre sufixo parecido", []string{"https://*.exemplo.com"}, false, "api", "https://evilexemplo.com", false},
+		{"curinga com porta padrão", []string{"https://*.exemplo.com:443"}, false, "api", "https://app.exemplo.com", true},
+		{"curinga com porta explícita", []string{"http://*.local:8080"}, false, "api", "http://app.local:8080", true},
+		{"curinga com scheme diferente", []string{"https://*.exemplo.com"}, false, "api", "http://app.exemplo.com", false},
+		{"qualquer origem", []string{"*"}, false, "api", "https://qualquer.com", true},
+		{"exigir Host recusa origem da allowlist", []string{"https://app.exemplo.com"}, true, "api.exemplo.com", "https://app.exemplo.com", false},
+		{"exigir Host aceita a mesma origem", nil, true, "app.exemplo.com", "https://app.exemplo.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewOriginPolicy(tt.allowed, tt.requireHost)
+			if err != nil {
+				t.Fatalf("NewOriginPolicy: %v", err)
+			}
+			if got, reason := policy.Check(upgradeRequest(tt.host, tt.origin)); got != tt.want {
+				t.Errorf("Check() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOriginPolicyInvalid(t *testing.T) {
+	for _, entry := range []string{"app.exemplo.com", "https://", "https://app.exemplo.com/path", "://app"} {
+		if _, err := NewOriginPolicy([]string{entry}, false); err == nil {
+			t.Errorf("NewOriginPolicy(%q) sem erro", entry)
+		}
+	}
+}
+
+func TestOriginPolicyRejections(t *testing.T) {
+	policy, err := NewOriginPolicy(nil, false)
+	if err != nil {
+		t.Fatalf("NewOriginPolicy: %v", err)
+	}
+
+	policy.Allow(upgradeRequest("api", "https://evil.com"))
+	policy.Allow(upgradeRequest("api", "https://evil.com"))
+	policy.Allow(upgradeRequest("api", "https://api"))
+
+	for i := 0; i < maxTrackedOrigins+5; i++ {
+		policy.Allow(upgradeRequest("api", fmt.Sprintf("https://o%d.com", i)))
+	}
+
+	rejections := policy.Rejections()
+	if rejections["https://evil.com"] != 2 {
+		t.Errorf("recusas de evil.com = %d, want 2", rejections["https://evil.com"])
+	}
+	if _, counted := rejections["https://api"]; counted {
+		t.Error("origem aceita contada como recusa")
+	}
+	if len(rejections) > maxTrackedOrigins+1 {
+		t.Errorf("%d origens contadas, want no máximo %d (+other)", len(rejections), maxTrackedOrigins)
+	}
+	if rejections["other"] == 0 {
+		t.Error("origens além do limite não foram para other")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		values []string
+		want   string
+	}{
+		{"sem cabeçalho configurado", "", []string{"1.1.1.1"}, "192.0.2.1"},
+		{"valor único", "X-Real-IP", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"lista usa o último endereço", "X-Forwarded-For", []string{"6.6.6.6, 203.0.113.7"}, "203.0.113.7"},
+		{"vários cabeçalhos usam o último", "X-Forwarded-For", []string{"6.6.6.6", "10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"cabeçalho ausente", "X-Real-IP", nil, "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			r.RemoteAddr = "192.0.2.1:5555"
+			for _, value := range tt.values {
+				name := tt.header
+				if name == "" {
+					name = "X-Forwarded-For"
+				}
+				r.Header.Add(name, value)
+			}
+			if got := ClientIP(r, tt.header); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
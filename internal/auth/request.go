@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// BearerProtocol é o subprotocolo que acompanha o token em Sec-WebSocket-Protocol
+// Navegadores não enviam cabeçalhos customizados no upgrade; o cliente usa
+// new WebSocket(url, ["bearer", token]) e o servidor responde "bearer"
+const BearerProtocol = "bearer"
+
+// DefaultCookieName é o cookie lido quando JWT_COOKIE não é informado
+const DefaultCookieName = "token"
+
+// TokenFromRequest extrai o token do upgrade, nesta ordem:
+// query param (token ou access_token), Sec-WebSocket-Protocol e cookie
+func TokenFromRequest(r *http.Request, cookieName string) string {
+	query := r.URL.Query()
+	if token := query.Get("token"); token != "" {
+		return token
+	}
+	if token := query.Get("access_token"); token != "" {
+		return token
+	}
+
+	protocols := websocketProtocols(r)
+	for i, protocol := range protocols {
+		if protocol == BearerProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+
+	if cookieName == "" {
+		cookieName = DefaultCookieName
+	}
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// AuthenticateRequest verifica o token do upgrade e retorna suas claims
+func (v *Verifier) AuthenticateRequest(r *http.Request, cookieName string) (Claims, error) {
+	token := TokenFromRequest(r, cookieName)
+	if token == "" {
+		return nil, fmt.Errorf("token ausente")
+	}
+	return v.Verify(token)
+}
+
+// websocketProtocols lista os subprotocolos pedidos pelo cliente
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
+
+// ClientIP retorna o IP do cliente do upgrade
+// Com header configurado (cabeçalho definido pelo proxy reverso), usa o último
+// endereço da lista: é o que o nosso proxy acrescentou, os anteriores vêm do cliente
+func ClientIP(r *http.Request, header string) string {
+	if header != "" {
+		values := r.Header.Values(header)
+		if len(values) > 0 {
+			entries := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,47 @@
+package backplane
+
+// Handler processa um evento recebido de outra instância
+type Handler = func(data []byte) error
+
+// Backplane transporta eventos entre instâncias do servidor por tópico
+// Eventos publicados por uma instância não são entregues de volta a ela
+type Backplane interface {
+	// Publish publica um evento em um tópico
+	Publish(topic string, data []byte) error
+
+	// Subscribe passa a entregar os eventos do tópico ao handler
+	Subscribe(topic string, handler Handler) error
+
+	// Unsubscribe deixa de receber os eventos do tópico
+	Unsubscribe(topic string) error
+
+	// Close encerra as inscrições e libera os recursos
+	Close() error
+}
+
+// HealthChecker é implementado por backplanes que reportam o estado da conexão
+type HealthChecker interface {
+	// IsConnected indica se o transporte está disponível
+	IsConnected() bool
+
+	// BufferedCount retorna o número de eventos aguardando a reconexão
+	BufferedCount() int
+}
+
+const (
+	// Tópico de broadcast para todos os clientes de todas as instâncias
+	BroadcastTopic = "broadcast"
+
+	roomTopicPrefix     = "room:"
+	instanceTopicPrefix = "instance:"
+)
+
+// RoomTopic retorna o tópico dos eventos de uma sala
+func RoomTopic(roomName string) string {
+	return roomTopicPrefix + roomName
+}
+
+// InstanceTopic retorna o tópico endereçado a uma instância
+func InstanceTopic(instanceID string) string {
+	return instanceTopicPrefix + instanceID
+}
@@ -0,0 +1,149 @@
+package backplane
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// memoryInboxSize é a capacidade da fila de entrega de cada instância
+const memoryInboxSize = 1024
+
+// MemoryBus conecta backplanes em memória do mesmo processo
+// Útil em testes que simulam várias instâncias
+type MemoryBus struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Memory]Handler
+}
+
+// NewMemoryBus cria um novo barramento em memória
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subscribers: make(map[string]map[*Memory]Handler),
+	}
+}
+
+// memoryDelivery é um evento aguardando entrega
+type memoryDelivery struct {
+	handler Handler
+	data    []byte
+}
+
+// Memory implementa Backplane dentro do processo
+// Com um barramento próprio, serve para instalações de nó único
+type Memory struct {
+	bus        *MemoryBus
+	instanceID string
+	inbox      chan memoryDelivery
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewMemory cria um backplane em memória conectado ao barramento informado
+// Se bus for nil, um barramento exclusivo é criado
+func NewMemory(bus *MemoryBus, instanceID string) *Memory {
+	if bus == nil {
+		bus = NewMemoryBus()
+	}
+
+	m := &Memory{
+		bus:        bus,
+		instanceID: instanceID,
+		inbox:      make(chan memoryDelivery, memoryInboxSize),
+		done:       make(chan struct{}),
+	}
+
+	go m.deliverLoop()
+
+	log.Printf("[Backplane Memory] Backplane em memória iniciado (instance: %s)", instanceID)
+	return m
+}
+
+// Publish entrega o evento às demais instâncias inscritas no tópico
+func (m *Memory) Publish(topic string, data []byte) error {
+	select {
+	case <-m.done:
+		return fmt.Errorf("backplane em memória fechado")
+	default:
+	}
+
+	m.bus.mu.RLock()
+	defer m.bus.mu.RUnlock()
+
+	for subscriber, handler := range m.bus.subscribers[topic] {
+		// Não entrega para a própria instância
+		if subscriber == m {
+			continue
+		}
+
+		// Cada instância recebe sua própria cópia
+		payload := make([]byte, len(data))
+		copy(payload, data)
+
+		select {
+		case subscriber.inbox <- memoryDelivery{handler: handler, data: payload}:
+		case <-subscriber.done:
+		default:
+			log.Printf("[Backplane Memory] Fila de %s cheia, evento descartado (tópico: %s)", subscriber.instanceID, topic)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe passa a entregar os eventos do tópico ao handler
+func (m *Memory) Subscribe(topic string, handler Handler) error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+
+	if m.bus.subscribers[topic] == nil {
+		m.bus.subscribers[topic] = make(map[*Memory]Handler)
+	}
+	m.bus.subscribers[topic][m] = handler
+	return nil
+}
+
+// Unsubscribe deixa de receber os eventos do tópico
+func (m *Memory) Unsubscribe(topic string) error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+
+	if subscribers, ok := m.bus.subscribers[topic]; ok {
+		delete(subscribers, m)
+		if len(subscribers) == 0 {
+			delete(m.bus.subscribers, topic)
+		}
+	}
+	return nil
+}
+
+// deliverLoop entrega os eventos recebidos na ordem de chegada
+func (m *Memory) deliverLoop() {
+	for {
+		select {
+		case <-m.done:
+			return
+		case delivery := <-m.inbox:
+			if err := delivery.handler(delivery.data); err != nil {
+				log.Printf("[Backplane Memory] Erro ao processar evento: %v", err)
+			}
+		}
+	}
+}
+
+// Close remove todas as inscrições desta instância
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() {
+		m.bus.mu.Lock()
+		for topic, subscribers := range m.bus.subscribers {
+			delete(subscribers, m)
+			if len(subscribers) == 0 {
+				delete(m.bus.subscribers, topic)
+			}
+		}
+		m.bus.mu.Unlock()
+
+		close(m.done)
+	})
+	return nil
+}
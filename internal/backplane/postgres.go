@@ -0,0 +1,304 @@
+package backplane
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Prefixo dos canais LISTEN/NOTIFY
+	postgresChannelPrefix = "gosocket:"
+
+	// Tamanho máximo de um identificador no PostgreSQL
+	postgresMaxChannelLen = 63
+
+	// Limite do payload de NOTIFY (8000 bytes, menos o terminador)
+	postgresMaxPayload = 7999
+
+	// Espera entre tentativas de reconexão do listener
+	postgresReconnectDelay = 2 * time.Second
+)
+
+// postgresEnvelope encapsula eventos com a instância de origem
+type postgresEnvelope struct {
+	InstanceID string          `json:"instance_id"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Postgres implementa Backplane com LISTEN/NOTIFY do PostgreSQL
+// Uma conexão dedicada faz LISTEN nos tópicos inscritos; NOTIFY usa o pool.
+// Eventos maiores que o limite do NOTIFY (~8KB) são rejeitados.
+type Postgres struct {
+	pool       *pgxpool.Pool
+	connString string
+	instanceID string
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// Handlers por canal (nome já convertido)
+	handlers map[string]Handler
+	mu       sync.RWMutex
+
+	// Sinaliza ao listener que o conjunto de canais mudou
+	changed chan struct{}
+
+	connected atomic.Bool
+	done      chan struct{}
+}
+
+// NewPostgres cria um backplane sobre o PostgreSQL
+func NewPostgres(postgresURL string, instanceID string) (*Postgres, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool, err := pgxpool.New(ctx, postgresURL)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("falha ao criar pool de conexões: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		cancel()
+		return nil, fmt.Errorf("falha ao conectar no PostgreSQL: %w", err)
+	}
+
+	p := &Postgres{
+		pool:       pool,
+		connString: postgresURL,
+		instanceID: instanceID,
+		ctx:        ctx,
+		cancel:     cancel,
+		handlers:   make(map[string]Handler),
+		changed:    make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	go p.listenLoop()
+
+	log.Printf("[Backplane Postgres] Backplane LISTEN/NOTIFY iniciado (instance: %s)", instanceID)
+	return p, nil
+}
+
+// channelName converte um tópico em um canal válido do PostgreSQL
+// Tópicos longos demais são substituídos por um hash
+func channelName(topic string) string {
+	channel := postgresChannelPrefix + topic
+	if len(channel) <= postgresMaxChannelLen {
+		return channel
+	}
+
+	sum := sha1.Sum([]byte(topic))
+	return postgresChannelPrefix + hex.EncodeToString(sum[:])
+}
+
+// Publish publica o evento com NOTIFY
+func (p *Postgres) Publish(topic string, data []byte) error {
+	payload, err := json.Marshal(postgresEnvelope{
+		InstanceID: p.instanceID,
+		Payload:    data,
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao serializar envelope: %w", err)
+	}
+
+	if len(payload) > postgresMaxPayload {
+		return fmt.Errorf("evento de %d bytes excede o limite do NOTIFY (%d bytes)", len(payload), postgresMaxPayload)
+	}
+
+	if _, err := p.pool.Exec(p.ctx, "SELECT pg_notify($1, $2)", channelName(topic), string(payload)); err != nil {
+		return fmt.Errorf("erro ao publicar evento: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe registra o handler e faz LISTEN no canal do tópico
+func (p *Postgres) Subscribe(topic string, handler Handler) error {
+	p.mu.Lock()
+	p.handlers[channelName(topic)] = handler
+	p.mu.Unlock()
+
+	p.notifyChanged()
+	return nil
+}
+
+// Unsubscribe remove o handler e faz UNLISTEN no canal do tópico
+func (p *Postgres) Unsubscribe(topic string) error {
+	p.mu.Lock()
+	delete(p.handlers, channelName(topic))
+	p.mu.Unlock()
+
+	p.notifyChanged()
+	return nil
+}
+
+// notifyChanged acorda o listener para sincronizar os canais
+func (p *Postgres) notifyChanged() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// listenLoop mantém a conexão de LISTEN, reconectando em caso de falha
+func (p *Postgres) listenLoop() {
+	defer close(p.done)
+
+	for p.ctx.Err() == nil {
+		conn, err := pgx.Connect(p.ctx, p.connString)
+		if err != nil {
+			if p.ctx.Err() == nil {
+				log.Printf("[Backplane Postgres] Erro ao conectar listener: %v", err)
+				p.sleep(postgresReconnectDelay)
+			}
+			continue
+		}
+
+		p.connected.Store(true)
+		err = p.listen(conn)
+		p.connected.Store(false)
+		conn.Close(context.Background())
+
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("[Backplane Postgres] Conexão do listener perdida, reconectando: %v", err)
+		p.sleep(postgresReconnectDelay)
+	}
+}
+
+// listen processa notificações até a conexão falhar ou o backplane fechar
+// Na reconexão todos os canais são inscritos novamente
+func (p *Postgres) listen(conn *pgx.Conn) error {
+	listening := make(map[string]bool)
+
+	for {
+		if err := p.syncChannels(conn, listening); err != nil {
+			return err
+		}
+
+		// Acorda a espera quando o conjunto de canais mudar
+		waitCtx, cancel := context.WithCancel(p.ctx)
+		go func() {
+			select {
+			case <-p.changed:
+				cancel()
+			case <-waitCtx.Done():
+			}
+		}()
+
+		notification, err := conn.WaitForNotification(waitCtx)
+		woken := waitCtx.Err() != nil
+		cancel()
+
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return p.ctx.Err()
+			}
+			if woken && !conn.IsClosed() {
+				continue
+			}
+			return err
+		}
+
+		p.dispatch(notification)
+	}
+}
+
+// syncChannels aplica LISTEN/UNLISTEN conforme os handlers registrados
+func (p *Postgres) syncChannels(conn *pgx.Conn, listening map[string]bool) error {
+	p.mu.RLock()
+	wanted := make(map[string]bool, len(p.handlers))
+	for channel := range p.handlers {
+		wanted[channel] = true
+	}
+	p.mu.RUnlock()
+
+	for channel := range wanted {
+		if listening[channel] {
+			continue
+		}
+		if _, err := conn.Exec(p.ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("erro ao executar LISTEN %s: %w", channel, err)
+		}
+		listening[channel] = true
+	}
+
+	for channel := range listening {
+		if wanted[channel] {
+			continue
+		}
+		if _, err := conn.Exec(p.ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("erro ao executar UNLISTEN %s: %w", channel, err)
+		}
+		delete(listening, channel)
+	}
+
+	return nil
+}
+
+// dispatch entrega uma notificação ao handler do canal
+func (p *Postgres) dispatch(notification *pgconn.Notification) {
+	var envelope postgresEnvelope
+	if err := json.Unmarshal([]byte(notification.Payload), &envelope); err != nil {
+		log.Printf("[Backplane Postgres] Erro ao deserializar evento: %v", err)
+		return
+	}
+
+	// Ignora eventos da própria instância
+	if envelope.InstanceID == p.instanceID {
+		return
+	}
+
+	p.mu.RLock()
+	handler := p.handlers[notification.Channel]
+	p.mu.RUnlock()
+
+	if handler == nil {
+		return
+	}
+
+	if err := handler(envelope.Payload); err != nil {
+		log.Printf("[Backplane Postgres] Erro ao processar evento: %v", err)
+	}
+}
+
+// sleep espera o intervalo ou o fechamento do backplane
+func (p *Postgres) sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-p.ctx.Done():
+	}
+}
+
+// IsConnected indica se a conexão de LISTEN está ativa
+func (p *Postgres) IsConnected() bool {
+	return p.connected.Load()
+}
+
+// BufferedCount retorna 0: o backplane Postgres não mantém buffer
+func (p *Postgres) BufferedCount() int {
+	return 0
+}
+
+// Close encerra o listener e o pool de conexões
+func (p *Postgres) Close() error {
+	p.cancel()
+	<-p.done
+	p.pool.Close()
+
+	log.Println("[Backplane Postgres] Backplane fechado")
+	return nil
+}
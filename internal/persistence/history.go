@@ -0,0 +1,152 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/redis"
+	"github.com/jackc/pgx/v5"
+)
+
+// Colunas lidas nas consultas de mensagens (na ordem de scanMessages)
+// O conteúdo de mensagens apagadas nunca é retornado
+const messageColumns = `message_id, COALESCE(instance_id, ''), created_at, room_name,
+			COALESCE(user_id, ''), COALESCE(username, ''),
+			CASE WHEN deleted_at IS NULL THEN payload ELSE 'null'::jsonb END,
+			metadata, edited_at, deleted_at`
+
+// scanMessages lê as linhas de uma consulta feita com messageColumns
+func scanMessages(rows pgx.Rows, capacity int) ([]*redis.StreamMessage, error) {
+	messages := make([]*redis.StreamMessage, 0, capacity)
+
+	for rows.Next() {
+		var msg redis.StreamMessage
+		var payloadJSON, metadataJSON []byte
+
+		err := rows.Scan(
+			&msg.MessageID,
+			&msg.InstanceID,
+			&msg.CreatedAt,
+			&msg.RoomName,
+			&msg.UserID,
+			&msg.Username,
+			&payloadJSON,
+			&metadataJSON,
+			&msg.EditedAt,
+			&msg.DeletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao scanear linha: %w", err)
+		}
+
+		// Deserializa payload
+		if len(payloadJSON) > 0 {
+			if err := json.Unmarshal(payloadJSON, &msg.Payload); err != nil {
+				return nil, fmt.Errorf("erro ao deserializar payload: %w", err)
+			}
+		}
+
+		// Deserializa metadata
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &msg.Metadata); err != nil {
+				return nil, fmt.Errorf("erro ao deserializar metadata: %w", err)
+			}
+		}
+
+		messages = append(messages, &msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao iterar linhas: %w", err)
+	}
+
+	return messages, nil
+}
+
+// GetMessage retorna uma mensagem de uma sala (nil se não existir)
+func (r *MessageRepository) GetMessage(roomName, messageID string) (*redis.StreamMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE room_name = $1 AND message_id = $2 AND NOT placeholder
+	`, roomName, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar mensagem: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows, 1)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+// GetMessagesBefore retorna até limit mensagens anteriores ao cursor
+// (created_at, message_id), em ordem cronológica
+// Cursor com horário zero retorna as mensagens mais recentes da sala
+func (r *MessageRepository) GetMessagesBefore(roomName string, before time.Time, beforeID string, limit int) ([]*redis.StreamMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var rows pgx.Rows
+	var err error
+	if before.IsZero() {
+		rows, err = r.pool.Query(ctx, `
+			SELECT `+messageColumns+`
+			FROM messages
+			WHERE room_name = $1 AND NOT placeholder
+			ORDER BY created_at DESC, message_id DESC
+			LIMIT $2
+		`, roomName, limit)
+	} else {
+		rows, err = r.pool.Query(ctx, `
+			SELECT `+messageColumns+`
+			FROM messages
+			WHERE room_name = $1 AND NOT placeholder AND (created_at, message_id) < ($2, $3)
+			ORDER BY created_at DESC, message_id DESC
+			LIMIT $4
+		`, roomName, before, beforeID, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar mensagens anteriores: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// A consulta ordena da mais nova para a mais antiga
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// GetMessagesAfter retorna até limit mensagens posteriores ao cursor
+// (created_at, message_id), em ordem cronológica
+func (r *MessageRepository) GetMessagesAfter(roomName string, after time.Time, afterID string, limit int) ([]*redis.StreamMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE room_name = $1 AND NOT placeholder AND (created_at, message_id) > ($2, $3)
+		ORDER BY created_at, message_id
+		LIMIT $4
+	`, roomName, after, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar mensagens posteriores: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows, limit)
+}
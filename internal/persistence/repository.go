@@ -3,18 +3,23 @@ package persistence
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/5ucr4m/go-socket/internal/redis"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // MessageRepository gerencia a persistência de mensagens no PostgreSQL
 type MessageRepository struct {
 	pool *pgxpool.Pool
+
+	// Configuração de idioma da busca textual (regconfig)
+	searchLanguage string
 }
 
 // NewMessageRepository cria um novo repositório de mensagens
@@ -35,124 +40,375 @@ func NewMessageRepository(postgresURL string) (*MessageRepository, error) {
 	log.Printf("[PostgreSQL] Conectado ao banco de dados")
 
 	return &MessageRepository{
-		pool: pool,
+		pool:           pool,
+		searchLanguage: DefaultSearchLanguage,
 	}, nil
 }
 
-// SaveBatch salva um lote de mensagens usando batch insert
+// SaveBatch salva um lote de mensagens ignorando as que já foram persistidas
+// Reprocessar um batch (ou o stream inteiro) não gera linhas duplicadas
+// Uma exclusão gravada antes da mensagem (placeholder) recebe o conteúdo e
+// continua apagada; mensagens que sofreram purge não são gravadas de novo
 func (r *MessageRepository) SaveBatch(messages []*redis.StreamMessage) error {
 	if len(messages) == 0 {
 		return nil
 	}
 
+	// Retry do produtor ou replay do stream trazem a mesma mensagem duas vezes
+	messages = uniqueEntries(messages, (*redis.StreamMessage).DedupKey)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Inicia transação
+	// Colunas em arrays paralelos para um único INSERT ... SELECT unnest
+	messageIDs := make([]string, len(messages))
+	instanceIDs := make([]string, len(messages))
+	languages := make([]string, len(messages))
+	roomNames := make([]string, len(messages))
+	userIDs := make([]string, len(messages))
+	usernames := make([]string, len(messages))
+	payloads := make([]string, len(messages))
+	metadatas := make([]string, len(messages))
+	createdAts := make([]time.Time, len(messages))
+
+	now := time.Now()
+	for i, msg := range messages {
+		if msg.DedupKey() == "" {
+			return &redis.MessageError{Err: fmt.Errorf("mensagem sem chave de deduplicação (room: %s)", msg.RoomName)}
+		}
+
+		// Serializa payload para JSONB
+		payloadJSON, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return &redis.MessageError{Err: fmt.Errorf("erro ao serializar payload: %w", err)}
+		}
+
+		// Serializa metadata para JSONB
+		metadataJSON, err := json.Marshal(msg.Metadata)
+		if err != nil {
+			return &redis.MessageError{Err: fmt.Errorf("erro ao serializar metadata: %w", err)}
+		}
+
+		messageIDs[i] = msg.DedupKey()
+		instanceIDs[i] = msg.InstanceID
+		languages[i] = r.searchLanguage
+		roomNames[i] = msg.RoomName
+		userIDs[i] = msg.UserID
+		usernames[i] = msg.Username
+		payloads[i] = string(payloadJSON)
+		metadatas[i] = string(metadataJSON)
+		createdAts[i] = msg.CreatedAt
+		if msg.CreatedAt.IsZero() {
+			// Entradas antigas do stream não têm horário de criação
+			createdAts[i] = now
+		}
+	}
+
+	query := `
+		INSERT INTO messages (message_id, instance_id, room_name, user_id, username, payload, metadata, created_at, search_language)
+		SELECT m.message_id, m.instance_id, m.room_name, m.user_id, m.username, m.payload, m.metadata, m.created_at, m.search_language::regconfig
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::jsonb[], $7::jsonb[], $8::timestamptz[], $9::text[])
+			AS m(message_id, instance_id, room_name, user_id, username, payload, metadata, created_at, search_language)
+		WHERE NOT EXISTS (SELECT 1 FROM purged_messages p WHERE p.message_id = m.message_id)
+		ON CONFLICT (message_id) DO UPDATE SET
+			instance_id = EXCLUDED.instance_id,
+			user_id = EXCLUDED.user_id,
+			username = EXCLUDED.username,
+			payload = EXCLUDED.payload,
+			metadata = EXCLUDED.metadata,
+			created_at = EXCLUDED.created_at,
+			search_language = EXCLUDED.search_language,
+			placeholder = FALSE
+		WHERE messages.placeholder
+	`
+
+	tag, err := r.pool.Exec(ctx, query, messageIDs, instanceIDs, roomNames, userIDs, usernames, payloads, metadatas, createdAts, languages)
+	if err != nil {
+		return fmt.Errorf("erro ao inserir batch: %w", err)
+	}
+
+	inserted := tag.RowsAffected()
+	if skipped := int64(len(messages)) - inserted; skipped > 0 {
+		log.Printf("[PostgreSQL] Batch de %d mensagens gravado (%d já persistidas ignoradas)", len(messages), skipped)
+		return nil
+	}
+
+	log.Printf("[PostgreSQL] Batch de %d mensagens gravado com sucesso", len(messages))
+	return nil
+}
+
+// SaveRevisions grava revisões de mensagens e atualiza o conteúdo atual
+// Revisões repetidas são ignoradas e uma revisão mais antiga nunca
+// sobrescreve uma mais recente
+func (r *MessageRepository) SaveRevisions(revisions []*redis.StreamMessage) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	revisionIDs := make([]string, len(revisions))
+	messageIDs := make([]string, len(revisions))
+	roomNames := make([]string, len(revisions))
+	editorIDs := make([]string, len(revisions))
+	editorNames := make([]string, len(revisions))
+	payloads := make([]string, len(revisions))
+	previousPayloads := make([]string, len(revisions))
+	editedAts := make([]time.Time, len(revisions))
+
+	// Revisão mais recente de cada mensagem, para atualizar a tabela messages
+	latest := make(map[string]int)
+
+	for i, rev := range revisions {
+		if rev.DedupKey() == "" || rev.MessageID == "" {
+			return &redis.MessageError{Err: fmt.Errorf("revisão sem ID (room: %s)", rev.RoomName)}
+		}
+
+		payloadJSON, err := json.Marshal(rev.Payload)
+		if err != nil {
+			return &redis.MessageError{Err: fmt.Errorf("erro ao serializar payload: %w", err)}
+		}
+
+		previousJSON, err := json.Marshal(rev.PreviousPayload)
+		if err != nil {
+			return &redis.MessageError{Err: fmt.Errorf("erro ao serializar payload anterior: %w", err)}
+		}
+
+		revisionIDs[i] = rev.DedupKey()
+		messageIDs[i] = rev.MessageID
+		roomNames[i] = rev.RoomName
+		editorIDs[i] = rev.UserID
+		editorNames[i] = rev.Username
+		payloads[i] = string(payloadJSON)
+		previousPayloads[i] = string(previousJSON)
+		editedAts[i] = rev.CreatedAt
+		if rev.CreatedAt.IsZero() {
+			editedAts[i] = time.Now()
+		}
+
+		if j, ok := latest[rev.MessageID]; !ok || editedAts[i].After(editedAts[j]) {
+			latest[rev.MessageID] = i
+		}
+	}
+
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("erro ao iniciar transação: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Usa COPY para inserção em massa (mais eficiente)
-	_, err = tx.CopyFrom(
-		ctx,
-		pgx.Identifier{"messages"},
-		[]string{"room_name", "user_id", "username", "payload", "metadata", "created_at"},
-		pgx.CopyFromSlice(len(messages), func(i int) ([]interface{}, error) {
-			msg := messages[i]
-
-			// Serializa payload para JSONB
-			payloadJSON, err := json.Marshal(msg.Payload)
-			if err != nil {
-				return nil, fmt.Errorf("erro ao serializar payload: %w", err)
-			}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO message_revisions (revision_id, message_id, room_name, editor_id, editor_name, payload, previous_payload, edited_at)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::jsonb[], $7::jsonb[], $8::timestamptz[])
+			AS r(revision_id, message_id, room_name, editor_id, editor_name, payload, previous_payload, edited_at)
+		WHERE NOT EXISTS (SELECT 1 FROM purged_messages p WHERE p.message_id = r.message_id)
+		ON CONFLICT (revision_id) DO NOTHING
+	`, revisionIDs, messageIDs, roomNames, editorIDs, editorNames, payloads, previousPayloads, editedAts)
+	if err != nil {
+		return fmt.Errorf("erro ao inserir revisões: %w", err)
+	}
 
-			// Serializa metadata para JSONB
-			metadataJSON, err := json.Marshal(msg.Metadata)
-			if err != nil {
-				return nil, fmt.Errorf("erro ao serializar metadata: %w", err)
-			}
+	updateIDs := make([]string, 0, len(latest))
+	updatePayloads := make([]string, 0, len(latest))
+	updateEditedAts := make([]time.Time, 0, len(latest))
+	for _, i := range latest {
+		updateIDs = append(updateIDs, messageIDs[i])
+		updatePayloads = append(updatePayloads, payloads[i])
+		updateEditedAts = append(updateEditedAts, editedAts[i])
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE messages m
+		SET payload = r.payload, edited_at = r.edited_at
+		FROM unnest($1::text[], $2::jsonb[], $3::timestamptz[]) AS r(message_id, payload, edited_at)
+		WHERE m.message_id = r.message_id
+		  AND (m.edited_at IS NULL OR m.edited_at < r.edited_at)
+	`, updateIDs, updatePayloads, updateEditedAts)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar mensagens editadas: %w", err)
+	}
 
-			return []interface{}{
-				msg.RoomName,
-				msg.UserID,
-				msg.Username,
-				payloadJSON,
-				metadataJSON,
-				time.Now(),
-			}, nil
-		}),
-	)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("erro ao commitar transação: %w", err)
+	}
 
+	log.Printf("[PostgreSQL] %d revisões gravadas", len(revisions))
+	return nil
+}
+
+// SaveDeletions marca mensagens como apagadas (tombstone)
+// O conteúdo é preservado para moderação até um purge
+func (r *MessageRepository) SaveDeletions(deletions []*redis.StreamMessage) error {
+	if len(deletions) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deletions = uniqueEntries(deletions, func(deletion *redis.StreamMessage) string { return deletion.MessageID })
+
+	messageIDs := make([]string, len(deletions))
+	roomNames := make([]string, len(deletions))
+	deletedBy := make([]string, len(deletions))
+	deletedAts := make([]time.Time, len(deletions))
+	for i, deletion := range deletions {
+		if deletion.MessageID == "" {
+			return &redis.MessageError{Err: fmt.Errorf("exclusão sem message_id (room: %s)", deletion.RoomName)}
+		}
+
+		messageIDs[i] = deletion.MessageID
+		roomNames[i] = deletion.RoomName
+		deletedBy[i] = deletion.UserID
+		deletedAts[i] = deletion.CreatedAt
+		if deletion.CreatedAt.IsZero() {
+			deletedAts[i] = time.Now()
+		}
+	}
+
+	// Se a mensagem ainda não foi gravada (outro consumidor, batch atrasado),
+	// fica um placeholder apagado que o SaveBatch preenche depois
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO messages (message_id, room_name, payload, created_at, deleted_at, deleted_by, placeholder)
+		SELECT d.message_id, d.room_name, '{}'::jsonb, d.deleted_at, d.deleted_at, NULLIF(d.deleted_by, ''), TRUE
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::timestamptz[]) AS d(message_id, room_name, deleted_by, deleted_at)
+		WHERE NOT EXISTS (SELECT 1 FROM purged_messages p WHERE p.message_id = d.message_id)
+		ON CONFLICT (message_id) DO UPDATE SET
+			deleted_at = EXCLUDED.deleted_at,
+			deleted_by = EXCLUDED.deleted_by
+		WHERE messages.deleted_at IS NULL
+	`, messageIDs, roomNames, deletedBy, deletedAts)
+	if err != nil {
+		return fmt.Errorf("erro ao marcar mensagens apagadas: %w", err)
+	}
+
+	log.Printf("[PostgreSQL] %d mensagens marcadas como apagadas", tag.RowsAffected())
+	return nil
+}
+
+// PurgeMessages apaga definitivamente mensagens e suas revisões
+// Os IDs ficam em purged_messages para que a mensagem não volte a ser gravada
+func (r *MessageRepository) PurgeMessages(messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Registra o purge antes de apagar: entradas atrasadas do stream não recriam a mensagem
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO purged_messages (message_id)
+		SELECT unnest($1::text[])
+		ON CONFLICT (message_id) DO NOTHING
+	`, messageIDs); err != nil {
+		return fmt.Errorf("erro ao registrar purge: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM message_revisions WHERE message_id = ANY($1)", messageIDs); err != nil {
+		return fmt.Errorf("erro ao apagar revisões: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM messages WHERE message_id = ANY($1)", messageIDs)
 	if err != nil {
-		return fmt.Errorf("erro ao executar COPY: %w", err)
+		return fmt.Errorf("erro ao apagar mensagens: %w", err)
 	}
 
-	// Commit da transação
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("erro ao commitar transação: %w", err)
 	}
 
-	log.Printf("[PostgreSQL] Batch de %d mensagens gravado com sucesso", len(messages))
+	log.Printf("[PostgreSQL] %d mensagens apagadas definitivamente", tag.RowsAffected())
 	return nil
 }
 
-// GetRecentMessages retorna as mensagens mais recentes de uma sala
-func (r *MessageRepository) GetRecentMessages(roomName string, limit int) ([]*redis.StreamMessage, error) {
+// GetRevisions retorna as revisões de uma mensagem em ordem cronológica
+func (r *MessageRepository) GetRevisions(roomName, messageID string) ([]*redis.StreamMessage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT room_name, user_id, username, payload, metadata
-		FROM messages
-		WHERE room_name = $1
-		ORDER BY created_at DESC
-		LIMIT $2
+		SELECT revision_id, message_id, room_name, COALESCE(editor_id, ''), COALESCE(editor_name, ''),
+			payload, previous_payload, edited_at
+		FROM message_revisions r
+		WHERE r.room_name = $1 AND r.message_id = $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM messages m
+			WHERE m.message_id = r.message_id AND m.deleted_at IS NOT NULL
+		  )
+		ORDER BY r.edited_at
 	`
 
-	rows, err := r.pool.Query(ctx, query, roomName, limit)
+	rows, err := r.pool.Query(ctx, query, roomName, messageID)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao buscar mensagens: %w", err)
+		return nil, fmt.Errorf("erro ao buscar revisões: %w", err)
 	}
 	defer rows.Close()
 
-	messages := make([]*redis.StreamMessage, 0, limit)
+	revisions := make([]*redis.StreamMessage, 0)
 
 	for rows.Next() {
-		var msg redis.StreamMessage
-		var payloadJSON, metadataJSON []byte
+		rev := redis.StreamMessage{Kind: redis.KindRevision}
+		var payloadJSON, previousJSON []byte
 
 		err := rows.Scan(
-			&msg.RoomName,
-			&msg.UserID,
-			&msg.Username,
+			&rev.RevisionID,
+			&rev.MessageID,
+			&rev.RoomName,
+			&rev.UserID,
+			&rev.Username,
 			&payloadJSON,
-			&metadataJSON,
+			&previousJSON,
+			&rev.CreatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("erro ao scanear linha: %w", err)
+			return nil, fmt.Errorf("erro ao scanear revisão: %w", err)
 		}
 
-		// Deserializa payload
-		if err := json.Unmarshal(payloadJSON, &msg.Payload); err != nil {
+		if err := json.Unmarshal(payloadJSON, &rev.Payload); err != nil {
 			return nil, fmt.Errorf("erro ao deserializar payload: %w", err)
 		}
-
-		// Deserializa metadata
-		if err := json.Unmarshal(metadataJSON, &msg.Metadata); err != nil {
-			return nil, fmt.Errorf("erro ao deserializar metadata: %w", err)
+		if err := json.Unmarshal(previousJSON, &rev.PreviousPayload); err != nil {
+			return nil, fmt.Errorf("erro ao deserializar payload anterior: %w", err)
 		}
 
-		messages = append(messages, &msg)
+		revisions = append(revisions, &rev)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("erro ao iterar linhas: %w", err)
+		return nil, fmt.Errorf("erro ao iterar revisões: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetRecentMessages retorna as mensagens mais recentes de uma sala
+func (r *MessageRepository) GetRecentMessages(roomName string, limit int) ([]*redis.StreamMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE room_name = $1 AND NOT placeholder
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.pool.Query(ctx, query, roomName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar mensagens: %w", err)
 	}
+	defer rows.Close()
 
-	return messages, nil
+	return scanMessages(rows, limit)
 }
 
 // GetStats retorna estatísticas do banco
@@ -162,9 +418,9 @@ func (r *MessageRepository) GetStats() (map[string]interface{}, error) {
 
 	stats := make(map[string]interface{})
 
-	// Total de mensagens
+	// Total de mensagens (placeholders ainda não são mensagens)
 	var totalMessages int64
-	err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM messages").Scan(&totalMessages)
+	err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM messages WHERE NOT placeholder").Scan(&totalMessages)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao contar mensagens: %w", err)
 	}
@@ -172,7 +428,7 @@ func (r *MessageRepository) GetStats() (map[string]interface{}, error) {
 
 	// Total de salas
 	var totalRooms int64
-	err = r.pool.QueryRow(ctx, "SELECT COUNT(DISTINCT room_name) FROM messages").Scan(&totalRooms)
+	err = r.pool.QueryRow(ctx, "SELECT COUNT(DISTINCT room_name) FROM messages WHERE NOT placeholder").Scan(&totalRooms)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao contar salas: %w", err)
 	}
@@ -198,7 +454,70 @@ func (r *MessageRepository) Close() {
 	log.Println("[PostgreSQL] Pool de conexões fechado")
 }
 
+// uniqueEntries mantém a primeira entrada de cada chave, na ordem do batch
+// ON CONFLICT DO UPDATE falha (cardinality_violation) se a mesma chave aparece
+// duas vezes no mesmo comando. Chaves vazias são mantidas para a validação
+func uniqueEntries(entries []*redis.StreamMessage, key func(*redis.StreamMessage) string) []*redis.StreamMessage {
+	seen := make(map[string]bool, len(entries))
+	unique := make([]*redis.StreamMessage, 0, len(entries))
+	for _, entry := range entries {
+		k := key(entry)
+		if k != "" {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		unique = append(unique, entry)
+	}
+	return unique
+}
+
 // ProcessBatch implementa a interface MessageProcessor
+// Mensagens são gravadas antes das revisões e exclusões do mesmo batch
 func (r *MessageRepository) ProcessBatch(messages []*redis.StreamMessage) error {
-	return r.SaveBatch(messages)
+	return classifyError(r.processBatch(messages))
+}
+
+// classifyError marca erros de dados (classe 22) e de constraint (classe 23)
+// do PostgreSQL como falhas da própria mensagem; os demais são de infraestrutura
+// Falhas de validação e serialização já saem como redis.MessageError
+func classifyError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")) {
+		return &redis.MessageError{Err: err}
+	}
+	return err
+}
+
+// processBatch grava as mensagens, revisões e exclusões de um batch
+func (r *MessageRepository) processBatch(messages []*redis.StreamMessage) error {
+	newMessages := make([]*redis.StreamMessage, 0, len(messages))
+	revisions := make([]*redis.StreamMessage, 0)
+	deletions := make([]*redis.StreamMessage, 0)
+	purges := make([]string, 0)
+
+	for _, msg := range messages {
+		switch msg.Kind {
+		case redis.KindRevision:
+			revisions = append(revisions, msg)
+		case redis.KindDeletion:
+			deletions = append(deletions, msg)
+		case redis.KindPurge:
+			purges = append(purges, msg.MessageID)
+		default:
+			newMessages = append(newMessages, msg)
+		}
+	}
+
+	if err := r.SaveBatch(newMessages); err != nil {
+		return err
+	}
+	if err := r.SaveRevisions(revisions); err != nil {
+		return err
+	}
+	if err := r.SaveDeletions(deletions); err != nil {
+		return err
+	}
+	return r.PurgeMessages(purges)
 }
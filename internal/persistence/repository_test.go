@@ -0,0 +1,83 @@
+package persistence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/5ucr4m/go-socket/internal/redis"
+)
+
+func TestProcessBatchRejectsInvalidEntries(t *testing.T) {
+	unencodable := map[string]interface{}{"canal": make(chan int)}
+
+	tests := []struct {
+		name  string
+		entry *redis.StreamMessage
+	}{
+		{"mensagem sem chave de deduplicação", &redis.StreamMessage{RoomName: "geral"}},
+		{"payload não serializável", &redis.StreamMessage{RoomName: "geral", MessageID: "m1", Payload: unencodable}},
+		{"metadata não serializável", &redis.StreamMessage{RoomName: "geral", MessageID: "m1", Metadata: unencodable}},
+		{"revisão sem message_id", &redis.StreamMessage{Kind: redis.KindRevision, RoomName: "geral", RevisionID: "r1", StreamID: "1-0"}},
+		{"revisão com payload não serializável", &redis.StreamMessage{Kind: redis.KindRevision, RoomName: "geral", RevisionID: "r1", MessageID: "m1", Payload: unencodable}},
+		{"revisão com payload anterior não serializável", &redis.StreamMessage{Kind: redis.KindRevision, RoomName: "geral", RevisionID: "r1", MessageID: "m1", PreviousPayload: unencodable}},
+		{"exclusão sem message_id", &redis.StreamMessage{Kind: redis.KindDeletion, RoomName: "geral", StreamID: "1-0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A validação acontece antes de qualquer acesso ao banco
+			repo := &MessageRepository{searchLanguage: DefaultSearchLanguage}
+
+			err := repo.ProcessBatch([]*redis.StreamMessage{tt.entry})
+			var messageErr *redis.MessageError
+			if !errors.As(err, &messageErr) {
+				t.Errorf("ProcessBatch = %v, want *redis.MessageError", err)
+			}
+		})
+	}
+}
+
+func TestUniqueEntriesReplayedBatch(t *testing.T) {
+	m1 := &redis.StreamMessage{RoomName: "geral", MessageID: "m1", StreamID: "1-0"}
+	m2 := &redis.StreamMessage{RoomName: "geral", MessageID: "m2", StreamID: "2-0"}
+	// Entrada devolvida da DLQ: outro ID no stream, mesma mensagem
+	m1Redriven := &redis.StreamMessage{RoomName: "geral", MessageID: "m1", StreamID: "9-0"}
+	legacy := &redis.StreamMessage{RoomName: "geral", StreamID: "3-0"}
+	noKey := &redis.StreamMessage{RoomName: "geral"}
+
+	batch := []*redis.StreamMessage{m1, m2, legacy}
+
+	tests := []struct {
+		name  string
+		batch []*redis.StreamMessage
+		want  []*redis.StreamMessage
+	}{
+		{"batch sem repetições", batch, batch},
+		{"mesmo batch duas vezes", append(append([]*redis.StreamMessage{}, batch...), batch...), batch},
+		{"mensagem devolvida da DLQ", []*redis.StreamMessage{m1, m2, m1Redriven}, []*redis.StreamMessage{m1, m2}},
+		{"chaves vazias seguem para a validação", []*redis.StreamMessage{noKey, m1, noKey}, []*redis.StreamMessage{noKey, m1, noKey}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uniqueEntries(tt.batch, (*redis.StreamMessage).DedupKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uniqueEntries = %d entradas, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestUniqueEntriesDeletions(t *testing.T) {
+	first := &redis.StreamMessage{Kind: redis.KindDeletion, MessageID: "m1", StreamID: "1-0"}
+	replayed := &redis.StreamMessage{Kind: redis.KindDeletion, MessageID: "m1", StreamID: "5-0"}
+	other := &redis.StreamMessage{Kind: redis.KindDeletion, MessageID: "m2", StreamID: "2-0"}
+
+	got := uniqueEntries([]*redis.StreamMessage{first, other, replayed, first}, func(deletion *redis.StreamMessage) string {
+		return deletion.MessageID
+	})
+	if want := []*redis.StreamMessage{first, other}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueEntries = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Configuração de idioma padrão da busca textual
+const DefaultSearchLanguage = "simple"
+
+// SearchQuery define uma busca textual nas mensagens persistidas
+type SearchQuery struct {
+	Text   string    // Termos no formato websearch ("frase exata", -excluir, OR)
+	Rooms  []string  // Salas onde buscar (obrigatório)
+	UserID string    // Autor (opcional)
+	From   time.Time // Início do período (opcional)
+	To     time.Time // Fim do período, exclusivo (opcional)
+	Limit  int
+	Offset int
+}
+
+// SearchResult representa uma mensagem encontrada
+type SearchResult struct {
+	MessageID string    `json:"messageId"`
+	Room      string    `json:"room"`
+	UserID    string    `json:"userId,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Snippet   string    `json:"snippet"` // Trecho em HTML escapado com os termos entre <mark></mark>
+	CreatedAt time.Time `json:"createdAt"`
+	Rank      float32   `json:"rank"`
+}
+
+// SetSearchLanguage define a configuração de idioma (regconfig) usada para
+// indexar novas mensagens e interpretar as buscas
+func (r *MessageRepository) SetSearchLanguage(language string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := r.pool.Exec(ctx, "SELECT $1::text::regconfig", language); err != nil {
+		return fmt.Errorf("configuração de busca inválida %q: %w", language, err)
+	}
+
+	r.searchLanguage = language
+	return nil
+}
+
+// escapedMessageSQL escapa o HTML do texto da mensagem antes do ts_headline,
+// para que o trecho só contenha as marcações <mark> adicionadas pela busca
+const escapedMessageSQL = `replace(replace(replace(replace(replace(
+				COALESCE(payload->>'message', ''),
+				'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// Search busca mensagens pelo texto, ordenadas por relevância
+// Mensagens apagadas nunca aparecem nos resultados
+func (r *MessageRepository) Search(query SearchQuery) ([]SearchResult, error) {
+	if len(query.Rooms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var from, to *time.Time
+	if !query.From.IsZero() {
+		from = &query.From
+	}
+	if !query.To.IsZero() {
+		to = &query.To
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT message_id, room_name, COALESCE(user_id, ''), COALESCE(username, ''), created_at,
+			ts_rank(search_vector, q),
+			ts_headline($1::text::regconfig, `+escapedMessageSQL+`, q,
+				'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2')
+		FROM messages, websearch_to_tsquery($1::text::regconfig, $2) q
+		WHERE search_vector @@ q
+		  AND deleted_at IS NULL
+		  AND room_name = ANY($3)
+		  AND ($4 = '' OR user_id = $4)
+		  AND ($5::timestamptz IS NULL OR created_at >= $5)
+		  AND ($6::timestamptz IS NULL OR created_at < $6)
+		ORDER BY 6 DESC, created_at DESC
+		LIMIT $7 OFFSET $8
+	`, r.searchLanguage, query.Text, query.Rooms, query.UserID, from, to, query.Limit, query.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar mensagens: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]SearchResult, 0, query.Limit)
+	for rows.Next() {
+		var result SearchResult
+		if err := rows.Scan(
+			&result.MessageID,
+			&result.Room,
+			&result.UserID,
+			&result.Username,
+			&result.CreatedAt,
+			&result.Rank,
+			&result.Snippet,
+		); err != nil {
+			return nil, fmt.Errorf("erro ao scanear resultado: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro ao iterar resultados: %w", err)
+	}
+
+	return results, nil
+}
@@ -3,6 +3,7 @@ package pubsub
 import (
 	"encoding/json"
 	"log"
+	"net"
 	"sync"
 	"time"
 
@@ -25,6 +26,9 @@ const (
 
 // Client representa um cliente WebSocket conectado
 type Client struct {
+	// Identificador único da conexão
+	id string
+
 	// Hub que gerencia este cliente
 	hub *Hub
 
@@ -37,6 +41,25 @@ type Client struct {
 	// Informações do usuário
 	userInfo map[string]interface{}
 
+	// Identidade definida pelo servidor (token verificado no upgrade)
+	// Quando true, o campo user enviado pelo cliente é ignorado
+	authenticated bool
+
+	// Decisões de sala delegadas ao serviço de autenticação (nil = apenas a política)
+	roomAuthorizer RoomAuthorizer
+
+	// Validade da credencial e timers de aviso/fechamento (zero = não expira)
+	expiresAt   time.Time
+	warnTimer   *time.Timer
+	expireTimer *time.Timer
+
+	// Endereço IP do cliente (limites de taxa por IP)
+	remoteIP string
+
+	// Eventos recusados por limite de taxa na janela atual
+	violations      int
+	violationsSince time.Time
+
 	// Salas às quais o cliente está subscrito
 	roomSubscriptions map[string]bool
 
@@ -49,9 +72,16 @@ type Client struct {
 
 // NewClient cria uma nova instância de Client
 func NewClient(hub *Hub, conn *websocket.Conn) *Client {
+	var remoteIP string
+	if conn != nil {
+		remoteIP, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
+	}
+
 	return &Client{
+		id:                generateMessageID(),
 		hub:               hub,
 		conn:              conn,
+		remoteIP:          remoteIP,
 		send:              make(chan []byte, 256),
 		userInfo:          make(map[string]interface{}),
 		roomSubscriptions: make(map[string]bool),
@@ -59,11 +89,68 @@ func NewClient(hub *Hub, conn *websocket.Conn) *Client {
 	}
 }
 
+// GetID retorna o identificador único da conexão
+func (c *Client) GetID() string {
+	return c.id
+}
+
 // SetUserInfo define as informações do usuário
 func (c *Client) SetUserInfo(userInfo map[string]interface{}) {
+	previousID := c.GetUserID()
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.userInfo = userInfo
+	c.mu.Unlock()
+
+	// Mantém o índice de usuários (usado por mensagens diretas) atualizado
+	if c.hub != nil && c.hub.roomManager != nil {
+		c.hub.roomManager.TrackUser(c, previousID)
+	}
+}
+
+// Authenticate define a identidade verificada pelo servidor
+// A partir daqui o campo user dos eventos do cliente é ignorado
+func (c *Client) Authenticate(userInfo map[string]interface{}) {
+	c.SetUserInfo(userInfo)
+
+	c.mu.Lock()
+	c.authenticated = true
+	c.mu.Unlock()
+}
+
+// SetRoomAuthorizer delega as decisões de acesso às salas desta conexão
+func (c *Client) SetRoomAuthorizer(authorizer RoomAuthorizer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roomAuthorizer = authorizer
+}
+
+// getRoomAuthorizer retorna o authorizer delegado da conexão (thread-safe)
+func (c *Client) getRoomAuthorizer() RoomAuthorizer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.roomAuthorizer
+}
+
+// IsAuthenticated indica se a identidade do cliente veio de um token verificado
+func (c *Client) IsAuthenticated() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.authenticated
+}
+
+// SetRemoteIP define o IP do cliente (ex.: informado pelo proxy reverso)
+func (c *Client) SetRemoteIP(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remoteIP = ip
+}
+
+// GetRemoteIP retorna o IP do cliente
+func (c *Client) GetRemoteIP() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.remoteIP
 }
 
 // GetUserInfo retorna as informações do usuário
@@ -98,8 +185,10 @@ func (c *Client) WritePump() {
 func (c *Client) readPump() {
 	defer func() {
 		// Remove cliente de todas as salas antes de desregistrar
+		c.stopExpiry()
 		if c.hub.roomManager != nil {
 			c.hub.roomManager.RemoveClientFromAllRooms(c)
+			c.hub.roomManager.UntrackUser(c)
 		}
 		c.hub.unregister <- c
 		c.conn.Close()
@@ -121,8 +210,6 @@ func (c *Client) readPump() {
 			break
 		}
 
-		log.Printf("Mensagem recebida: %s", rawMessage)
-
 		// Tenta desserializar como evento de sala
 		var event ClientEvent
 		if err := json.Unmarshal(rawMessage, &event); err != nil {
@@ -130,9 +217,28 @@ func (c *Client) readPump() {
 			continue
 		}
 
+		// O frame bruto não é logado: pode conter tokens (reauth) e conteúdo das mensagens
+		log.Printf("Evento recebido: %s (sala: %q)", event.Type, event.Room)
+
 		// Atualiza userInfo se fornecido no evento
-		if event.User != nil && len(event.User) > 0 {
-			c.SetUserInfo(event.User)
+		// Clientes autenticados não podem trocar de identidade
+		if event.User != nil && len(event.User) > 0 && !c.IsAuthenticated() {
+			if isSystemUser(event.User) {
+				log.Printf("Identidade reservada recusada: %v", event.User["id"])
+			} else {
+				c.SetUserInfo(event.User)
+			}
+		}
+
+		// Limites de taxa (eventos recusados não são processados)
+		if c.hub.roomManager != nil {
+			if allowed, disconnect := c.hub.roomManager.allowEvent(c, &event); !allowed {
+				if disconnect {
+					c.closePolicyViolation("limite de eventos excedido")
+					break
+				}
+				continue
+			}
 		}
 
 		// Processa o evento
@@ -161,26 +267,7 @@ func (c *Client) handleEvent(event *ClientEvent) {
 
 	case EventPublish:
 		// Cria payload estruturado
-		payload := event.Payload
-
-		// Se o payload for um map, verifica se tem message e type
-		if payloadMap, ok := event.Payload.(map[string]interface{}); ok {
-			if _, hasMessage := payloadMap["message"]; !hasMessage {
-				// Se não tem message, assume que o payload todo é a mensagem
-				payload = PayloadMessage{
-					Message: event.Payload,
-					Type:    "text", // default
-				}
-			}
-		} else {
-			// Se não for um map, envelopa como PayloadMessage
-			payload = PayloadMessage{
-				Message: event.Payload,
-				Type:    "text",
-			}
-		}
-
-		c.hub.roomManager.Publish(c, event.Room, payload)
+		c.hub.roomManager.Publish(c, event.Room, messagePayload(event.Payload))
 
 	case EventPresence:
 		c.hub.roomManager.AddPresence(c, event.Room)
@@ -201,6 +288,33 @@ func (c *Client) handleEvent(event *ClientEvent) {
 		// Edita uma mensagem existente
 		c.hub.roomManager.EditMessage(c, event.Room, event.MessageID, event.Payload)
 
+	case EventGetRevisions:
+		// Lista de revisões de uma mensagem
+		c.hub.roomManager.GetRevisions(c, event.Room, event.MessageID)
+
+	case EventFetchHistory:
+		// Página de histórico (memória ou PostgreSQL)
+		query := HistoryQuery{}
+		if event.Options != nil {
+			query.Before = event.Options.Before
+			query.After = event.Options.After
+			query.Limit = event.Options.Limit
+		}
+		c.hub.roomManager.FetchHistory(c, event.Room, query)
+
+	case EventSearch:
+		// Busca textual nas mensagens persistidas
+		c.hub.roomManager.Search(c, event.Room, event.Options)
+
+	case EventReauth:
+		// Renova a credencial sem reconectar
+		c.hub.roomManager.Reauthenticate(c, event.Token)
+
+	case EventDeleteMessage:
+		// Exclusão lógica (tombstone) ou definitiva com options.purge
+		purge := event.Options != nil && event.Options.Purge
+		c.hub.roomManager.DeleteMessage(c, event.Room, event.MessageID, purge)
+
 	default:
 		log.Printf("Tipo de evento desconhecido: %s", event.Type)
 	}
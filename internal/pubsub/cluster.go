@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/backplane"
+)
+
+// SetBackplane define o backplane usado para propagar eventos de sala
+// Cada instância só se inscreve nas salas em que tem clientes locais;
+// salas já existentes são inscritas imediatamente
+func (rm *RoomManager) SetBackplane(bp backplane.Backplane) {
+	rm.mu.Lock()
+	rm.backplane = bp
+	names := make([]string, 0, len(rm.rooms))
+	for name := range rm.rooms {
+		names = append(names, name)
+	}
+	rm.mu.Unlock()
+
+	for _, name := range names {
+		rm.syncRoomTopic(name)
+	}
+}
+
+// syncRoomTopic inscreve a instância nos eventos da sala enquanto ela existir
+// localmente e cancela a inscrição quando ela é removida
+// Chamado sem rm.mu; topicMu serializa as chamadas para que a última
+// sincronização sempre reflita o estado mais recente das salas
+func (rm *RoomManager) syncRoomTopic(roomName string) {
+	rm.topicMu.Lock()
+	defer rm.topicMu.Unlock()
+
+	rm.mu.RLock()
+	bp := rm.backplane
+	_, exists := rm.rooms[roomName]
+	rm.mu.RUnlock()
+
+	if bp == nil {
+		return
+	}
+
+	if exists {
+		if err := bp.Subscribe(backplane.RoomTopic(roomName), rm.HandleRemoteEvent); err != nil {
+			log.Printf("Erro ao inscrever instância na sala %s: %v", roomName, err)
+		}
+		return
+	}
+	if err := bp.Unsubscribe(backplane.RoomTopic(roomName)); err != nil {
+		log.Printf("Erro ao cancelar inscrição da instância na sala %s: %v", roomName, err)
+	}
+}
+
+// getBackplane retorna o backplane configurado (thread-safe)
+func (rm *RoomManager) getBackplane() backplane.Backplane {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.backplane
+}
+
+// publishRemote serializa e propaga um evento de sala para as demais instâncias
+func (rm *RoomManager) publishRemote(event *RoomEvent) {
+	bp := rm.getBackplane()
+	if bp == nil {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Erro ao serializar evento de sala: %v", err)
+		return
+	}
+
+	if err := bp.Publish(backplane.RoomTopic(event.Room), data); err != nil {
+		log.Printf("Erro ao propagar evento de sala %s (%s): %v", event.Room, event.Type, err)
+	}
+}
+
+// HandleRemoteEvent entrega um evento de sala vindo de outra instância
+// para os subscribers locais da sala
+func (rm *RoomManager) HandleRemoteEvent(data []byte) error {
+	var event RoomEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("erro ao deserializar evento de sala: %w", err)
+	}
+
+	// Sem subscribers locais, nada a entregar
+	room := rm.GetRoom(event.Room)
+	if room == nil {
+		return nil
+	}
+
+	switch event.Type {
+	case "message":
+		if event.Message != nil {
+			room.AddMessage(event.Message)
+		}
+
+	case "message_edited":
+		if event.Message != nil {
+			editedAt := time.Now()
+			if event.Message.EditedAt != nil {
+				editedAt = *event.Message.EditedAt
+			}
+			room.applyEdit(event.Message.ID, event.Message.Payload, editedAt)
+		}
+		if event.Revision != nil {
+			room.addRevision(event.Revision)
+		}
+
+	case "message_deleted":
+		rm.applyRemoteDelete(room, &event)
+
+	case "typing", "read_receipt":
+		// Apenas repassa o frame
+
+	case "user_joined", "user_left":
+		// Eventos de presença vão apenas para os clientes com presence
+		rm.deliverPresenceEvent(room, event.Type, event.User, event.Frame)
+		return nil
+
+	default:
+		return fmt.Errorf("tipo de evento de sala desconhecido: %s", event.Type)
+	}
+
+	rm.sendToClients(room.GetSubscribers(), event.Frame, nil)
+	return nil
+}
@@ -0,0 +1,345 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/backplane"
+)
+
+// frameTimeout é o tempo máximo de espera por um frame entregue pelo backplane
+const frameTimeout = 2 * time.Second
+
+// memoryDirectory simula o diretório de sessões compartilhado entre instâncias
+type memoryDirectory struct {
+	mu    sync.Mutex
+	users map[string]map[string]bool
+}
+
+// instanceDirectory é a visão do diretório de uma instância
+type instanceDirectory struct {
+	shared     *memoryDirectory
+	instanceID string
+}
+
+func (d *instanceDirectory) Register(userID string) error {
+	d.shared.mu.Lock()
+	defer d.shared.mu.Unlock()
+
+	if d.shared.users[userID] == nil {
+		d.shared.users[userID] = make(map[string]bool)
+	}
+	d.shared.users[userID][d.instanceID] = true
+	return nil
+}
+
+func (d *instanceDirectory) Unregister(userID string) error {
+	d.shared.mu.Lock()
+	defer d.shared.mu.Unlock()
+
+	delete(d.shared.users[userID], d.instanceID)
+	return nil
+}
+
+func (d *instanceDirectory) Lookup(userID string) ([]string, error) {
+	d.shared.mu.Lock()
+	defer d.shared.mu.Unlock()
+
+	instances := make([]string, 0)
+	for instanceID := range d.shared.users[userID] {
+		if instanceID != d.instanceID {
+			instances = append(instances, instanceID)
+		}
+	}
+	return instances, nil
+}
+
+// newTestCluster cria n instâncias ligadas pelo mesmo barramento em memória
+// e pelo mesmo diretório de sessões
+func newTestCluster(t *testing.T, n int) []*Hub {
+	t.Helper()
+
+	bus := backplane.NewMemoryBus()
+	directory := &memoryDirectory{users: make(map[string]map[string]bool)}
+
+	hubs := make([]*Hub, 0, n)
+	for i := 0; i < n; i++ {
+		instanceID := fmt.Sprintf("inst-%d", i)
+		hub, err := NewHubWithBackplane(backplane.NewMemory(bus, instanceID), instanceID)
+		if err != nil {
+			t.Fatalf("NewHubWithBackplane(%s): %v", instanceID, err)
+		}
+		hub.RoomManager().SetUserDirectory(&instanceDirectory{shared: directory, instanceID: instanceID})
+		t.Cleanup(func() { hub.Close() })
+		hubs = append(hubs, hub)
+	}
+	return hubs
+}
+
+// newTestClient cria uma conexão autenticada sem websocket
+func newTestClient(hub *Hub, userID string) *Client {
+	client := NewClient(hub, nil)
+	client.Authenticate(map[string]interface{}{"id": userID})
+	return client
+}
+
+// waitFrame aguarda um frame do tipo informado, ignorando os demais
+func waitFrame(t *testing.T, client *Client, frameType string) map[string]interface{} {
+	t.Helper()
+
+	timeout := time.After(frameTimeout)
+	for {
+		select {
+		case data := <-client.send:
+			var frame map[string]interface{}
+			if err := json.Unmarshal(data, &frame); err != nil {
+				t.Fatalf("frame inválido: %v", err)
+			}
+			if frame["type"] == frameType {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("cliente %s não recebeu frame %s", client.GetUserID(), frameType)
+			return nil
+		}
+	}
+}
+
+// expectNoFrame falha se um frame do tipo informado chegar dentro do prazo
+func expectNoFrame(t *testing.T, client *Client, frameType string, wait time.Duration) {
+	t.Helper()
+
+	timeout := time.After(wait)
+	for {
+		select {
+		case data := <-client.send:
+			var frame map[string]interface{}
+			if err := json.Unmarshal(data, &frame); err == nil && frame["type"] == frameType {
+				t.Fatalf("cliente %s recebeu frame %s inesperado: %s", client.GetUserID(), frameType, data)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestClusterRoomFanOut(t *testing.T) {
+	hubs := newTestCluster(t, 3)
+
+	sender := newTestClient(hubs[0], "alice")
+	local := newTestClient(hubs[0], "bob")
+	remote := newTestClient(hubs[1], "carol")
+	outsider := newTestClient(hubs[2], "dave")
+
+	for _, client := range []*Client{sender, local, remote} {
+		if err := client.hub.RoomManager().Subscribe(client, "geral", SubscribeOptions{}); err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+	}
+	if err := outsider.hub.RoomManager().Subscribe(outsider, "outra", SubscribeOptions{}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := hubs[0].RoomManager().Publish(sender, "geral", messagePayload("olá")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var messageID interface{}
+	for _, client := range []*Client{sender, local, remote} {
+		frame := waitFrame(t, client, "message")
+		if messageID == nil {
+			messageID = frame["messageId"]
+		}
+		if frame["messageId"] != messageID {
+			t.Errorf("%s recebeu messageId %v, want %v", client.GetUserID(), frame["messageId"], messageID)
+		}
+	}
+
+	// O backplane não devolve o evento à instância de origem
+	expectNoFrame(t, sender, "message", 100*time.Millisecond)
+	expectNoFrame(t, outsider, "message", 100*time.Millisecond)
+
+	// A instância remota guarda a mensagem no buffer da sala
+	room := hubs[1].RoomManager().GetRoom("geral")
+	if room == nil {
+		t.Fatal("sala geral não existe na instância remota")
+	}
+	history := room.GetHistory(10)
+	if len(history) != 1 || history[0].ID != messageID {
+		t.Errorf("histórico remoto = %d mensagens, want a mensagem %v", len(history), messageID)
+	}
+}
+
+func TestClusterRoomEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		frameType string
+		// senderLeaves tira o remetente da sala antes do envio
+		senderLeaves bool
+		send         func(rm *RoomManager, client *Client)
+	}{
+		{
+			name:      "read receipt",
+			frameType: "read_receipt",
+			send: func(rm *RoomManager, client *Client) {
+				rm.SendReadReceipt(client, "geral", "m01")
+			},
+		},
+		{
+			name:         "mensagem de sistema sem clientes locais",
+			frameType:    "message",
+			senderLeaves: true,
+			send: func(rm *RoomManager, client *Client) {
+				if _, err := rm.PublishSystem("geral", "manutenção", map[string]interface{}{"id": SystemIDPrefix + "ops"}); err != nil {
+					t.Errorf("PublishSystem: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubs := newTestCluster(t, 2)
+
+			sender := newTestClient(hubs[0], "alice")
+			remote := newTestClient(hubs[1], "bob")
+			if err := hubs[0].RoomManager().Subscribe(sender, "geral", SubscribeOptions{}); err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+			if err := hubs[1].RoomManager().Subscribe(remote, "geral", SubscribeOptions{}); err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+
+			rm := hubs[0].RoomManager()
+			if tt.senderLeaves {
+				rm.Unsubscribe(sender, "geral")
+			}
+
+			tt.send(rm, sender)
+			waitFrame(t, remote, tt.frameType)
+		})
+	}
+}
+
+func TestClusterUnsubscribeStopsFanOut(t *testing.T) {
+	hubs := newTestCluster(t, 2)
+
+	sender := newTestClient(hubs[0], "alice")
+	remote := newTestClient(hubs[1], "bob")
+	if err := hubs[0].RoomManager().Subscribe(sender, "geral", SubscribeOptions{}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := hubs[1].RoomManager().Subscribe(remote, "geral", SubscribeOptions{}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	// Último cliente saiu: a instância remota deixa o tópico da sala
+	hubs[1].RoomManager().Unsubscribe(remote, "geral")
+
+	if err := hubs[0].RoomManager().Publish(sender, "geral", messagePayload("olá")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	waitFrame(t, sender, "message")
+	expectNoFrame(t, remote, "message", 100*time.Millisecond)
+}
+
+func TestClusterDirectMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		to        string
+		wantFrame string
+	}{
+		{"destinatário em outra instância", "bob", "direct_message"},
+		{"destinatário offline", "ninguem", "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubs := newTestCluster(t, 3)
+
+			sender := newTestClient(hubs[0], "alice")
+			recipients := []*Client{newTestClient(hubs[1], "bob"), newTestClient(hubs[2], "bob")}
+
+			hubs[0].RoomManager().SendDirectMessage(sender, tt.to, "oi")
+
+			if tt.wantFrame == "error" {
+				waitFrame(t, sender, "error")
+				return
+			}
+
+			// O destinatário recebe em todas as conexões, em qualquer instância
+			for _, recipient := range recipients {
+				frame := waitFrame(t, recipient, tt.wantFrame)
+				if frame["payload"] != "oi" {
+					t.Errorf("payload = %v, want oi", frame["payload"])
+				}
+			}
+			expectNoFrame(t, sender, "error", 100*time.Millisecond)
+		})
+	}
+}
+
+// slowBackplane segura a inscrição no tópico de uma sala até release ser fechado
+type slowBackplane struct {
+	backplane.Backplane
+	topic   string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *slowBackplane) Subscribe(topic string, handler backplane.Handler) error {
+	if topic == b.topic {
+		close(b.entered)
+		<-b.release
+	}
+	return b.Backplane.Subscribe(topic, handler)
+}
+
+func TestRoomTopicSubscribeRunsOutsideLock(t *testing.T) {
+	bp := &slowBackplane{
+		Backplane: backplane.NewMemory(backplane.NewMemoryBus(), "inst-0"),
+		topic:     backplane.RoomTopic("lenta"),
+		entered:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	hub, err := NewHubWithBackplane(bp, "inst-0")
+	if err != nil {
+		t.Fatalf("NewHubWithBackplane: %v", err)
+	}
+	t.Cleanup(func() { hub.Close() })
+	rm := hub.RoomManager()
+
+	client := newTestClient(hub, "alice")
+	if err := rm.Subscribe(client, "geral", SubscribeOptions{}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	created := make(chan struct{})
+	go func() {
+		rm.GetOrCreateRoom("lenta")
+		close(created)
+	}()
+	<-bp.entered
+
+	// Com o backplane parado, as salas existentes continuam respondendo
+	done := make(chan struct{})
+	go func() {
+		rm.GetRoom("lenta")
+		rm.ListRooms()
+		rm.Publish(client, "geral", messagePayload("olá"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(frameTimeout):
+		close(bp.release)
+		t.Fatal("RoomManager travado pela inscrição no backplane")
+	}
+	waitFrame(t, client, "message")
+
+	close(bp.release)
+	<-created
+}
@@ -0,0 +1,204 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Close code enviado quando a credencial expira sem reauth
+	CloseCredentialsExpired = 4001
+
+	// Antecedência padrão do aviso token_expiring
+	DefaultExpiryWarning = time.Minute
+)
+
+// Credential é a credencial verificada de uma conexão
+type Credential interface {
+	RoomAuthorizer
+
+	// UserInfo retorna o perfil do usuário
+	UserInfo() map[string]interface{}
+
+	// Expiry retorna o fim da validade (zero = não expira)
+	Expiry() time.Time
+}
+
+// Reauthenticator verifica o token enviado pelo evento reauth
+type Reauthenticator interface {
+	Reauthenticate(token string) (Credential, error)
+}
+
+// SetReauthenticator habilita o evento reauth
+// warning é a antecedência do aviso token_expiring
+func (rm *RoomManager) SetReauthenticator(reauth Reauthenticator, warning time.Duration) {
+	if warning <= 0 {
+		warning = DefaultExpiryWarning
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.reauth = reauth
+	rm.expiryWarning = warning
+}
+
+// reauthenticator retorna o verificador de reauth e a antecedência do aviso (thread-safe)
+func (rm *RoomManager) reauthenticator() (Reauthenticator, time.Duration) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if rm.expiryWarning <= 0 {
+		return rm.reauth, DefaultExpiryWarning
+	}
+	return rm.reauth, rm.expiryWarning
+}
+
+// SetCredential define a identidade da conexão a partir de uma credencial
+// e agenda o aviso de expiração e o fechamento da conexão
+func (c *Client) SetCredential(credential Credential) {
+	c.Authenticate(credential.UserInfo())
+	c.SetRoomAuthorizer(credential)
+	c.scheduleExpiry(credential.Expiry())
+}
+
+// scheduleExpiry troca os timers de expiração da conexão
+func (c *Client) scheduleExpiry(expiresAt time.Time) {
+	// Lido antes de travar c.mu: rm.mu nunca é travado dentro de c.mu
+	warning := DefaultExpiryWarning
+	if c.hub != nil && c.hub.roomManager != nil {
+		_, warning = c.hub.roomManager.reauthenticator()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopExpiryLocked()
+	c.expiresAt = expiresAt
+	if expiresAt.IsZero() {
+		return
+	}
+
+	c.warnTimer = time.AfterFunc(time.Until(expiresAt.Add(-warning)), func() {
+		c.sendTokenExpiring(expiresAt)
+	})
+	c.expireTimer = time.AfterFunc(time.Until(expiresAt), func() {
+		c.closeExpired(expiresAt)
+	})
+}
+
+// stopExpiry cancela os timers de expiração (conexão encerrada)
+func (c *Client) stopExpiry() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopExpiryLocked()
+}
+
+// stopExpiryLocked cancela os timers; deve ser chamado com c.mu travado
+func (c *Client) stopExpiryLocked() {
+	if c.warnTimer != nil {
+		c.warnTimer.Stop()
+		c.warnTimer = nil
+	}
+	if c.expireTimer != nil {
+		c.expireTimer.Stop()
+		c.expireTimer = nil
+	}
+}
+
+// sendTokenExpiring avisa o cliente que a credencial vai expirar
+func (c *Client) sendTokenExpiring(expiresAt time.Time) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "token_expiring",
+		"expiresAt": expiresAt,
+		"expiresIn": int(time.Until(expiresAt).Seconds()),
+	})
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+// closeExpired fecha a conexão se a credencial não foi renovada
+func (c *Client) closeExpired(expiresAt time.Time) {
+	c.mu.RLock()
+	renewed := !c.expiresAt.Equal(expiresAt)
+	c.mu.RUnlock()
+	if renewed {
+		return
+	}
+
+	log.Printf("Credencial de %s expirou sem reauth, fechando conexão", c.GetUserID())
+	message := websocket.FormatCloseMessage(CloseCredentialsExpired, "credenciais expiradas")
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+	c.conn.Close()
+}
+
+// Reauthenticate renova a credencial de uma conexão com um token novo
+// O token precisa identificar o mesmo usuário; salas que deixaram de ser
+// permitidas são abandonadas
+func (rm *RoomManager) Reauthenticate(client *Client, token string) {
+	reauth, _ := rm.reauthenticator()
+	if reauth == nil {
+		sendReauthError(client, "reauth_unsupported", "Servidor sem autenticação configurada")
+		return
+	}
+
+	credential, err := reauth.Reauthenticate(token)
+	if err != nil {
+		log.Printf("Reauth recusado para %s: %v", client.GetUserID(), err)
+		sendReauthError(client, "reauth_failed", "Token inválido")
+		return
+	}
+
+	newID, _ := credential.UserInfo()["id"].(string)
+	if client.IsAuthenticated() && newID != client.GetUserID() {
+		log.Printf("Reauth de %s com token de outro usuário (%s)", client.GetUserID(), newID)
+		sendReauthError(client, "reauth_user_mismatch", "Token pertence a outro usuário")
+		return
+	}
+
+	client.SetCredential(credential)
+
+	// Reavalia as salas com a nova credencial
+	client.mu.RLock()
+	subscriptions := make([]string, 0, len(client.roomSubscriptions))
+	for roomName := range client.roomSubscriptions {
+		subscriptions = append(subscriptions, roomName)
+	}
+	client.mu.RUnlock()
+
+	for _, roomName := range subscriptions {
+		if !rm.allowedFor(client, roomName, ActionSubscribe) {
+			rm.Unsubscribe(client, roomName)
+			sendForbidden(client, roomName, ActionSubscribe)
+		}
+	}
+
+	frame := map[string]interface{}{
+		"type": "reauthenticated",
+		"user": client.GetUserInfo(),
+	}
+	if expiresAt := credential.Expiry(); !expiresAt.IsZero() {
+		frame["expiresAt"] = expiresAt
+	}
+	data, _ := json.Marshal(frame)
+	select {
+	case client.send <- data:
+	default:
+	}
+}
+
+// sendReauthError envia um erro de reauth ao cliente
+func sendReauthError(client *Client, code, reason string) {
+	errorData, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"code":  code,
+		"error": reason,
+	})
+	select {
+	case client.send <- errorData:
+	default:
+	}
+}
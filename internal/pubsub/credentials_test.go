@@ -0,0 +1,205 @@
+package pubsub
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testCredential libera as salas que casam com rooms (nil = todas)
+type testCredential struct {
+	user      map[string]interface{}
+	rooms     []string
+	expiresAt time.Time
+}
+
+func (c *testCredential) AuthorizeRoom(roomName, action string) (bool, error) {
+	if c.rooms == nil {
+		return true, nil
+	}
+	for _, pattern := range c.rooms {
+		if matched, _ := path.Match(pattern, roomName); matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *testCredential) UserInfo() map[string]interface{} { return c.user }
+
+func (c *testCredential) Expiry() time.Time { return c.expiresAt }
+
+// testReauthenticator aceita apenas os tokens conhecidos
+type testReauthenticator map[string]*testCredential
+
+func (r testReauthenticator) Reauthenticate(token string) (Credential, error) {
+	credential, ok := r[token]
+	if !ok {
+		return nil, errors.New("token desconhecido")
+	}
+	return credential, nil
+}
+
+func TestReauthenticate(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		wantCode  string
+		wantUser  string
+		wantRooms []string
+	}{
+		{"token inválido", "expirado", "reauth_failed", "alice", []string{"geral", "order:1"}},
+		{"token de outro usuário", "bob", "reauth_user_mismatch", "alice", []string{"geral", "order:1"}},
+		{"mesmas salas", "alice", "", "alice", []string{"geral", "order:1"}},
+		{"salas reavaliadas", "alice-restrito", "", "alice", []string{"order:1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			rm := hub.RoomManager()
+			rm.SetReauthenticator(testReauthenticator{
+				"alice":          {user: map[string]interface{}{"id": "alice"}},
+				"alice-restrito": {user: map[string]interface{}{"id": "alice"}, rooms: []string{"order:*"}},
+				"bob":            {user: map[string]interface{}{"id": "bob"}},
+			}, 0)
+
+			client := NewClient(hub, nil)
+			client.SetCredential(&testCredential{user: map[string]interface{}{"id": "alice"}})
+			for _, room := range []string{"geral", "order:1"} {
+				if err := rm.Subscribe(client, room, SubscribeOptions{}); err != nil {
+					t.Fatalf("Subscribe: %v", err)
+				}
+			}
+
+			rm.Reauthenticate(client, tt.token)
+
+			if tt.wantCode != "" {
+				frame := waitFrame(t, client, "error")
+				if frame["code"] != tt.wantCode {
+					t.Errorf("code = %v, want %s", frame["code"], tt.wantCode)
+				}
+			} else {
+				frame := waitFrame(t, client, "reauthenticated")
+				if user, _ := frame["user"].(map[string]interface{}); user["id"] != tt.wantUser {
+					t.Errorf("user = %v, want %s", frame["user"], tt.wantUser)
+				}
+			}
+
+			if got := client.GetUserID(); got != tt.wantUser {
+				t.Errorf("usuário = %s, want %s", got, tt.wantUser)
+			}
+			for _, room := range []string{"geral", "order:1"} {
+				want := false
+				for _, kept := range tt.wantRooms {
+					want = want || kept == room
+				}
+				client.mu.RLock()
+				got := client.roomSubscriptions[room]
+				client.mu.RUnlock()
+				if got != want {
+					t.Errorf("inscrito em %s = %v, want %v", room, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReauthenticateWithoutAuthenticator(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, "alice")
+
+	hub.RoomManager().Reauthenticate(client, "qualquer")
+
+	if frame := waitFrame(t, client, "error"); frame["code"] != "reauth_unsupported" {
+		t.Errorf("code = %v, want reauth_unsupported", frame["code"])
+	}
+}
+
+// dialExpiring conecta um cliente cuja credencial expira em expiresIn
+// e retorna a conexão do lado do navegador e o Client do servidor
+func dialExpiring(t *testing.T, hub *Hub, expiresIn time.Duration) (*websocket.Conn, *Client) {
+	t.Helper()
+
+	clients := make(chan *Client, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn)
+		client.SetCredential(&testCredential{
+			user:      map[string]interface{}{"id": "alice"},
+			expiresAt: time.Now().Add(expiresIn),
+		})
+		go client.WritePump()
+		clients <- client
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client := <-clients
+	t.Cleanup(client.stopExpiry)
+	return conn, client
+}
+
+func TestCredentialExpiryClosesConnection(t *testing.T) {
+	tests := []struct {
+		name      string
+		reauth    bool
+		wantClose bool
+	}{
+		{"sem reauth fecha com 4001", false, true},
+		{"reauth antes do prazo mantém a conexão", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			rm := hub.RoomManager()
+			rm.SetReauthenticator(testReauthenticator{
+				"renovado": {user: map[string]interface{}{"id": "alice"}, expiresAt: time.Now().Add(time.Hour)},
+			}, 100*time.Millisecond)
+
+			conn, client := dialExpiring(t, hub, 200*time.Millisecond)
+
+			// O aviso chega antes da expiração
+			conn.SetReadDeadline(time.Now().Add(frameTimeout))
+			if _, data, err := conn.ReadMessage(); err != nil || !strings.Contains(string(data), `"token_expiring"`) {
+				t.Fatalf("aviso token_expiring não recebido: %s, %v", data, err)
+			}
+
+			if tt.reauth {
+				rm.Reauthenticate(client, "renovado")
+			}
+
+			// Lê até o fechamento ou até passar do prazo original
+			conn.SetReadDeadline(time.Now().Add(400 * time.Millisecond))
+			var err error
+			for err == nil {
+				_, _, err = conn.ReadMessage()
+			}
+
+			var closeErr *websocket.CloseError
+			closed := errors.As(err, &closeErr)
+			if closed != tt.wantClose {
+				t.Fatalf("fechamento = %v (%v), want %v", closed, err, tt.wantClose)
+			}
+			if closed && closeErr.Code != CloseCredentialsExpired {
+				t.Errorf("close code = %d, want %d", closeErr.Code, CloseCredentialsExpired)
+			}
+		})
+	}
+}
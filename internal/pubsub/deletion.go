@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	redisAdapter "github.com/5ucr4m/go-socket/internal/redis"
+)
+
+// DeleteMessage apaga uma mensagem de uma sala
+// Por padrão deixa um tombstone (sem conteúdo); purge remove a mensagem
+// definitivamente da memória, do histórico compartilhado e do PostgreSQL
+// Mensagens fora da memória (sala inativa ou histórico antigo) são buscadas no arquivo
+func (rm *RoomManager) DeleteMessage(client *Client, roomName, messageID string, purge bool) error {
+	// Apagar exige a mesma permissão de editar
+	if !rm.authorize(client, roomName, ActionEdit) {
+		return nil
+	}
+
+	room := rm.GetRoom(roomName)
+	original, inMemory := rm.findMessage(room, roomName, messageID)
+	if original == nil {
+		log.Printf("Mensagem não encontrada para exclusão: %s", messageID)
+		sendMessageError(client, "message_not_found", "Mensagem não encontrada", messageID)
+		return nil
+	}
+
+	if code, reason := rm.checkDelete(client, roomName, original, purge); code != "" {
+		log.Printf("Exclusão da mensagem %s negada para %s: %s", messageID, client.GetUserID(), code)
+		sendMessageError(client, code, reason, messageID)
+		return nil
+	}
+
+	deletedAt := time.Now()
+	deletedBy := client.GetUserInfo()
+
+	var tombstone *RoomMessage
+	if purge {
+		if inMemory {
+			room.removeMessage(messageID)
+		}
+		rm.removeHistory(roomName, messageID)
+		// Outras instâncias só precisam do ID para remover a mensagem
+		tombstone = &RoomMessage{ID: messageID}
+	} else {
+		if inMemory {
+			tombstone, inMemory = room.applyDelete(messageID, deletedAt)
+		}
+		if tombstone == nil {
+			tombstone = deletedCopy(original, deletedAt)
+		}
+		rm.updateHistory(roomName, tombstone)
+	}
+	rm.enqueueDeletion(roomName, messageID, deletedBy, deletedAt, purge)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      "message_deleted",
+		"room":      roomName,
+		"messageId": messageID,
+		"user":      original.User,
+		"deletedBy": deletedBy,
+		"metadata": map[string]interface{}{
+			"room":      roomName,
+			"createdAt": original.CreatedAt,
+			"deletedAt": deletedAt,
+			"isDeleted": true,
+			"purged":    purge,
+		},
+	})
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem apagada: %v", err)
+		return err
+	}
+
+	if room != nil {
+		rm.sendToClients(room.GetSubscribers(), data, nil)
+	}
+
+	rm.publishRemote(&RoomEvent{
+		Type:    "message_deleted",
+		Room:    roomName,
+		Message: tombstone,
+		Purge:   purge,
+		Frame:   data,
+	})
+
+	log.Printf("Mensagem %s apagada na sala %s por %s (purge: %v)", messageID, roomName, client.GetUserID(), purge)
+
+	return nil
+}
+
+// findMessage busca a mensagem na memória da sala e, se não estiver lá, no arquivo
+// inMemory indica se a mensagem está no histórico em memória da sala
+func (rm *RoomManager) findMessage(room *Room, roomName, messageID string) (msg *RoomMessage, inMemory bool) {
+	if room != nil {
+		if msg, found := room.GetMessage(messageID); found {
+			return msg, true
+		}
+	}
+
+	archive := rm.messageArchive()
+	if archive == nil {
+		return nil, false
+	}
+	entry, err := archive.GetMessage(roomName, messageID)
+	if err != nil {
+		log.Printf("Erro ao buscar mensagem %s no arquivo: %v", messageID, err)
+		return nil, false
+	}
+	if entry == nil {
+		return nil, false
+	}
+	return streamMessageToRoomMessage(entry), false
+}
+
+// deletedCopy monta o tombstone de uma mensagem que não está na memória
+func deletedCopy(msg *RoomMessage, deletedAt time.Time) *RoomMessage {
+	tombstone := *msg
+	tombstone.Payload = nil
+	tombstone.IsDeleted = true
+	tombstone.DeletedAt = &deletedAt
+
+	tombstone.Metadata = make(map[string]interface{}, len(msg.Metadata)+2)
+	for k, v := range msg.Metadata {
+		tombstone.Metadata[k] = v
+	}
+	tombstone.Metadata["deletedAt"] = deletedAt
+	tombstone.Metadata["isDeleted"] = true
+	return &tombstone
+}
+
+// checkDelete verifica se o cliente pode apagar a mensagem
+// O autor e moderadores fazem exclusão lógica; purge é restrito a moderadores
+func (rm *RoomManager) checkDelete(client *Client, roomName string, msg *RoomMessage, purge bool) (string, string) {
+	moderator := isModerator(client.GetUserInfo(), roomName, rm.getEditPolicy().ModeratorRoles)
+
+	if purge {
+		if !moderator {
+			return "forbidden", "Apenas moderadores podem apagar mensagens definitivamente"
+		}
+		return "", ""
+	}
+
+	if msg.IsDeleted {
+		return "message_deleted", "Mensagem já foi apagada"
+	}
+
+	if !moderator && !isAuthor(client, msg) {
+		return "forbidden", "Apenas o autor ou moderadores podem apagar esta mensagem"
+	}
+
+	return "", ""
+}
+
+// applyRemoteDelete aplica uma exclusão vinda de outra instância
+func (rm *RoomManager) applyRemoteDelete(room *Room, event *RoomEvent) {
+	if event.Message == nil {
+		return
+	}
+
+	if event.Purge {
+		room.removeMessage(event.Message.ID)
+		return
+	}
+
+	deletedAt := time.Now()
+	if event.Message.DeletedAt != nil {
+		deletedAt = *event.Message.DeletedAt
+	}
+	room.applyDelete(event.Message.ID, deletedAt)
+}
+
+// enqueueDeletion enfileira uma exclusão (ou purge) para persistência
+func (rm *RoomManager) enqueueDeletion(roomName, messageID string, deletedBy map[string]interface{}, deletedAt time.Time, purge bool) {
+	queue := rm.messageQueue()
+	if queue == nil {
+		return
+	}
+
+	streamMsg := &redisAdapter.StreamMessage{
+		Kind:      redisAdapter.KindDeletion,
+		MessageID: messageID,
+		CreatedAt: deletedAt,
+		RoomName:  roomName,
+	}
+	if purge {
+		streamMsg.Kind = redisAdapter.KindPurge
+	}
+	if id, ok := deletedBy["id"].(string); ok {
+		streamMsg.UserID = id
+	}
+	if username, ok := deletedBy["username"].(string); ok {
+		streamMsg.Username = username
+	}
+
+	if err := queue.Publish(streamMsg); err != nil {
+		log.Printf("Erro ao enfileirar exclusão de %s para persistência: %v", messageID, err)
+	}
+}
+
+// sendMessageError envia ao cliente um erro referente a uma mensagem
+func sendMessageError(client *Client, code, reason, messageID string) {
+	errorData, _ := json.Marshal(map[string]interface{}{
+		"type":      "error",
+		"code":      code,
+		"error":     reason,
+		"messageId": messageID,
+	})
+	select {
+	case client.send <- errorData:
+	default:
+	}
+}
@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/5ucr4m/go-socket/internal/backplane"
+)
+
+// UserDirectory mapeia usuários para as instâncias onde estão conectados
+type UserDirectory interface {
+	// Register registra que o usuário tem conexões nesta instância
+	Register(userID string) error
+
+	// Unregister remove o usuário desta instância
+	Unregister(userID string) error
+
+	// Lookup retorna as outras instâncias onde o usuário está conectado
+	Lookup(userID string) ([]string, error)
+}
+
+// SetUserDirectory define o diretório de sessões usado para localizar
+// destinatários de mensagens diretas em outras instâncias
+// As mensagens são entregues pelo tópico da instância no backplane
+func (rm *RoomManager) SetUserDirectory(directory UserDirectory) {
+	rm.mu.Lock()
+	rm.directory = directory
+	users := make([]string, 0, len(rm.localUsers))
+	for userID := range rm.localUsers {
+		users = append(users, userID)
+	}
+	rm.mu.Unlock()
+
+	for _, userID := range users {
+		rm.syncDirectory(userID)
+	}
+}
+
+// TrackUser indexa o cliente pelo ID do usuário
+// previousID é o ID anterior do cliente (vazio se não havia)
+func (rm *RoomManager) TrackUser(client *Client, previousID string) {
+	userID := client.GetUserID()
+	if userID == previousID {
+		return
+	}
+
+	rm.mu.Lock()
+	releasedPrevious := rm.untrackUser(client, previousID)
+	registered := false
+	if userID != "" {
+		clients, exists := rm.localUsers[userID]
+		if !exists {
+			clients = make(map[*Client]bool)
+			rm.localUsers[userID] = clients
+			registered = true
+		}
+		clients[client] = true
+	}
+	rm.mu.Unlock()
+
+	// Diretório atualizado fora do lock: Redis lento não trava as salas
+	if releasedPrevious {
+		rm.syncDirectory(previousID)
+	}
+	if registered {
+		rm.syncDirectory(userID)
+	}
+}
+
+// UntrackUser remove o cliente do índice de usuários
+func (rm *RoomManager) UntrackUser(client *Client) {
+	userID := client.GetUserID()
+
+	rm.mu.Lock()
+	released := rm.untrackUser(client, userID)
+	rm.mu.Unlock()
+
+	if released {
+		rm.syncDirectory(userID)
+	}
+}
+
+// untrackUser remove o cliente do índice. Retorna true quando a última conexão
+// local do usuário saiu. Deve ser chamado com rm.mu travado
+func (rm *RoomManager) untrackUser(client *Client, userID string) bool {
+	clients, exists := rm.localUsers[userID]
+	if !exists {
+		return false
+	}
+
+	delete(clients, client)
+	if len(clients) > 0 {
+		return false
+	}
+
+	delete(rm.localUsers, userID)
+	return true
+}
+
+// syncDirectory registra ou remove o usuário do diretório conforme o índice local
+// Chamado sem rm.mu; directoryMu serializa as chamadas para que a última
+// sincronização sempre reflita o estado mais recente do índice
+func (rm *RoomManager) syncDirectory(userID string) {
+	rm.directoryMu.Lock()
+	defer rm.directoryMu.Unlock()
+
+	rm.mu.RLock()
+	directory := rm.directory
+	_, online := rm.localUsers[userID]
+	rm.mu.RUnlock()
+
+	if directory == nil {
+		return
+	}
+
+	if online {
+		if err := directory.Register(userID); err != nil {
+			log.Printf("Erro ao registrar usuário %s no diretório: %v", userID, err)
+		}
+		return
+	}
+	if err := directory.Unregister(userID); err != nil {
+		log.Printf("Erro ao remover usuário %s do diretório: %v", userID, err)
+	}
+}
+
+// getLocalClients retorna as conexões locais de um usuário (thread-safe)
+func (rm *RoomManager) getLocalClients(userID string) []*Client {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	clients := make([]*Client, 0, len(rm.localUsers[userID]))
+	for client := range rm.localUsers[userID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// routeDirectMessage envia o frame para as outras instâncias onde o
+// usuário está conectado. Retorna o número de instâncias alcançadas
+func (rm *RoomManager) routeDirectMessage(toUserID string, data []byte) int {
+	rm.mu.RLock()
+	directory := rm.directory
+	bp := rm.backplane
+	rm.mu.RUnlock()
+
+	if directory == nil || bp == nil {
+		return 0
+	}
+
+	instances, err := directory.Lookup(toUserID)
+	if err != nil {
+		log.Printf("Erro ao localizar usuário %s no diretório: %v", toUserID, err)
+		return 0
+	}
+
+	event, err := json.Marshal(&DirectEvent{
+		ToUserID: toUserID,
+		Frame:    data,
+	})
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem direta: %v", err)
+		return 0
+	}
+
+	delivered := 0
+	for _, instanceID := range instances {
+		if err := bp.Publish(backplane.InstanceTopic(instanceID), event); err != nil {
+			log.Printf("Erro ao encaminhar mensagem direta para %s: %v", instanceID, err)
+			continue
+		}
+		delivered++
+	}
+
+	return delivered
+}
+
+// HandleDirectEvent entrega uma mensagem direta vinda de outra instância
+// para as conexões locais do destinatário
+func (rm *RoomManager) HandleDirectEvent(data []byte) error {
+	var event DirectEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("erro ao deserializar mensagem direta: %w", err)
+	}
+
+	rm.sendToClients(rm.getLocalClients(event.ToUserID), event.Frame, nil)
+	return nil
+}
@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// HistoryStore mantém o histórico das salas compartilhado entre instâncias
+type HistoryStore interface {
+	// Append adiciona uma mensagem serializada ao histórico da sala
+	Append(roomName, messageID string, data []byte) error
+
+	// Update registra a versão mais recente de uma mensagem (edições)
+	Update(roomName, messageID string, data []byte) error
+
+	// Recent retorna as últimas mensagens da sala em ordem cronológica
+	Recent(roomName string, limit int) ([][]byte, error)
+
+	// Remove apaga definitivamente uma mensagem (purge)
+	Remove(roomName, messageID string) error
+}
+
+// SetHistoryStore define o armazenamento de histórico compartilhado
+func (rm *RoomManager) SetHistoryStore(store HistoryStore) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.history = store
+}
+
+// historyStore retorna o armazenamento de histórico configurado (thread-safe)
+func (rm *RoomManager) historyStore() HistoryStore {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.history
+}
+
+// appendHistory grava uma nova mensagem no histórico compartilhado
+func (rm *RoomManager) appendHistory(roomName string, msg *RoomMessage) {
+	store := rm.historyStore()
+	if store == nil {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem para o histórico: %v", err)
+		return
+	}
+
+	if err := store.Append(roomName, msg.ID, data); err != nil {
+		log.Printf("Erro ao gravar histórico da sala %s: %v", roomName, err)
+	}
+}
+
+// updateHistory grava a versão atualizada de uma mensagem no histórico compartilhado
+func (rm *RoomManager) updateHistory(roomName string, msg *RoomMessage) {
+	store := rm.historyStore()
+	if store == nil {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem para o histórico: %v", err)
+		return
+	}
+
+	if err := store.Update(roomName, msg.ID, data); err != nil {
+		log.Printf("Erro ao atualizar histórico da sala %s: %v", roomName, err)
+	}
+}
+
+// removeHistory apaga uma mensagem do histórico compartilhado
+func (rm *RoomManager) removeHistory(roomName, messageID string) {
+	store := rm.historyStore()
+	if store == nil {
+		return
+	}
+
+	if err := store.Remove(roomName, messageID); err != nil {
+		log.Printf("Erro ao remover mensagem do histórico da sala %s: %v", roomName, err)
+	}
+}
+
+// readHistory lê as últimas mensagens do histórico compartilhado
+func readHistory(store HistoryStore, roomName string, limit int) ([]*RoomMessage, error) {
+	entries, err := store.Recent(roomName, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*RoomMessage, 0, len(entries))
+	for _, data := range entries {
+		var msg RoomMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("Erro ao deserializar mensagem do histórico da sala %s: %v", roomName, err)
+			continue
+		}
+		messages = append(messages, &msg)
+	}
+
+	return messages, nil
+}
+
+// getHistory retorna o histórico da sala
+// Usa o armazenamento compartilhado e cai para a memória local em caso de erro
+func (rm *RoomManager) getHistory(room *Room, limit int) []*RoomMessage {
+	store := rm.historyStore()
+	if store == nil {
+		return room.GetHistory(limit)
+	}
+
+	messages, err := readHistory(store, room.name, limit)
+	if err != nil {
+		log.Printf("Erro ao ler histórico da sala %s, usando memória local: %v", room.name, err)
+		return room.GetHistory(limit)
+	}
+
+	return messages
+}
+
+// hydrateRoom carrega o histórico compartilhado em uma sala recém-criada
+// Chamado sem rm.mu (faz I/O no armazenamento compartilhado)
+func (rm *RoomManager) hydrateRoom(room *Room) {
+	store := rm.historyStore()
+	if store == nil {
+		return
+	}
+
+	messages, err := readHistory(store, room.name, room.maxHistorySize)
+	if err != nil {
+		log.Printf("Erro ao carregar histórico da sala %s: %v", room.name, err)
+		return
+	}
+
+	room.LoadHistory(messages)
+}
@@ -0,0 +1,319 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	redisAdapter "github.com/5ucr4m/go-socket/internal/redis"
+)
+
+const (
+	// Tamanho padrão e máximo de uma página de histórico
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 200
+)
+
+// HistoryQuery define uma página de histórico
+// Before e After são IDs de mensagem; sem cursor retorna as mais recentes
+type HistoryQuery struct {
+	Before string
+	After  string
+	Limit  int
+}
+
+// HistoryPage é uma página de histórico em ordem cronológica
+type HistoryPage struct {
+	Messages []*RoomMessage
+	HasMore  bool // Há mais mensagens na direção da consulta
+}
+
+// FetchHistory envia ao cliente uma página de histórico em um único frame
+func (rm *RoomManager) FetchHistory(client *Client, roomName string, query HistoryQuery) {
+	if !rm.authorize(client, roomName, ActionSubscribe) {
+		return
+	}
+
+	page, err := rm.GetHistoryPage(roomName, query)
+	if err != nil {
+		log.Printf("Erro ao buscar histórico da sala %s: %v", roomName, err)
+		errorData, _ := json.Marshal(map[string]interface{}{
+			"type":  "error",
+			"code":  "history_unavailable",
+			"error": "Histórico indisponível",
+			"room":  roomName,
+		})
+		select {
+		case client.send <- errorData:
+		default:
+		}
+		return
+	}
+
+	data, err := json.Marshal(HistoryPageFrame(roomName, query, page))
+	if err != nil {
+		log.Printf("Erro ao serializar página de histórico: %v", err)
+		return
+	}
+
+	select {
+	case client.send <- data:
+	default:
+		log.Printf("Cliente %p não pode receber histórico", client)
+	}
+}
+
+// HistoryPageFrame monta o frame history_page de uma página
+// O mesmo formato é usado pela API HTTP
+func HistoryPageFrame(roomName string, query HistoryQuery, page *HistoryPage) map[string]interface{} {
+	messages := make([]map[string]interface{}, 0, len(page.Messages))
+	for _, msg := range page.Messages {
+		messages = append(messages, map[string]interface{}{
+			"messageId": msg.ID,
+			"payload":   msg.Payload,
+			"user":      msg.User,
+			"metadata":  msg.Metadata,
+		})
+	}
+
+	frame := map[string]interface{}{
+		"type":     "history_page",
+		"room":     roomName,
+		"messages": messages,
+		"hasMore":  page.HasMore,
+	}
+	if query.Before != "" {
+		frame["before"] = query.Before
+	}
+	if query.After != "" {
+		frame["after"] = query.After
+	}
+	return frame
+}
+
+// GetHistoryPage monta uma página de histórico
+// Usa o buffer em memória da sala quando ele cobre a página inteira e
+// completa com o PostgreSQL quando a página vai além do buffer
+func (rm *RoomManager) GetHistoryPage(roomName string, query HistoryQuery) (*HistoryPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultHistoryPageSize
+	}
+	if limit > maxHistoryPageSize {
+		limit = maxHistoryPageSize
+	}
+
+	var memory []*RoomMessage
+	if room := rm.GetRoom(roomName); room != nil {
+		memory = room.GetHistory(0)
+	}
+	archive := rm.messageArchive()
+
+	if query.After != "" {
+		return rm.historyAfter(roomName, memory, archive, query.After, limit)
+	}
+	return rm.historyBefore(roomName, memory, archive, query.Before, limit)
+}
+
+// historyBefore retorna a página anterior ao cursor (ou a mais recente)
+func (rm *RoomManager) historyBefore(roomName string, memory []*RoomMessage, archive MessageArchive, before string, limit int) (*HistoryPage, error) {
+	// Posição do cursor no buffer (sem cursor: após a última mensagem)
+	end := len(memory)
+	if before != "" {
+		end = indexOfMessage(memory, before)
+	}
+
+	if end >= 0 {
+		start := end - limit
+		if start > 0 {
+			// A página inteira está em memória e há mais mensagens antes dela
+			return &HistoryPage{Messages: memory[start:end], HasMore: true}, nil
+		}
+		if archive == nil {
+			return &HistoryPage{Messages: memory[:end]}, nil
+		}
+
+		// Completa com as mensagens anteriores ao início do buffer
+		local := memory[:end]
+		need := limit - len(local)
+		var older []*RoomMessage
+		var hasMore bool
+		var err error
+		if len(memory) > 0 {
+			older, hasMore, err = archiveBefore(archive, roomName, memory[0].CreatedAt, memory[0].ID, need)
+		} else {
+			older, hasMore, err = archiveBefore(archive, roomName, time.Time{}, "", need)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &HistoryPage{Messages: mergeMessages(older, local), HasMore: hasMore}, nil
+	}
+
+	// Cursor fora do buffer: mensagem antiga, apenas no PostgreSQL
+	if archive == nil {
+		return &HistoryPage{Messages: []*RoomMessage{}}, nil
+	}
+	cursor, err := archive.GetMessage(roomName, before)
+	if err != nil || cursor == nil {
+		return &HistoryPage{Messages: []*RoomMessage{}}, err
+	}
+	older, hasMore, err := archiveBefore(archive, roomName, cursor.CreatedAt, cursor.MessageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryPage{Messages: older, HasMore: hasMore}, nil
+}
+
+// historyAfter retorna a página posterior ao cursor
+func (rm *RoomManager) historyAfter(roomName string, memory []*RoomMessage, archive MessageArchive, after string, limit int) (*HistoryPage, error) {
+	// O buffer sempre contém as mensagens mais recentes
+	if idx := indexOfMessage(memory, after); idx >= 0 {
+		newer := memory[idx+1:]
+		if len(newer) > limit {
+			return &HistoryPage{Messages: newer[:limit], HasMore: true}, nil
+		}
+		return &HistoryPage{Messages: newer}, nil
+	}
+
+	if archive == nil {
+		return &HistoryPage{Messages: []*RoomMessage{}}, nil
+	}
+	cursor, err := archive.GetMessage(roomName, after)
+	if err != nil || cursor == nil {
+		return &HistoryPage{Messages: []*RoomMessage{}}, err
+	}
+
+	entries, err := archive.GetMessagesAfter(roomName, cursor.CreatedAt, cursor.MessageID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	newer := make([]*RoomMessage, 0, len(entries))
+	for _, entry := range entries {
+		newer = append(newer, streamMessageToRoomMessage(entry))
+	}
+
+	// Mensagens recentes podem ainda não ter sido persistidas pelo worker
+	pending := make([]*RoomMessage, 0, len(memory))
+	for _, msg := range memory {
+		if isAfterCursor(msg, cursor.CreatedAt, cursor.MessageID) {
+			pending = append(pending, msg)
+		}
+	}
+	messages := mergeMessages(newer, pending)
+	if len(messages) > limit {
+		return &HistoryPage{Messages: messages[:limit], HasMore: true}, nil
+	}
+	return &HistoryPage{Messages: messages}, nil
+}
+
+// archiveBefore busca até limit mensagens anteriores ao cursor no PostgreSQL
+func archiveBefore(archive MessageArchive, roomName string, before time.Time, beforeID string, limit int) ([]*RoomMessage, bool, error) {
+	if limit <= 0 {
+		// Página completa com a memória; verifica se ainda há mensagens antes
+		entries, err := archive.GetMessagesBefore(roomName, before, beforeID, 1)
+		return []*RoomMessage{}, len(entries) > 0, err
+	}
+
+	entries, err := archive.GetMessagesBefore(roomName, before, beforeID, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[1:]
+	}
+
+	messages := make([]*RoomMessage, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, streamMessageToRoomMessage(entry))
+	}
+	return messages, hasMore, nil
+}
+
+// isAfterCursor compara (CreatedAt, ID) com o cursor, como no PostgreSQL
+func isAfterCursor(msg *RoomMessage, after time.Time, afterID string) bool {
+	createdAt := msg.CreatedAt.Truncate(time.Microsecond)
+	after = after.Truncate(time.Microsecond)
+	if createdAt.Equal(after) {
+		return msg.ID > afterID
+	}
+	return createdAt.After(after)
+}
+
+// indexOfMessage retorna a posição da mensagem no histórico (-1 se ausente)
+func indexOfMessage(messages []*RoomMessage, messageID string) int {
+	for i, msg := range messages {
+		if msg.ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeMessages junta listas cronológicas sem repetir mensagens
+// Em caso de repetição prevalece a versão da última lista (memória)
+func mergeMessages(lists ...[]*RoomMessage) []*RoomMessage {
+	byID := make(map[string]*RoomMessage)
+	for _, list := range lists {
+		for _, msg := range list {
+			byID[msg.ID] = msg
+		}
+	}
+
+	merged := make([]*RoomMessage, 0, len(byID))
+	for _, msg := range byID {
+		merged = append(merged, msg)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].ID < merged[j].ID
+		}
+		return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+	})
+	return merged
+}
+
+// streamMessageToRoomMessage converte uma mensagem persistida para RoomMessage
+func streamMessageToRoomMessage(entry *redisAdapter.StreamMessage) *RoomMessage {
+	user := map[string]interface{}{}
+	if entry.UserID != "" {
+		user["id"] = entry.UserID
+	}
+	if entry.Username != "" {
+		user["username"] = entry.Username
+	}
+
+	metadata := make(map[string]interface{}, len(entry.Metadata)+4)
+	for k, v := range entry.Metadata {
+		metadata[k] = v
+	}
+	metadata["room"] = entry.RoomName
+	metadata["createdAt"] = entry.CreatedAt
+
+	msg := &RoomMessage{
+		ID:        entry.MessageID,
+		User:      user,
+		Metadata:  metadata,
+		CreatedAt: entry.CreatedAt,
+		EditedAt:  entry.EditedAt,
+		IsEdited:  entry.EditedAt != nil,
+		DeletedAt: entry.DeletedAt,
+		IsDeleted: entry.DeletedAt != nil,
+	}
+	if entry.Payload != nil {
+		msg.Payload = entry.Payload
+	}
+	if msg.IsEdited {
+		metadata["editedAt"] = *entry.EditedAt
+		metadata["isEdited"] = true
+	}
+	if msg.IsDeleted {
+		metadata["deletedAt"] = *entry.DeletedAt
+		metadata["isDeleted"] = true
+	}
+
+	return msg
+}
@@ -0,0 +1,235 @@
+package pubsub
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/persistence"
+	redisAdapter "github.com/5ucr4m/go-socket/internal/redis"
+)
+
+// fakeArchive simula o PostgreSQL com mensagens em ordem cronológica
+type fakeArchive struct {
+	messages []*redisAdapter.StreamMessage
+}
+
+func (a *fakeArchive) GetRevisions(roomName, messageID string) ([]*redisAdapter.StreamMessage, error) {
+	return nil, nil
+}
+
+func (a *fakeArchive) GetMessage(roomName, messageID string) (*redisAdapter.StreamMessage, error) {
+	for _, msg := range a.messages {
+		if msg.MessageID == messageID {
+			return msg, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *fakeArchive) GetMessagesBefore(roomName string, before time.Time, beforeID string, limit int) ([]*redisAdapter.StreamMessage, error) {
+	older := make([]*redisAdapter.StreamMessage, 0)
+	for _, msg := range a.messages {
+		entry := &RoomMessage{ID: msg.MessageID, CreatedAt: msg.CreatedAt}
+		if !before.IsZero() && (entry.ID == beforeID || isAfterCursor(entry, before, beforeID)) {
+			continue
+		}
+		older = append(older, msg)
+	}
+	if len(older) > limit {
+		older = older[len(older)-limit:]
+	}
+	return older, nil
+}
+
+func (a *fakeArchive) GetMessagesAfter(roomName string, after time.Time, afterID string, limit int) ([]*redisAdapter.StreamMessage, error) {
+	newer := make([]*redisAdapter.StreamMessage, 0)
+	for _, msg := range a.messages {
+		if isAfterCursor(&RoomMessage{ID: msg.MessageID, CreatedAt: msg.CreatedAt}, after, afterID) {
+			newer = append(newer, msg)
+		}
+	}
+	if len(newer) > limit {
+		newer = newer[:limit]
+	}
+	return newer, nil
+}
+
+func (a *fakeArchive) Search(query persistence.SearchQuery) ([]persistence.SearchResult, error) {
+	return nil, nil
+}
+
+var historyBase = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// historyID retorna o ID da n-ésima mensagem da sala de teste (m01, m02, ...)
+func historyID(n int) string {
+	return fmt.Sprintf("m%02d", n)
+}
+
+// memoryRange monta o buffer em memória com as mensagens from..to
+func memoryRange(from, to int) []*RoomMessage {
+	messages := make([]*RoomMessage, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		messages = append(messages, &RoomMessage{ID: historyID(n), CreatedAt: historyBase.Add(time.Duration(n) * time.Second)})
+	}
+	return messages
+}
+
+// archiveRange monta o arquivo com as mensagens from..to
+func archiveRange(from, to int) *fakeArchive {
+	archive := &fakeArchive{}
+	for n := from; n <= to; n++ {
+		archive.messages = append(archive.messages, &redisAdapter.StreamMessage{
+			MessageID: historyID(n),
+			RoomName:  "geral",
+			CreatedAt: historyBase.Add(time.Duration(n) * time.Second),
+		})
+	}
+	return archive
+}
+
+// idsRange retorna os IDs from..to
+func idsRange(from, to int) []string {
+	ids := make([]string, 0)
+	for n := from; n <= to; n++ {
+		ids = append(ids, historyID(n))
+	}
+	return ids
+}
+
+func TestHistoryPage(t *testing.T) {
+	tests := []struct {
+		name        string
+		memory      []*RoomMessage
+		archive     *fakeArchive
+		query       HistoryQuery
+		wantIDs     []string
+		wantHasMore bool
+	}{
+		{
+			name:        "mais recentes só na memória",
+			memory:      memoryRange(1, 10),
+			query:       HistoryQuery{Limit: 3},
+			wantIDs:     idsRange(8, 10),
+			wantHasMore: true,
+		},
+		{
+			name:    "memória menor que a página, sem arquivo",
+			memory:  memoryRange(1, 4),
+			query:   HistoryQuery{Limit: 10},
+			wantIDs: idsRange(1, 4),
+		},
+		{
+			name:        "before completa a memória com o arquivo",
+			memory:      memoryRange(6, 10),
+			archive:     archiveRange(1, 10),
+			query:       HistoryQuery{Before: historyID(7), Limit: 3},
+			wantIDs:     idsRange(4, 6),
+			wantHasMore: true,
+		},
+		{
+			name:    "before fora da memória chega ao início",
+			memory:  memoryRange(6, 10),
+			archive: archiveRange(1, 10),
+			query:   HistoryQuery{Before: historyID(3), Limit: 5},
+			wantIDs: idsRange(1, 2),
+		},
+		{
+			name:    "before no início da memória sem mais no arquivo",
+			memory:  memoryRange(1, 10),
+			archive: archiveRange(1, 10),
+			query:   HistoryQuery{Before: historyID(4), Limit: 3},
+			wantIDs: idsRange(1, 3),
+		},
+		{
+			name:    "after na memória",
+			memory:  memoryRange(6, 10),
+			archive: archiveRange(1, 10),
+			query:   HistoryQuery{After: historyID(8), Limit: 5},
+			wantIDs: idsRange(9, 10),
+		},
+		{
+			name:        "after fora da memória",
+			memory:      memoryRange(6, 10),
+			archive:     archiveRange(1, 10),
+			query:       HistoryQuery{After: historyID(2), Limit: 3},
+			wantIDs:     idsRange(3, 5),
+			wantHasMore: true,
+		},
+		{
+			name:    "after inclui mensagens ainda não persistidas",
+			memory:  memoryRange(6, 10),
+			archive: archiveRange(1, 7),
+			query:   HistoryQuery{After: historyID(5), Limit: 10},
+			wantIDs: idsRange(6, 10),
+		},
+		{
+			name:    "cursor desconhecido",
+			memory:  memoryRange(6, 10),
+			archive: archiveRange(1, 10),
+			query:   HistoryQuery{Before: "inexistente", Limit: 3},
+			wantIDs: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := NewRoomManager(100)
+
+			var archive MessageArchive
+			if tt.archive != nil {
+				archive = tt.archive
+			}
+
+			var page *HistoryPage
+			var err error
+			if tt.query.After != "" {
+				page, err = rm.historyAfter("geral", tt.memory, archive, tt.query.After, tt.query.Limit)
+			} else {
+				page, err = rm.historyBefore("geral", tt.memory, archive, tt.query.Before, tt.query.Limit)
+			}
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+
+			ids := make([]string, 0, len(page.Messages))
+			for _, msg := range page.Messages {
+				ids = append(ids, msg.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("mensagens = %v, want %v", ids, tt.wantIDs)
+			}
+			if page.HasMore != tt.wantHasMore {
+				t.Errorf("hasMore = %v, want %v", page.HasMore, tt.wantHasMore)
+			}
+		})
+	}
+}
+
+func TestIsAfterCursor(t *testing.T) {
+	cursor := historyBase.Add(time.Second)
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		id        string
+		want      bool
+	}{
+		{"posterior", cursor.Add(time.Millisecond), "a", true},
+		{"anterior", cursor.Add(-time.Millisecond), "z", false},
+		{"mesmo horário, ID maior", cursor, "m05", true},
+		{"mesmo horário, ID menor", cursor, "m03", false},
+		{"o próprio cursor", cursor, "m04", false},
+		{"diferença abaixo do microssegundo (precisão do PostgreSQL)", cursor.Add(500 * time.Nanosecond), "m03", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &RoomMessage{ID: tt.id, CreatedAt: tt.createdAt}
+			if got := isAfterCursor(msg, cursor, "m04"); got != tt.want {
+				t.Errorf("isAfterCursor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// blockingHistory segura a leitura do histórico até release ser fechado
+type blockingHistory struct {
+	stored  [][]byte
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHistory) Append(roomName, messageID string, data []byte) error { return nil }
+
+func (h *blockingHistory) Update(roomName, messageID string, data []byte) error { return nil }
+
+func (h *blockingHistory) Remove(roomName, messageID string) error { return nil }
+
+func (h *blockingHistory) Recent(roomName string, limit int) ([][]byte, error) {
+	if roomName != "geral" {
+		return nil, nil
+	}
+	close(h.reading)
+	<-h.release
+	return h.stored, nil
+}
+
+func TestHydrationRunsOutsideRoomManagerLock(t *testing.T) {
+	stored, err := json.Marshal(&RoomMessage{ID: "antiga", Payload: "oi"})
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	store := &blockingHistory{
+		stored:  [][]byte{stored},
+		reading: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	rm := NewRoomManager(100)
+	rm.SetHistoryStore(store)
+
+	hydrated := make(chan *Room)
+	go func() { hydrated <- rm.GetOrCreateRoom("geral") }()
+	<-store.reading
+
+	// Com a leitura parada, as demais salas continuam respondendo
+	other := make(chan *Room)
+	go func() { other <- rm.GetOrCreateRoom("outra") }()
+	select {
+	case <-other:
+	case <-time.After(frameTimeout):
+		close(store.release)
+		t.Fatal("GetOrCreateRoom de outra sala travado pela carga do histórico")
+	}
+
+	// Mensagem recebida durante a carga não é descartada
+	room := rm.GetRoom("geral")
+	if room == nil {
+		close(store.release)
+		t.Fatal("sala não registrada antes da carga do histórico")
+	}
+	room.AddMessage(&RoomMessage{ID: "nova", Payload: "olá"})
+
+	close(store.release)
+	<-hydrated
+
+	history := room.GetHistory(10)
+	if len(history) != 2 || history[0].ID != "antiga" || history[1].ID != "nova" {
+		ids := make([]string, 0, len(history))
+		for _, msg := range history {
+			ids = append(ids, msg.ID)
+		}
+		t.Errorf("histórico = %v, want [antiga nova]", ids)
+	}
+}
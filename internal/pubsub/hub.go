@@ -3,8 +3,11 @@ package pubsub
 import (
 	"encoding/json"
 	"log"
+	"time"
 
+	"github.com/5ucr4m/go-socket/internal/backplane"
 	redisAdapter "github.com/5ucr4m/go-socket/internal/redis"
+	"github.com/redis/go-redis/v9"
 )
 
 // Hub mantém o conjunto de clientes ativos e faz broadcast de mensagens
@@ -24,13 +27,25 @@ type Hub struct {
 	// Gerenciador de salas
 	roomManager *RoomManager
 
-	// Redis Pub/Sub para sincronização entre instâncias
-	pubSubAdapter *redisAdapter.PubSubAdapter
+	// Cliente Redis compartilhado entre os componentes abaixo
+	redisClient redis.UniversalClient
+
+	// Backplane para sincronização entre instâncias (Redis, PostgreSQL ou memória)
+	backplane backplane.Backplane
 
 	// Redis Streams para persistência
 	streamProducer *redisAdapter.StreamProducer
+
+	// Registro de presença compartilhado entre instâncias
+	presenceRegistry *redisAdapter.PresenceRegistry
+
+	// Diretório usuário → instância para mensagens diretas
+	sessionDirectory *redisAdapter.SessionDirectory
 }
 
+// Histórico padrão de mensagens por sala
+const defaultHistorySize = 1000
+
 // NewHub cria uma nova instância do Hub
 func NewHub() *Hub {
 	return &Hub{
@@ -38,43 +53,111 @@ func NewHub() *Hub {
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
 		clients:     make(map[*Client]bool),
-		roomManager: NewRoomManager(1000), // Histórico padrão de 1000 mensagens
+		roomManager: NewRoomManager(defaultHistorySize),
 	}
 }
 
 // NewHubWithRedis cria um Hub com suporte a Redis
-func NewHubWithRedis(redisURL, instanceID string) (*Hub, error) {
+// Um único cliente (nó único, Sentinel ou Cluster) é compartilhado por todos os componentes
+// streamRetention define o trim do stream de persistência
+func NewHubWithRedis(redisConfig redisAdapter.ClientConfig, instanceID string, streamRetention redisAdapter.RetentionConfig) (*Hub, error) {
 	hub := NewHub()
 
-	// Inicializa Redis Pub/Sub
-	pubSubAdapter, err := redisAdapter.NewPubSubAdapter(redisURL, instanceID)
+	redisClient, err := redisAdapter.NewClient(redisConfig)
 	if err != nil {
 		return nil, err
 	}
-	hub.pubSubAdapter = pubSubAdapter
+	hub.redisClient = redisClient
+
+	// Inicializa Redis Pub/Sub como backplane
+	pubSubAdapter, err := redisAdapter.NewPubSubAdapter(redisClient, instanceID)
+	if err != nil {
+		hub.Close()
+		return nil, err
+	}
+	if err := hub.attachBackplane(pubSubAdapter, instanceID); err != nil {
+		hub.Close()
+		return nil, err
+	}
 
 	// Inicializa Redis Streams
-	streamProducer, err := redisAdapter.NewStreamProducer(redisURL)
+	streamProducer, err := redisAdapter.NewStreamProducer(redisClient, instanceID, streamRetention)
 	if err != nil {
+		hub.Close()
 		return nil, err
 	}
 	hub.streamProducer = streamProducer
+	hub.roomManager.SetMessageQueue(streamProducer)
 
-	// Se inscreve no canal de broadcast
-	err = pubSubAdapter.Subscribe(func(payload []byte) error {
-		// Broadcast local para clientes conectados nesta instância
-		log.Printf("[Hub] Mensagem recebida do Redis Pub/Sub, broadcasting para %d clientes locais", len(hub.clients))
-		hub.broadcastToLocalClients(payload)
-		return nil
-	})
+	// Histórico das salas em Redis Streams por sala
+	hub.roomManager.SetHistoryStore(redisAdapter.NewHistoryStore(redisClient, defaultHistorySize))
+
+	// Limites de taxa compartilhados entre instâncias
+	hub.roomManager.SetRateLimiter(redisAdapter.NewRateLimiter(redisClient))
+
+	// Inicializa registro de presença
+	presenceRegistry, err := redisAdapter.NewPresenceRegistry(redisClient, instanceID, redisAdapter.DefaultPresenceTTL)
 	if err != nil {
+		hub.Close()
 		return nil, err
 	}
+	hub.presenceRegistry = presenceRegistry
+	hub.roomManager.SetPresenceStore(presenceRegistry)
+
+	// Inicializa diretório de sessões para mensagens diretas
+	sessionDirectory, err := redisAdapter.NewSessionDirectory(redisClient, instanceID, redisAdapter.DefaultSessionTTL)
+	if err != nil {
+		hub.Close()
+		return nil, err
+	}
+	hub.sessionDirectory = sessionDirectory
+	hub.roomManager.SetUserDirectory(sessionDirectory)
 
 	log.Printf("[Hub] Hub inicializado com Redis (instance: %s)", instanceID)
 	return hub, nil
 }
 
+// NewHubWithBackplane cria um Hub sincronizado pelo backplane informado
+// Sem Redis não há presença compartilhada, diretório de sessões nem persistência
+func NewHubWithBackplane(bp backplane.Backplane, instanceID string) (*Hub, error) {
+	hub := NewHub()
+
+	if err := hub.attachBackplane(bp, instanceID); err != nil {
+		hub.Close()
+		return nil, err
+	}
+
+	log.Printf("[Hub] Hub inicializado com backplane (instance: %s)", instanceID)
+	return hub, nil
+}
+
+// attachBackplane conecta o Hub e o RoomManager ao backplane
+func (h *Hub) attachBackplane(bp backplane.Backplane, instanceID string) error {
+	h.backplane = bp
+
+	// Se inscreve no tópico de broadcast
+	err := bp.Subscribe(backplane.BroadcastTopic, func(payload []byte) error {
+		// Broadcast local para clientes conectados nesta instância
+		log.Printf("[Hub] Mensagem recebida do backplane, broadcasting para %d clientes locais", len(h.clients))
+		h.broadcastToLocalClients(payload)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Eventos de sala trafegam em tópicos por sala, inscritos sob demanda
+	h.roomManager.SetBackplane(bp)
+
+	// Mensagens diretas chegam pelo tópico endereçado a esta instância
+	return bp.Subscribe(backplane.InstanceTopic(instanceID), h.roomManager.HandleDirectEvent)
+}
+
+// RoomManager retorna o gerenciador de salas do Hub
+func (h *Hub) RoomManager() *RoomManager {
+	return h.roomManager
+}
+
 // Register adiciona um novo cliente ao hub
 func (h *Hub) Register(client *Client) {
 	h.register <- client
@@ -109,10 +192,10 @@ func (h *Hub) Run() {
 			// 1. Broadcast local para clientes conectados nesta instância
 			h.broadcastToLocalClients(jsonData)
 
-			// 2. Publica no Redis Pub/Sub para outras instâncias
-			if h.pubSubAdapter != nil {
-				if err := h.pubSubAdapter.Publish(jsonData); err != nil {
-					log.Printf("Erro ao publicar no Redis Pub/Sub: %v", err)
+			// 2. Publica no backplane para outras instâncias
+			if h.backplane != nil {
+				if err := h.backplane.Publish(backplane.BroadcastTopic, jsonData); err != nil {
+					log.Printf("Erro ao publicar no backplane: %v", err)
 				}
 			}
 
@@ -146,9 +229,11 @@ func (h *Hub) broadcastToLocalClients(jsonData []byte) {
 // messageToStreamMessage converte Message para StreamMessage
 func (h *Hub) messageToStreamMessage(msg *Message) *redisAdapter.StreamMessage {
 	streamMsg := &redisAdapter.StreamMessage{
-		RoomName: "default",
-		Payload:  make(map[string]interface{}),
-		Metadata: make(map[string]interface{}),
+		MessageID: generateMessageID(),
+		CreatedAt: time.Now(),
+		RoomName:  "default",
+		Payload:   make(map[string]interface{}),
+		Metadata:  make(map[string]interface{}),
 	}
 
 	// Deserializa payload se disponível
@@ -188,11 +273,36 @@ func (h *Hub) messageToStreamMessage(msg *Message) *redisAdapter.StreamMessage {
 	return streamMsg
 }
 
+// Health retorna o estado das dependências do Hub
+// "degraded" indica que o backplane está fora e as mensagens entre instâncias
+// estão em buffer (ou sendo perdidas, se o backplane não tiver buffer)
+func (h *Hub) Health() map[string]interface{} {
+	health := map[string]interface{}{
+		"status":    "ok",
+		"backplane": "disabled",
+	}
+
+	if h.backplane == nil {
+		return health
+	}
+
+	health["backplane"] = "connected"
+	if checker, ok := h.backplane.(backplane.HealthChecker); ok {
+		health["bufferedMessages"] = checker.BufferedCount()
+		if !checker.IsConnected() {
+			health["backplane"] = "disconnected"
+			health["status"] = "degraded"
+		}
+	}
+
+	return health
+}
+
 // Close fecha as conexões do Hub
 func (h *Hub) Close() error {
-	if h.pubSubAdapter != nil {
-		if err := h.pubSubAdapter.Close(); err != nil {
-			log.Printf("Erro ao fechar Redis Pub/Sub: %v", err)
+	if h.backplane != nil {
+		if err := h.backplane.Close(); err != nil {
+			log.Printf("Erro ao fechar backplane: %v", err)
 		}
 	}
 
@@ -202,5 +312,24 @@ func (h *Hub) Close() error {
 		}
 	}
 
+	if h.presenceRegistry != nil {
+		if err := h.presenceRegistry.Close(); err != nil {
+			log.Printf("Erro ao fechar registro de presença: %v", err)
+		}
+	}
+
+	if h.sessionDirectory != nil {
+		if err := h.sessionDirectory.Close(); err != nil {
+			log.Printf("Erro ao fechar diretório de sessões: %v", err)
+		}
+	}
+
+	// Fecha o cliente compartilhado por último
+	if h.redisClient != nil {
+		if err := h.redisClient.Close(); err != nil {
+			log.Printf("Erro ao fechar cliente Redis: %v", err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/persistence"
+	redisAdapter "github.com/5ucr4m/go-socket/internal/redis"
+)
+
+// MessageQueue enfileira mensagens para persistência assíncrona (worker)
+type MessageQueue interface {
+	Publish(msg *redisAdapter.StreamMessage) error
+}
+
+// MessageArchive consulta mensagens já persistidas (PostgreSQL)
+type MessageArchive interface {
+	// GetRevisions retorna as revisões de uma mensagem em ordem cronológica
+	GetRevisions(roomName, messageID string) ([]*redisAdapter.StreamMessage, error)
+
+	// GetMessage retorna uma mensagem da sala (nil se não existir)
+	GetMessage(roomName, messageID string) (*redisAdapter.StreamMessage, error)
+
+	// GetMessagesBefore retorna mensagens anteriores ao cursor em ordem cronológica
+	// (horário zero = mais recentes)
+	GetMessagesBefore(roomName string, before time.Time, beforeID string, limit int) ([]*redisAdapter.StreamMessage, error)
+
+	// GetMessagesAfter retorna mensagens posteriores ao cursor em ordem cronológica
+	GetMessagesAfter(roomName string, after time.Time, afterID string, limit int) ([]*redisAdapter.StreamMessage, error)
+
+	// Search busca mensagens pelo texto, ordenadas por relevância
+	Search(query persistence.SearchQuery) ([]persistence.SearchResult, error)
+}
+
+// SetMessageArchive define o arquivo de mensagens persistidas
+func (rm *RoomManager) SetMessageArchive(archive MessageArchive) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.archive = archive
+}
+
+// messageArchive retorna o arquivo de mensagens configurado (thread-safe)
+func (rm *RoomManager) messageArchive() MessageArchive {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.archive
+}
+
+// SetMessageQueue define a fila de persistência das mensagens das salas
+func (rm *RoomManager) SetMessageQueue(queue MessageQueue) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.queue = queue
+}
+
+// messageQueue retorna a fila de persistência configurada (thread-safe)
+func (rm *RoomManager) messageQueue() MessageQueue {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.queue
+}
+
+// enqueueMessage enfileira uma mensagem publicada nesta instância para persistência
+// Apenas a instância de origem enfileira; as demais só recebem via backplane
+func (rm *RoomManager) enqueueMessage(roomName string, msg *RoomMessage) {
+	queue := rm.messageQueue()
+	if queue == nil {
+		return
+	}
+
+	if err := queue.Publish(roomMessageToStreamMessage(roomName, msg)); err != nil {
+		log.Printf("Erro ao enfileirar mensagem %s para persistência: %v", msg.ID, err)
+	}
+}
+
+// roomMessageToStreamMessage converte RoomMessage para StreamMessage
+func roomMessageToStreamMessage(roomName string, msg *RoomMessage) *redisAdapter.StreamMessage {
+	streamMsg := &redisAdapter.StreamMessage{
+		MessageID: msg.ID,
+		CreatedAt: msg.CreatedAt,
+		RoomName:  roomName,
+		Payload:   payloadToMap(msg.Payload),
+		Metadata:  msg.Metadata,
+	}
+
+	if id, ok := msg.User["id"].(string); ok {
+		streamMsg.UserID = id
+	}
+	if username, ok := msg.User["username"].(string); ok {
+		streamMsg.Username = username
+	}
+
+	return streamMsg
+}
+
+// payloadToMap converte o payload para objeto JSON
+// Payloads que não são objetos (ex.: texto simples) ficam em "message"
+func payloadToMap(payload interface{}) map[string]interface{} {
+	if payloadMap, ok := payload.(map[string]interface{}); ok {
+		return payloadMap
+	}
+
+	data, err := json.Marshal(payload)
+	if err == nil {
+		var payloadMap map[string]interface{}
+		if json.Unmarshal(data, &payloadMap) == nil && payloadMap != nil {
+			return payloadMap
+		}
+	}
+
+	return map[string]interface{}{"message": payload}
+}
@@ -0,0 +1,281 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Action é uma operação sujeita à política de salas
+type Action string
+
+const (
+	ActionSubscribe Action = "subscribe" // Entrar na sala e ler histórico, revisões e busca
+	ActionPublish   Action = "publish"   // Publicar mensagens
+	ActionPresence  Action = "presence"  // Aparecer na lista de presença
+	ActionTyping    Action = "typing"    // Enviar indicador de digitação
+	ActionEdit      Action = "edit"      // Editar e apagar mensagens
+	ActionDirect    Action = "direct"    // Enviar mensagem direta (sala "user:{id}" do destinatário)
+)
+
+// Prefixo da sala de usuário avaliada nas mensagens diretas
+const DirectRoomPrefix = "user:"
+
+// PolicyRule concede ou nega ações nas salas cujo nome casa com Pattern
+//
+// Pattern aceita variáveis ("order:{id}") e curinga ("public:*"). Value pode
+// referenciar as variáveis capturadas ("{id}"). A regra concede a ação se o
+// usuário tem um dos Roles ou se a claim Claim é igual a Value (ou, sendo
+// lista, contém Value). Sem Claim nem Roles, concede a todos.
+type PolicyRule struct {
+	Pattern string   `json:"pattern"`
+	Actions []Action `json:"actions,omitempty"` // Vazio = todas as ações
+	Claim   string   `json:"claim,omitempty"`
+	Value   string   `json:"value,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	Deny    bool     `json:"deny,omitempty"` // Nega as ações para quem casar com a regra
+
+	pattern *regexp.Regexp
+}
+
+// RoomPolicy avalia as regras em ordem; a primeira que casa com a sala e a
+// ação decide. Sem regra aplicável vale Default
+type RoomPolicy struct {
+	Default string        `json:"default"` // "allow" (padrão) ou "deny"
+	Rules   []*PolicyRule `json:"rules"`
+}
+
+// patternVariable captura "{nome}" e "*" em um padrão de sala
+var patternVariable = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}|\*`)
+
+// LoadRoomPolicy lê a política de um arquivo JSON
+func LoadRoomPolicy(path string) (*RoomPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler política de salas: %w", err)
+	}
+
+	var policy RoomPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("erro ao parsear política de salas: %w", err)
+	}
+
+	if err := policy.Compile(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Compile valida a política e prepara os padrões de sala
+func (p *RoomPolicy) Compile() error {
+	if p.Default == "" {
+		p.Default = "allow"
+	}
+	if p.Default != "allow" && p.Default != "deny" {
+		return fmt.Errorf("política de salas: default inválido %q", p.Default)
+	}
+
+	for i, rule := range p.Rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("política de salas: regra %d sem pattern", i)
+		}
+		for _, action := range rule.Actions {
+			switch action {
+			case ActionSubscribe, ActionPublish, ActionPresence, ActionTyping, ActionEdit, ActionDirect:
+			default:
+				return fmt.Errorf("política de salas: regra %d com ação desconhecida %q", i, action)
+			}
+		}
+		rule.pattern = compileRoomPattern(rule.Pattern)
+	}
+	return nil
+}
+
+// compileRoomPattern converte "order:{id}" em uma expressão com grupos nomeados
+func compileRoomPattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	last := 0
+	for _, match := range patternVariable.FindAllStringSubmatchIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:match[0]]))
+		if match[2] >= 0 {
+			expr.WriteString("(?P<" + pattern[match[2]:match[3]] + ">[^:]+)")
+		} else {
+			expr.WriteString(".*")
+		}
+		last = match[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String())
+}
+
+// Allows indica se o usuário pode executar a ação na sala
+// Retorna também a regra que decidiu (nil quando vale o padrão)
+func (p *RoomPolicy) Allows(user map[string]interface{}, roomName string, action Action) (bool, *PolicyRule) {
+	for _, rule := range p.Rules {
+		if !rule.appliesTo(action) {
+			continue
+		}
+
+		match := rule.pattern.FindStringSubmatch(roomName)
+		if match == nil {
+			continue
+		}
+
+		vars := make(map[string]string)
+		for i, name := range rule.pattern.SubexpNames() {
+			if name != "" {
+				vars[name] = match[i]
+			}
+		}
+
+		matched := rule.matchesUser(user, roomName, vars)
+		if rule.Deny {
+			if matched {
+				return false, rule
+			}
+			continue
+		}
+		return matched, rule
+	}
+
+	return p.Default != "deny", nil
+}
+
+// appliesTo indica se a regra cobre a ação
+func (r *PolicyRule) appliesTo(action Action) bool {
+	if len(r.Actions) == 0 {
+		return true
+	}
+	for _, candidate := range r.Actions {
+		if candidate == action {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesUser verifica papéis e claim do usuário contra a regra
+func (r *PolicyRule) matchesUser(user map[string]interface{}, roomName string, vars map[string]string) bool {
+	if r.Claim == "" && len(r.Roles) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+
+	// Papéis seguem o mesmo formato da moderação ("papel" ou "papel:sala")
+	if len(r.Roles) > 0 && isModerator(user, roomName, r.Roles) {
+		return true
+	}
+
+	if r.Claim == "" {
+		return false
+	}
+
+	expected := r.Value
+	for name, value := range vars {
+		expected = strings.ReplaceAll(expected, "{"+name+"}", value)
+	}
+	return claimMatches(user[r.Claim], expected)
+}
+
+// claimMatches compara uma claim (texto, número ou lista) com o valor esperado
+func claimMatches(claim interface{}, expected string) bool {
+	switch value := claim.(type) {
+	case string:
+		return value == expected
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64) == expected
+	case []interface{}:
+		for _, item := range value {
+			if claimMatches(item, expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RoomAuthorizer delega a um serviço externo o acesso de uma conexão às salas
+// Consultado depois da política de salas, que continua valendo
+type RoomAuthorizer interface {
+	AuthorizeRoom(roomName, action string) (bool, error)
+}
+
+// SetRoomPolicy define a política de acesso às salas (nil = todas liberadas)
+func (rm *RoomManager) SetRoomPolicy(policy *RoomPolicy) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.policy = policy
+}
+
+// roomPolicy retorna a política configurada (thread-safe)
+func (rm *RoomManager) roomPolicy() *RoomPolicy {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.policy
+}
+
+// isAllowed avalia a política para o usuário, a sala e a ação
+func (rm *RoomManager) isAllowed(user map[string]interface{}, roomName string, action Action) bool {
+	policy := rm.roomPolicy()
+	if policy == nil {
+		return true
+	}
+
+	allowed, _ := policy.Allows(user, roomName, action)
+	return allowed
+}
+
+// allowedFor avalia a política e o authorizer delegado da conexão
+func (rm *RoomManager) allowedFor(client *Client, roomName string, action Action) bool {
+	if !rm.isAllowed(client.GetUserInfo(), roomName, action) {
+		return false
+	}
+
+	authorizer := client.getRoomAuthorizer()
+	if authorizer == nil {
+		return true
+	}
+
+	allowed, err := authorizer.AuthorizeRoom(roomName, string(action))
+	if err != nil {
+		log.Printf("Erro ao autorizar %s na sala %s para %s: %v", action, roomName, client.GetUserID(), err)
+		return false
+	}
+	return allowed
+}
+
+// authorize verifica a ação do cliente e responde com erro forbidden se negada
+func (rm *RoomManager) authorize(client *Client, roomName string, action Action) bool {
+	if rm.allowedFor(client, roomName, action) {
+		return true
+	}
+
+	log.Printf("Ação %s negada na sala %s para %s", action, roomName, client.GetUserID())
+	sendForbidden(client, roomName, action)
+	return false
+}
+
+// sendForbidden envia um erro estruturado de permissão
+func sendForbidden(client *Client, roomName string, action Action) {
+	errorData, _ := json.Marshal(map[string]interface{}{
+		"type":   "error",
+		"code":   "forbidden",
+		"error":  "Sem permissão para " + string(action) + " na sala",
+		"room":   roomName,
+		"action": action,
+	})
+	select {
+	case client.send <- errorData:
+	default:
+	}
+}
@@ -0,0 +1,162 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompileRoomPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		room    string
+		match   bool
+		vars    map[string]string
+	}{
+		{"order:{id}", "order:123", true, map[string]string{"id": "123"}},
+		{"order:{id}", "order:", false, nil},
+		{"order:{id}", "order:1:extra", false, nil},
+		{"order:{id}", "xorder:1", false, nil},
+		{"user:{id}:inbox", "user:u1:inbox", true, map[string]string{"id": "u1"}},
+		{"team:{team}:user:{id}", "team:a:user:b", true, map[string]string{"team": "a", "id": "b"}},
+		{"public:*", "public:a:b", true, map[string]string{}},
+		{"public:*", "private:a", false, nil},
+		{"a.b", "axb", false, nil},
+		{"announcements", "announcements", true, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.room, func(t *testing.T) {
+			re := compileRoomPattern(tt.pattern)
+			match := re.FindStringSubmatch(tt.room)
+			if (match != nil) != tt.match {
+				t.Fatalf("match = %v, want %v (regex %s)", match != nil, tt.match, re)
+			}
+			if match == nil {
+				return
+			}
+
+			vars := make(map[string]string)
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					vars[name] = match[i]
+				}
+			}
+			if len(vars) != len(tt.vars) {
+				t.Fatalf("vars = %v, want %v", vars, tt.vars)
+			}
+			for name, value := range tt.vars {
+				if vars[name] != value {
+					t.Errorf("vars[%s] = %q, want %q", name, vars[name], value)
+				}
+			}
+		})
+	}
+}
+
+// testPolicy é a política de examples/room-policy.json, com uma regra de negação
+const testPolicy = `{
+	"default": "allow",
+	"rules": [
+		{"pattern": "user:*", "actions": ["direct"]},
+		{"pattern": "user:{id}", "claim": "id", "value": "{id}", "roles": ["admin"]},
+		{"pattern": "order:{id}", "actions": ["subscribe", "presence", "typing"], "claim": "orders", "value": "{id}", "roles": ["admin", "support"]},
+		{"pattern": "order:{id}", "actions": ["publish", "edit"], "roles": ["courier", "admin"]},
+		{"pattern": "announcements", "actions": ["publish", "edit"], "roles": ["admin"]},
+		{"pattern": "*", "claim": "banned", "value": "true", "deny": true}
+	]
+}`
+
+func TestRoomPolicyAllows(t *testing.T) {
+	var policy RoomPolicy
+	if err := json.Unmarshal([]byte(testPolicy), &policy); err != nil {
+		t.Fatalf("política inválida: %v", err)
+	}
+	if err := policy.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	alice := map[string]interface{}{"id": "alice", "orders": []interface{}{"1", float64(2)}}
+	admin := map[string]interface{}{"id": "root", "role": "admin"}
+	courier := map[string]interface{}{"id": "bob", "roles": []interface{}{"courier"}}
+	scopedSupport := map[string]interface{}{"id": "carol", "roles": []interface{}{"support:order:7"}}
+	banned := map[string]interface{}{"id": "eve", "banned": "true"}
+
+	tests := []struct {
+		name   string
+		user   map[string]interface{}
+		room   string
+		action Action
+		want   bool
+	}{
+		{"própria sala de usuário", alice, "user:alice", ActionSubscribe, true},
+		{"sala de outro usuário", alice, "user:bob", ActionSubscribe, false},
+		{"admin em sala de usuário", admin, "user:alice", ActionSubscribe, true},
+		{"mensagem direta para qualquer usuário", alice, "user:bob", ActionDirect, true},
+		{"pedido listado na claim (texto)", alice, "order:1", ActionSubscribe, true},
+		{"pedido listado na claim (número)", alice, "order:2", ActionTyping, true},
+		{"pedido fora da claim", alice, "order:3", ActionSubscribe, false},
+		{"publicar em pedido sem papel", alice, "order:1", ActionPublish, false},
+		{"entregador publica no pedido", courier, "order:9", ActionPublish, true},
+		{"entregador não assina pedido alheio", courier, "order:9", ActionSubscribe, false},
+		{"papel restrito à sala", scopedSupport, "order:7", ActionSubscribe, true},
+		{"papel restrito a outra sala", scopedSupport, "order:8", ActionSubscribe, false},
+		{"anúncios: leitura liberada", alice, "announcements", ActionSubscribe, true},
+		{"anúncios: publicação só admin", alice, "announcements", ActionPublish, false},
+		{"anúncios: admin publica", admin, "announcements", ActionPublish, true},
+		{"negação explícita", banned, "geral", ActionSubscribe, false},
+		{"sem regra vale o padrão", alice, "geral", ActionPublish, true},
+		{"usuário anônimo com claim exigida", nil, "order:1", ActionSubscribe, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := policy.Allows(tt.user, tt.room, tt.action); got != tt.want {
+				t.Errorf("Allows(%v, %q, %s) = %v, want %v", tt.user["id"], tt.room, tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomPolicyDefaultDeny(t *testing.T) {
+	policy := RoomPolicy{
+		Default: "deny",
+		Rules:   []*PolicyRule{{Pattern: "public:*", Actions: []Action{ActionSubscribe}}},
+	}
+	if err := policy.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	tests := []struct {
+		room   string
+		action Action
+		want   bool
+	}{
+		{"public:news", ActionSubscribe, true},
+		{"public:news", ActionPublish, false},
+		{"private", ActionSubscribe, false},
+	}
+	for _, tt := range tests {
+		if got, _ := policy.Allows(map[string]interface{}{"id": "u1"}, tt.room, tt.action); got != tt.want {
+			t.Errorf("Allows(%q, %s) = %v, want %v", tt.room, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestRoomPolicyCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RoomPolicy
+	}{
+		{"default inválido", RoomPolicy{Default: "maybe"}},
+		{"regra sem pattern", RoomPolicy{Rules: []*PolicyRule{{}}}},
+		{"ação desconhecida", RoomPolicy{Rules: []*PolicyRule{{Pattern: "a", Actions: []Action{"delete"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Compile(); err == nil {
+				t.Errorf("Compile() sem erro, want erro")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package pubsub
+
+import "log"
+
+// PresenceStore mantém a presença das salas compartilhada entre instâncias
+type PresenceStore interface {
+	// Join registra a conexão como presente na sala
+	Join(roomName, clientID string, user map[string]interface{}) error
+
+	// Leave remove a conexão da sala
+	Leave(roomName, clientID string) error
+
+	// List retorna os usuários presentes na sala em todo o cluster
+	List(roomName string) ([]map[string]interface{}, error)
+}
+
+// SetPresenceStore define o armazenamento de presença compartilhado
+func (rm *RoomManager) SetPresenceStore(store PresenceStore) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.presence = store
+}
+
+// presenceStore retorna o armazenamento de presença configurado (thread-safe)
+func (rm *RoomManager) presenceStore() PresenceStore {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.presence
+}
+
+// joinPresence registra o cliente no armazenamento de presença compartilhado
+func (rm *RoomManager) joinPresence(roomName string, client *Client) {
+	store := rm.presenceStore()
+	if store == nil {
+		return
+	}
+
+	if err := store.Join(roomName, client.GetID(), client.GetUserInfo()); err != nil {
+		log.Printf("Erro ao registrar presença na sala %s: %v", roomName, err)
+	}
+}
+
+// leavePresence remove o cliente do armazenamento de presença compartilhado
+// quando ele não está mais subscrito nem com presence na sala
+func (rm *RoomManager) leavePresence(room *Room, client *Client) {
+	store := rm.presenceStore()
+	if store == nil || room.HasClient(client) {
+		return
+	}
+
+	if err := store.Leave(room.name, client.GetID()); err != nil {
+		log.Printf("Erro ao remover presença da sala %s: %v", room.name, err)
+	}
+}
+
+// stillPresent indica se o usuário continua na lista de presença da sala
+// por outra conexão, nesta ou em outra instância
+func (rm *RoomManager) stillPresent(room *Room, userID string) bool {
+	if userID == "" {
+		return false
+	}
+
+	for _, user := range rm.getPresenceList(room) {
+		if id, _ := user["id"].(string); id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPresence retorna a lista de presença de uma sala
+// Salas sem clientes nesta instância são consultadas apenas no armazenamento compartilhado
+func (rm *RoomManager) GetPresence(roomName string) []map[string]interface{} {
+	if room := rm.GetRoom(roomName); room != nil {
+		return rm.getPresenceList(room)
+	}
+
+	store := rm.presenceStore()
+	if store == nil {
+		return []map[string]interface{}{}
+	}
+
+	presenceList, err := store.List(roomName)
+	if err != nil {
+		log.Printf("Erro ao buscar presença da sala %s: %v", roomName, err)
+		return []map[string]interface{}{}
+	}
+	return presenceList
+}
+
+// getPresenceList retorna a lista de presença da sala
+// Usa o armazenamento compartilhado e cai para os clientes locais em caso de erro
+func (rm *RoomManager) getPresenceList(room *Room) []map[string]interface{} {
+	store := rm.presenceStore()
+	if store == nil {
+		return room.GetPresenceList()
+	}
+
+	presenceList, err := store.List(room.name)
+	if err != nil {
+		log.Printf("Erro ao buscar presença da sala %s, usando lista local: %v", room.name, err)
+		return room.GetPresenceList()
+	}
+
+	return presenceList
+}
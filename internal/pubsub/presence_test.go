@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryPresence simula o armazenamento de presença compartilhado entre instâncias
+type memoryPresence struct {
+	mu    sync.Mutex
+	rooms map[string]map[string]map[string]interface{}
+}
+
+func (p *memoryPresence) Join(roomName, clientID string, user map[string]interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rooms[roomName] == nil {
+		p.rooms[roomName] = make(map[string]map[string]interface{})
+	}
+	p.rooms[roomName][clientID] = user
+	return nil
+}
+
+func (p *memoryPresence) Leave(roomName, clientID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.rooms[roomName], clientID)
+	return nil
+}
+
+// List retorna cada usuário uma única vez, como o PresenceRegistry
+func (p *memoryPresence) List(roomName string) ([]map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	presence := make([]map[string]interface{}, 0)
+	seen := make(map[interface{}]bool)
+	for _, user := range p.rooms[roomName] {
+		if seen[user["id"]] {
+			continue
+		}
+		seen[user["id"]] = true
+		presence = append(presence, user)
+	}
+	return presence, nil
+}
+
+func TestRemovePresenceUserLeftOnLastConnection(t *testing.T) {
+	tests := []struct {
+		name string
+		// instância da segunda conexão de alice (-1 = sem segunda conexão)
+		second int
+	}{
+		{"última conexão", -1},
+		{"outra conexão na mesma instância", 0},
+		{"outra conexão em outra instância", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubs := newTestCluster(t, 2)
+			shared := &memoryPresence{rooms: make(map[string]map[string]map[string]interface{})}
+			for _, hub := range hubs {
+				hub.RoomManager().SetPresenceStore(shared)
+			}
+
+			observer := newTestClient(hubs[0], "bob")
+			hubs[0].RoomManager().AddPresence(observer, "geral")
+
+			first := newTestClient(hubs[0], "alice")
+			hubs[0].RoomManager().AddPresence(first, "geral")
+
+			var second *Client
+			if tt.second >= 0 {
+				second = newTestClient(hubs[tt.second], "alice")
+				hubs[tt.second].RoomManager().AddPresence(second, "geral")
+			}
+
+			hubs[0].RoomManager().RemovePresence(first, "geral")
+
+			if second == nil {
+				if frame := waitFrame(t, observer, "user_left"); frame["user"].(map[string]interface{})["id"] != "alice" {
+					t.Errorf("user_left de %v, want alice", frame["user"])
+				}
+				return
+			}
+
+			// Alice continua na lista de presença pela outra conexão
+			expectNoFrame(t, observer, "user_left", 100*time.Millisecond)
+
+			hubs[tt.second].RoomManager().RemovePresence(second, "geral")
+			waitFrame(t, observer, "user_left")
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Escopos dos limites de taxa
+const (
+	RateScopeUser = "user" // Por usuário (conexão, quando anônimo)
+	RateScopeIP   = "ip"   // Por endereço IP do cliente
+	RateScopeRoom = "room" // Por sala, somando todos os clientes
+)
+
+const (
+	// Evento coringa: o limite vale para todos os tipos de evento
+	RateAnyEvent EventType = "*"
+
+	// Janela padrão da contagem de recusas que desconecta o cliente
+	DefaultViolationWindow = time.Minute
+
+	// Intervalo mínimo entre logs de falha do limitador compartilhado
+	limiterLogInterval = 30 * time.Second
+)
+
+// rateLimitedEvents são os eventos que aceitam limite próprio
+var rateLimitedEvents = map[EventType]bool{
+	RateAnyEvent:       true,
+	EventSubscribe:     true,
+	EventUnsubscribe:   true,
+	EventPublish:       true,
+	EventPresence:      true,
+	EventTyping:        true,
+	EventReadReceipt:   true,
+	EventDirectMsg:     true,
+	EventEditMessage:   true,
+	EventGetRevisions:  true,
+	EventDeleteMessage: true,
+	EventFetchHistory:  true,
+	EventSearch:        true,
+	EventReauth:        true,
+}
+
+// RateLimit é um token bucket: Rate eventos por segundo com rajadas de até Burst
+type RateLimit struct {
+	Scope string
+	Event EventType
+	Rate  float64
+	Burst int
+}
+
+// key retorna a chave do bucket do limite para um usuário, IP ou sala
+func (l RateLimit) key(id string) string {
+	return l.Scope + ":" + string(l.Event) + ":" + id
+}
+
+// ParseRateLimit lê um limite no formato escopo:evento=N/unidade[:rajada]
+// Ex.: user:publish=5/s:10, ip:*=50/s, room:typing=600/m
+// Sem rajada, o bucket comporta N eventos
+func ParseRateLimit(spec string) (RateLimit, error) {
+	target, value, ok := strings.Cut(strings.TrimSpace(spec), "=")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("limite %q: esperado escopo:evento=N/unidade", spec)
+	}
+
+	scope, event, ok := strings.Cut(target, ":")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("limite %q: esperado escopo:evento", spec)
+	}
+	switch scope {
+	case RateScopeUser, RateScopeIP, RateScopeRoom:
+	default:
+		return RateLimit{}, fmt.Errorf("limite %q: escopo desconhecido %q", spec, scope)
+	}
+	if !rateLimitedEvents[EventType(event)] {
+		return RateLimit{}, fmt.Errorf("limite %q: evento desconhecido %q", spec, event)
+	}
+
+	value, burstValue, hasBurst := strings.Cut(value, ":")
+	count, unit, _ := strings.Cut(value, "/")
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("limite %q: quantidade inválida %q", spec, count)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "", "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return RateLimit{}, fmt.Errorf("limite %q: unidade desconhecida %q", spec, unit)
+	}
+
+	burst := n
+	if hasBurst {
+		burst, err = strconv.Atoi(burstValue)
+		if err != nil || burst <= 0 {
+			return RateLimit{}, fmt.Errorf("limite %q: rajada inválida %q", spec, burstValue)
+		}
+	}
+
+	return RateLimit{
+		Scope: scope,
+		Event: EventType(event),
+		Rate:  float64(n) / period.Seconds(),
+		Burst: burst,
+	}, nil
+}
+
+// ParseRateLimits lê a lista de limites de RATE_LIMITS
+func ParseRateLimits(specs []string) ([]RateLimit, error) {
+	limits := make([]RateLimit, 0, len(specs))
+	for _, spec := range specs {
+		limit, err := ParseRateLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// RateLimitPolicy define os limites aplicados aos eventos dos clientes
+type RateLimitPolicy struct {
+	Limits []RateLimit
+
+	// Recusas dentro de ViolationWindow que desconectam o cliente (0 = nunca)
+	MaxViolations   int
+	ViolationWindow time.Duration
+}
+
+// RateLimiter consome tokens de buckets identificados por chave
+type RateLimiter interface {
+	// Allow consome um token; sem token disponível, retorna quanto esperar
+	Allow(key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// SetRateLimiter define onde ficam os buckets (ex.: Redis, compartilhado entre instâncias)
+func (rm *RoomManager) SetRateLimiter(limiter RateLimiter) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.limiter = limiter
+}
+
+// SetRateLimitPolicy define os limites de taxa dos eventos
+func (rm *RoomManager) SetRateLimitPolicy(policy RateLimitPolicy) {
+	if policy.ViolationWindow <= 0 {
+		policy.ViolationWindow = DefaultViolationWindow
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.rateLimits = policy
+}
+
+// rateLimitPolicy retorna a política e o limitador configurados (thread-safe)
+func (rm *RoomManager) rateLimitPolicy() (RateLimitPolicy, RateLimiter) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.rateLimits, rm.limiter
+}
+
+// allowEvent aplica os limites de taxa a um evento do cliente
+// Eventos recusados recebem um erro rate_limited; disconnect indica reincidência
+func (rm *RoomManager) allowEvent(client *Client, event *ClientEvent) (allowed, disconnect bool) {
+	policy, limiter := rm.rateLimitPolicy()
+	if len(policy.Limits) == 0 {
+		return true, false
+	}
+
+	for _, limit := range policy.Limits {
+		if limit.Event != RateAnyEvent && limit.Event != event.Type {
+			continue
+		}
+
+		id := rateLimitID(client, event, limit.Scope)
+		if id == "" {
+			continue
+		}
+
+		allowed, retryAfter, err := rm.consumeToken(limiter, limit, id)
+		if err != nil {
+			log.Printf("[RateLimit] %v", err)
+			continue
+		}
+		if !allowed {
+			return false, rm.rejectEvent(client, event, limit, retryAfter, policy)
+		}
+	}
+	return true, false
+}
+
+// consumeToken consome um token no limitador configurado
+// Com o limitador compartilhado fora do ar, o limite passa a valer por instância
+func (rm *RoomManager) consumeToken(limiter RateLimiter, limit RateLimit, id string) (bool, time.Duration, error) {
+	if limiter != nil {
+		allowed, retryAfter, err := limiter.Allow(limit.key(id), limit.Rate, limit.Burst)
+		if err == nil {
+			return allowed, retryAfter, nil
+		}
+		rm.logLimiterFailure(err)
+	}
+	return rm.localLimiter.Allow(limit.key(id), limit.Rate, limit.Burst)
+}
+
+// logLimiterFailure registra a falha do limitador compartilhado no máximo uma
+// vez por limiterLogInterval (com o Redis fora, todo evento falharia)
+func (rm *RoomManager) logLimiterFailure(err error) {
+	rm.limiterLogMu.Lock()
+	if time.Since(rm.limiterLoggedAt) < limiterLogInterval {
+		rm.limiterFailures++
+		rm.limiterLogMu.Unlock()
+		return
+	}
+	suppressed := rm.limiterFailures
+	rm.limiterLoggedAt = time.Now()
+	rm.limiterFailures = 0
+	rm.limiterLogMu.Unlock()
+
+	log.Printf("[RateLimit] Limitador compartilhado indisponível, usando limite local (%d falhas omitidas): %v", suppressed, err)
+}
+
+// rateLimitID retorna o identificador do bucket no escopo do limite ("" = não se aplica)
+func rateLimitID(client *Client, event *ClientEvent, scope string) string {
+	switch scope {
+	case RateScopeUser:
+		// Sem autenticação o userId vem do cliente e não identifica ninguém
+		if userID := client.GetUserID(); userID != "" && client.IsAuthenticated() {
+			return userID
+		}
+		return "conn:" + client.GetID()
+	case RateScopeIP:
+		return client.GetRemoteIP()
+	case RateScopeRoom:
+		return event.Room
+	}
+	return ""
+}
+
+// rejectEvent avisa o cliente da recusa e indica se ele deve ser desconectado
+func (rm *RoomManager) rejectEvent(client *Client, event *ClientEvent, limit RateLimit, retryAfter time.Duration, policy RateLimitPolicy) bool {
+	frame := map[string]interface{}{
+		"type":         "error",
+		"code":         "rate_limited",
+		"error":        "Limite de eventos excedido",
+		"event":        event.Type,
+		"scope":        limit.Scope,
+		"retryAfterMs": retryAfter.Milliseconds(),
+	}
+	if event.Room != "" {
+		frame["room"] = event.Room
+	}
+	errorData, _ := json.Marshal(frame)
+	select {
+	case client.send <- errorData:
+	default:
+	}
+
+	violations := client.recordViolation(policy.ViolationWindow)
+	if policy.MaxViolations > 0 && violations >= policy.MaxViolations {
+		log.Printf("[RateLimit] %s (%s) excedeu o limite %d vezes, desconectando", rateLimitID(client, event, RateScopeUser), client.GetRemoteIP(), violations)
+		return true
+	}
+	return false
+}
+
+// recordViolation registra uma recusa e retorna quantas houve na janela atual
+func (c *Client) recordViolation(window time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.violationsSince) > window {
+		c.violationsSince = now
+		c.violations = 0
+	}
+	c.violations++
+	return c.violations
+}
+
+// closePolicyViolation envia o close code 1008 (o readPump encerra a conexão)
+func (c *Client) closePolicyViolation(reason string) {
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+}
+
+// memoryRateLimiter mantém os buckets na memória da instância
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket é o estado de um bucket em memória
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	idleAfter time.Duration // Tempo até encher de novo (pode ser descartado)
+}
+
+// NewMemoryRateLimiter cria um limitador de taxa local (sem compartilhamento entre instâncias)
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow consome um token do bucket key
+func (ml *memoryRateLimiter) Allow(key string, rate float64, burst int) (bool, time.Duration, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := time.Now()
+	ml.sweep(now)
+
+	bucket, exists := ml.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{
+			tokens:    float64(burst),
+			updatedAt: now,
+			idleAfter: time.Duration(float64(burst) / rate * float64(time.Second)),
+		}
+		ml.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+elapsed*rate)
+	bucket.updatedAt = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0, nil
+	}
+
+	wait := math.Ceil((1 - bucket.tokens) / rate * 1000)
+	return false, time.Duration(wait) * time.Millisecond, nil
+}
+
+// sweep descarta buckets que já voltaram a encher; deve ser chamado com ml.mu travado
+func (ml *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(ml.lastSweep) < time.Minute {
+		return
+	}
+	ml.lastSweep = now
+
+	for key, bucket := range ml.buckets {
+		if now.Sub(bucket.updatedAt) > bucket.idleAfter {
+			delete(ml.buckets, key)
+		}
+	}
+}
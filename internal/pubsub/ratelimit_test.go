@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    RateLimit
+		wantErr bool
+	}{
+		{spec: "user:publish=5/s:10", want: RateLimit{Scope: RateScopeUser, Event: EventPublish, Rate: 5, Burst: 10}},
+		{spec: "ip:*=50/s", want: RateLimit{Scope: RateScopeIP, Event: RateAnyEvent, Rate: 50, Burst: 50}},
+		{spec: "room:typing=600/m", want: RateLimit{Scope: RateScopeRoom, Event: EventTyping, Rate: 10, Burst: 600}},
+		{spec: "user:search=360/h:5", want: RateLimit{Scope: RateScopeUser, Event: EventSearch, Rate: 0.1, Burst: 5}},
+		{spec: " user:subscribe=3 ", want: RateLimit{Scope: RateScopeUser, Event: EventSubscribe, Rate: 3, Burst: 3}},
+		{spec: "user:publish", wantErr: true},
+		{spec: "publish=5/s", wantErr: true},
+		{spec: "tenant:publish=5/s", wantErr: true},
+		{spec: "user:shout=5/s", wantErr: true},
+		{spec: "user:publish=0/s", wantErr: true},
+		{spec: "user:publish=-1/s", wantErr: true},
+		{spec: "user:publish=abc/s", wantErr: true},
+		{spec: "user:publish=5/d", wantErr: true},
+		{spec: "user:publish=5/s:0", wantErr: true},
+		{spec: "user:publish=5/s:x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRateLimit(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRateLimit(%q) = %+v, want erro", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRateLimit(%q): %v", tt.spec, err)
+			}
+			if got.Scope != tt.want.Scope || got.Event != tt.want.Event || got.Burst != tt.want.Burst ||
+				math.Abs(got.Rate-tt.want.Rate) > 1e-9 {
+				t.Errorf("ParseRateLimit(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitsStopsAtFirstError(t *testing.T) {
+	if _, err := ParseRateLimits([]string{"user:publish=5/s", "user:publish"}); err == nil {
+		t.Error("ParseRateLimits sem erro, want erro da segunda entrada")
+	}
+
+	limits, err := ParseRateLimits(nil)
+	if err != nil || len(limits) != 0 {
+		t.Errorf("ParseRateLimits(nil) = %v, %v; want vazio", limits, err)
+	}
+}
+
+func TestMemoryRateLimiterBurst(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+	}{
+		{"rajada igual à taxa", 5, 5},
+		{"rajada maior que a taxa", 1, 10},
+		{"rajada de um", 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewMemoryRateLimiter()
+
+			for i := 0; i < tt.burst; i++ {
+				allowed, _, err := limiter.Allow("k", tt.rate, tt.burst)
+				if err != nil || !allowed {
+					t.Fatalf("evento %d da rajada recusado (err: %v)", i+1, err)
+				}
+			}
+
+			allowed, retryAfter, err := limiter.Allow("k", tt.rate, tt.burst)
+			if err != nil {
+				t.Fatalf("Allow: %v", err)
+			}
+			if allowed {
+				t.Fatal("evento além da rajada aceito")
+			}
+			maxWait := time.Duration(float64(time.Second) / tt.rate)
+			if retryAfter <= 0 || retryAfter > maxWait+time.Millisecond {
+				t.Errorf("retryAfter = %v, want entre 0 e %v", retryAfter, maxWait)
+			}
+
+			// Buckets são independentes por chave
+			if allowed, _, _ := limiter.Allow("outra", tt.rate, tt.burst); !allowed {
+				t.Error("bucket de outra chave recusou o primeiro evento")
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimiterRefill(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+
+	// 100 tokens/s: um token a cada 10ms
+	if allowed, _, _ := limiter.Allow("k", 100, 1); !allowed {
+		t.Fatal("primeiro evento recusado")
+	}
+	if allowed, _, _ := limiter.Allow("k", 100, 1); allowed {
+		t.Fatal("segundo evento imediato aceito")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if allowed, _, _ := limiter.Allow("k", 100, 1); !allowed {
+		t.Error("evento recusado depois do reabastecimento")
+	}
+}
+
+func TestRateLimitID(t *testing.T) {
+	anonymous := &Client{id: "c1", userInfo: map[string]interface{}{"id": "alice"}, remoteIP: "10.0.0.1"}
+	verified := &Client{id: "c2", userInfo: map[string]interface{}{"id": "alice"}, authenticated: true}
+	event := &ClientEvent{Type: EventPublish, Room: "geral"}
+
+	tests := []struct {
+		name   string
+		client *Client
+		scope  string
+		want   string
+	}{
+		{"usuário autenticado", verified, RateScopeUser, "alice"},
+		{"userId informado pelo cliente não vale", anonymous, RateScopeUser, "conn:c1"},
+		{"ip", anonymous, RateScopeIP, "10.0.0.1"},
+		{"sala", anonymous, RateScopeRoom, "geral"},
+		{"escopo desconhecido", anonymous, "tenant", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rateLimitID(tt.client, event, tt.scope); got != tt.want {
+				t.Errorf("rateLimitID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	redisAdapter "github.com/5ucr4m/go-socket/internal/redis"
+)
+
+// MessageRevision representa uma edição de mensagem
+type MessageRevision struct {
+	ID              string                 `json:"id"`
+	MessageID       string                 `json:"messageId"`
+	Payload         interface{}            `json:"payload"`         // Conteúdo após a edição
+	PreviousPayload interface{}            `json:"previousPayload"` // Conteúdo antes da edição
+	EditedBy        map[string]interface{} `json:"editedBy"`
+	EditedAt        time.Time              `json:"editedAt"`
+}
+
+// EditPolicy define quem pode editar mensagens e por quanto tempo
+type EditPolicy struct {
+	// Tempo após a criação em que o autor ainda pode editar (0 = sem limite)
+	// Moderadores não estão sujeitos à janela
+	Window time.Duration
+
+	// Papéis com permissão de moderação em todas as salas
+	// O papel "<papel>:<sala>" concede moderação apenas naquela sala
+	ModeratorRoles []string
+}
+
+// DefaultEditPolicy retorna a política padrão: sem janela, moderadores "admin" e "moderator"
+func DefaultEditPolicy() EditPolicy {
+	return EditPolicy{ModeratorRoles: []string{"admin", "moderator"}}
+}
+
+// SetEditPolicy define a política de edição de mensagens
+func (rm *RoomManager) SetEditPolicy(policy EditPolicy) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.editPolicy = policy
+}
+
+// getEditPolicy retorna a política de edição configurada (thread-safe)
+func (rm *RoomManager) getEditPolicy() EditPolicy {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.editPolicy
+}
+
+// checkEdit verifica se o cliente pode editar a mensagem
+// Retorna o código e a descrição do erro, ou "" se a edição é permitida
+func (rm *RoomManager) checkEdit(client *Client, roomName string, msg *RoomMessage) (string, string) {
+	policy := rm.getEditPolicy()
+
+	if msg.IsDeleted {
+		return "message_deleted", "Mensagens apagadas não podem ser editadas"
+	}
+
+	if isModerator(client.GetUserInfo(), roomName, policy.ModeratorRoles) {
+		return "", ""
+	}
+
+	if !isAuthor(client, msg) {
+		return "forbidden", "Apenas o autor ou moderadores podem editar esta mensagem"
+	}
+
+	if policy.Window > 0 && time.Since(msg.CreatedAt) > policy.Window {
+		return "edit_window_expired", "O prazo para editar esta mensagem expirou"
+	}
+
+	return "", ""
+}
+
+// isAuthor verifica se o cliente é o autor da mensagem
+func isAuthor(client *Client, msg *RoomMessage) bool {
+	// Mensagens de serviços não têm autor entre os clientes
+	if isSystemUser(msg.User) {
+		return false
+	}
+	if system, _ := msg.Metadata["system"].(bool); system {
+		return false
+	}
+
+	userID := client.GetUserID()
+	authorID, _ := msg.User["id"].(string)
+	return userID != "" && userID == authorID
+}
+
+// isModerator verifica se o usuário tem papel de moderação na sala
+// Os papéis vêm de "role" (string) ou "roles" (lista) nas informações do usuário
+func isModerator(user map[string]interface{}, roomName string, moderatorRoles []string) bool {
+	roles := make([]string, 0)
+	if role, ok := user["role"].(string); ok {
+		roles = append(roles, role)
+	}
+	if list, ok := user["roles"].([]interface{}); ok {
+		for _, item := range list {
+			if role, ok := item.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	for _, role := range roles {
+		name, scope, scoped := strings.Cut(role, ":")
+		if scoped && scope != roomName {
+			continue
+		}
+		for _, moderatorRole := range moderatorRoles {
+			if name == moderatorRole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enqueueRevision enfileira uma revisão para persistência
+func (rm *RoomManager) enqueueRevision(roomName string, revision *MessageRevision) {
+	queue := rm.messageQueue()
+	if queue == nil {
+		return
+	}
+
+	streamMsg := &redisAdapter.StreamMessage{
+		Kind:            redisAdapter.KindRevision,
+		MessageID:       revision.MessageID,
+		RevisionID:      revision.ID,
+		CreatedAt:       revision.EditedAt,
+		RoomName:        roomName,
+		Payload:         payloadToMap(revision.Payload),
+		PreviousPayload: payloadToMap(revision.PreviousPayload),
+	}
+	if id, ok := revision.EditedBy["id"].(string); ok {
+		streamMsg.UserID = id
+	}
+	if username, ok := revision.EditedBy["username"].(string); ok {
+		streamMsg.Username = username
+	}
+
+	if err := queue.Publish(streamMsg); err != nil {
+		log.Printf("Erro ao enfileirar revisão %s para persistência: %v", revision.ID, err)
+	}
+}
+
+// GetRevisions envia ao cliente as revisões de uma mensagem
+// Junta as revisões persistidas com as que ainda estão apenas em memória
+func (rm *RoomManager) GetRevisions(client *Client, roomName, messageID string) {
+	if !rm.authorize(client, roomName, ActionSubscribe) {
+		return
+	}
+
+	byID := make(map[string]*MessageRevision)
+
+	if archive := rm.messageArchive(); archive != nil {
+		stored, err := archive.GetRevisions(roomName, messageID)
+		if err != nil {
+			log.Printf("Erro ao buscar revisões de %s: %v", messageID, err)
+		}
+		for _, entry := range stored {
+			revision := streamMessageToRevision(entry)
+			byID[revision.ID] = revision
+		}
+	}
+
+	if room := rm.GetRoom(roomName); room != nil {
+		// Mensagens apagadas não expõem o conteúdo anterior
+		if msg, found := room.GetMessage(messageID); found && msg.IsDeleted {
+			byID = make(map[string]*MessageRevision)
+		}
+		for _, revision := range room.GetRevisions(messageID) {
+			byID[revision.ID] = revision
+		}
+	}
+
+	revisions := make([]*MessageRevision, 0, len(byID))
+	for _, revision := range byID {
+		revisions = append(revisions, revision)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].EditedAt.Before(revisions[j].EditedAt)
+	})
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      "message_revisions",
+		"room":      roomName,
+		"messageId": messageID,
+		"revisions": revisions,
+	})
+	if err != nil {
+		log.Printf("Erro ao serializar revisões: %v", err)
+		return
+	}
+
+	select {
+	case client.send <- data:
+	default:
+	}
+}
+
+// streamMessageToRevision converte uma revisão persistida para MessageRevision
+func streamMessageToRevision(entry *redisAdapter.StreamMessage) *MessageRevision {
+	editedBy := map[string]interface{}{}
+	if entry.UserID != "" {
+		editedBy["id"] = entry.UserID
+	}
+	if entry.Username != "" {
+		editedBy["username"] = entry.Username
+	}
+
+	return &MessageRevision{
+		ID:              entry.RevisionID,
+		MessageID:       entry.MessageID,
+		Payload:         entry.Payload,
+		PreviousPayload: entry.PreviousPayload,
+		EditedBy:        editedBy,
+		EditedAt:        entry.CreatedAt,
+	}
+}
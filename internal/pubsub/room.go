@@ -28,6 +28,9 @@ type Room struct {
 	// Limite máximo de mensagens no histórico (0 = ilimitado)
 	maxHistorySize int
 
+	// Revisões das mensagens em memória (ID da mensagem -> revisões em ordem)
+	revisions map[string][]*MessageRevision
+
 	// Metadata da sala
 	metadata map[string]interface{}
 
@@ -44,6 +47,8 @@ type RoomMessage struct {
 	CreatedAt time.Time              `json:"createdAt"`        // Timestamp de criação original
 	EditedAt  *time.Time             `json:"editedAt,omitempty"` // Timestamp da última edição (se houver)
 	IsEdited  bool                   `json:"isEdited"`         // Flag indicando se foi editada
+	DeletedAt *time.Time             `json:"deletedAt,omitempty"` // Timestamp da exclusão (tombstone)
+	IsDeleted bool                   `json:"isDeleted,omitempty"` // Flag indicando se foi apagada
 }
 
 // NewRoom cria uma nova sala
@@ -52,6 +57,7 @@ func NewRoom(name string, maxHistorySize int) *Room {
 		name:            name,
 		subscribers:     make(map[*Client]bool),
 		presenceClients: make(map[*Client]bool),
+		revisions:       make(map[string][]*MessageRevision),
 		messageHistory:  make([]*RoomMessage, 0),
 		maxHistorySize:  maxHistorySize,
 		metadata: map[string]interface{}{
@@ -96,6 +102,22 @@ func generateMessageID() string {
 	return hex.EncodeToString(bytes)
 }
 
+// stampMessage atribui ID, horário e metadata de sala a uma mensagem
+// publicada sem sala local (AddMessage faz o mesmo para salas locais)
+func stampMessage(roomName string, msg *RoomMessage) {
+	if msg.ID == "" {
+		msg.ID = generateMessageID()
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]interface{})
+	}
+	msg.Metadata["room"] = roomName
+	msg.Metadata["createdAt"] = msg.CreatedAt
+}
+
 // AddMessage adiciona uma mensagem ao histórico
 // Implementa um buffer circular se maxHistorySize > 0
 func (r *Room) AddMessage(msg *RoomMessage) {
@@ -124,24 +146,160 @@ func (r *Room) AddMessage(msg *RoomMessage) {
 	// Mantém apenas as últimas maxHistorySize mensagens
 	if r.maxHistorySize > 0 && len(r.messageHistory) > r.maxHistorySize {
 		// Remove mensagens antigas (buffer circular)
+		evicted := r.messageHistory[:len(r.messageHistory)-r.maxHistorySize]
+		for _, old := range evicted {
+			delete(r.revisions, old.ID)
+		}
 		r.messageHistory = r.messageHistory[len(r.messageHistory)-r.maxHistorySize:]
 	}
 }
 
-// EditMessage edita uma mensagem existente no histórico
-// Retorna true se a mensagem foi encontrada e editada, false caso contrário
-func (r *Room) EditMessage(messageID string, newPayload interface{}) (*RoomMessage, bool) {
+// LoadHistory substitui o histórico em memória por mensagens já persistidas
+// (ordem cronológica), respeitando o limite da sala
+// Mensagens adicionadas enquanto o histórico era lido (a sala já está visível
+// para as demais conexões) são mantidas depois das carregadas
+func (r *Room) LoadHistory(messages []*RoomMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		loaded[msg.ID] = true
+	}
+
+	merged := make([]*RoomMessage, 0, len(messages)+len(r.messageHistory))
+	merged = append(merged, messages...)
+	for _, msg := range r.messageHistory {
+		if !loaded[msg.ID] {
+			merged = append(merged, msg)
+		}
+	}
+
+	if r.maxHistorySize > 0 && len(merged) > r.maxHistorySize {
+		merged = merged[len(merged)-r.maxHistorySize:]
+	}
+
+	r.messageHistory = merged
+}
+
+// EditMessage edita uma mensagem existente no histórico e registra a revisão
+// Retorna false se a mensagem não foi encontrada
+func (r *Room) EditMessage(messageID string, newPayload interface{}, editor map[string]interface{}) (*RoomMessage, *MessageRevision, bool) {
+	editedAt := time.Now()
+
+	msg, previous, found := r.applyEdit(messageID, newPayload, editedAt)
+	if !found {
+		return nil, nil, false
+	}
+
+	revision := &MessageRevision{
+		ID:              generateMessageID(),
+		MessageID:       messageID,
+		Payload:         newPayload,
+		PreviousPayload: previous,
+		EditedBy:        editor,
+		EditedAt:        editedAt,
+	}
+	r.addRevision(revision)
+
+	return msg, revision, true
+}
+
+// applyDelete transforma uma mensagem em tombstone (sem conteúdo nem revisões)
+// Usado tanto para exclusões locais quanto para exclusões vindas de outras instâncias
+func (r *Room) applyDelete(messageID string, deletedAt time.Time) (*RoomMessage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, msg := range r.messageHistory {
+		if msg.ID == messageID {
+			msg.Payload = nil
+			msg.IsDeleted = true
+			msg.DeletedAt = &deletedAt
+
+			if msg.Metadata == nil {
+				msg.Metadata = make(map[string]interface{})
+			}
+			msg.Metadata["deletedAt"] = deletedAt
+			msg.Metadata["isDeleted"] = true
+
+			delete(r.revisions, messageID)
+			return msg, true
+		}
+	}
+
+	return nil, false
+}
+
+// removeMessage remove definitivamente uma mensagem do histórico (purge)
+func (r *Room) removeMessage(messageID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.revisions, messageID)
+
+	for i, msg := range r.messageHistory {
+		if msg.ID == messageID {
+			r.messageHistory = append(r.messageHistory[:i], r.messageHistory[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetMessage retorna uma cópia de uma mensagem do histórico
+func (r *Room) GetMessage(messageID string) (*RoomMessage, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, msg := range r.messageHistory {
+		if msg.ID == messageID {
+			copied := *msg
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// addRevision registra uma revisão em memória (ignora revisões repetidas)
+func (r *Room) addRevision(revision *MessageRevision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.revisions[revision.MessageID] {
+		if existing.ID == revision.ID {
+			return
+		}
+	}
+	r.revisions[revision.MessageID] = append(r.revisions[revision.MessageID], revision)
+}
+
+// GetRevisions retorna as revisões em memória de uma mensagem
+func (r *Room) GetRevisions(messageID string) []*MessageRevision {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*MessageRevision, len(r.revisions[messageID]))
+	copy(result, r.revisions[messageID])
+	return result
+}
+
+// applyEdit aplica uma edição com o timestamp informado e retorna o payload anterior
+// Usado tanto para edições locais quanto para edições vindas de outras instâncias
+func (r *Room) applyEdit(messageID string, newPayload interface{}, editedAt time.Time) (*RoomMessage, interface{}, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// Procura a mensagem no histórico
 	for i, msg := range r.messageHistory {
 		if msg.ID == messageID {
+			previous := msg.Payload
+
 			// Atualiza o payload
 			msg.Payload = newPayload
 
 			// Marca como editada
-			now := time.Now()
+			now := editedAt
 			msg.EditedAt = &now
 			msg.IsEdited = true
 
@@ -155,11 +313,11 @@ func (r *Room) EditMessage(messageID string, newPayload interface{}) (*RoomMessa
 			// Atualiza no histórico
 			r.messageHistory[i] = msg
 
-			return msg, true
+			return msg, previous, true
 		}
 	}
 
-	return nil, false
+	return nil, nil, false
 }
 
 // GetHistory retorna o histórico de mensagens com limite opcional
@@ -230,6 +388,13 @@ func (r *Room) GetPresenceList() []map[string]interface{} {
 	return presence
 }
 
+// HasClient verifica se o cliente está subscrito ou com presence na sala
+func (r *Room) HasClient(client *Client) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.subscribers[client] || r.presenceClients[client]
+}
+
 // IsEmpty verifica se a sala está vazia
 func (r *Room) IsEmpty() bool {
 	r.mu.RLock()
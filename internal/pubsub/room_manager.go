@@ -3,7 +3,11 @@ package pubsub
 import (
 	"encoding/json"
 	"log"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/backplane"
 )
 
 // RoomManager gerencia todas as salas de chat
@@ -16,12 +20,66 @@ type RoomManager struct {
 
 	// Limite padrão de histórico para novas salas
 	defaultMaxHistory int
+
+	// Propaga eventos entre instâncias (nil = modo local)
+	backplane backplane.Backplane
+
+	// Presença compartilhada entre instâncias (nil = apenas clientes locais)
+	presence PresenceStore
+
+	// Histórico compartilhado entre instâncias (nil = apenas memória local)
+	history HistoryStore
+
+	// Conexões locais indexadas pelo ID do usuário
+	localUsers map[string]map[*Client]bool
+
+	// Diretório de sessões para mensagens diretas entre instâncias
+	directory UserDirectory
+
+	// Serializa as chamadas ao diretório, feitas fora de mu
+	directoryMu sync.Mutex
+
+	// Serializa as inscrições nos tópicos das salas, feitas fora de mu
+	topicMu sync.Mutex
+
+	// Fila de persistência das mensagens (nil = sem persistência)
+	queue MessageQueue
+
+	// Mensagens persistidas para consultas (nil = apenas memória)
+	archive MessageArchive
+
+	// Quem pode editar mensagens e por quanto tempo
+	editPolicy EditPolicy
+
+	// Quem pode entrar, publicar, editar... em cada sala (nil = todas liberadas)
+	policy *RoomPolicy
+
+	// Verificador de tokens do evento reauth e antecedência do aviso token_expiring
+	reauth        Reauthenticator
+	expiryWarning time.Duration
+
+	// Limites de taxa dos eventos dos clientes (sem limites = desabilitado)
+	rateLimits RateLimitPolicy
+
+	// Buckets compartilhados entre instâncias (nil = apenas localLimiter)
+	limiter RateLimiter
+
+	// Buckets locais, usados sem limitador compartilhado ou quando ele falha
+	localLimiter RateLimiter
+
+	// Controle do log de falhas do limitador compartilhado (protegido por limiterLogMu)
+	limiterLogMu    sync.Mutex
+	limiterLoggedAt time.Time
+	limiterFailures int
 }
 
 // NewRoomManager cria um novo gerenciador de salas
 func NewRoomManager(defaultMaxHistory int) *RoomManager {
 	return &RoomManager{
 		rooms:             make(map[string]*Room),
+		localUsers:        make(map[string]map[*Client]bool),
+		editPolicy:        DefaultEditPolicy(),
+		localLimiter:      NewMemoryRateLimiter(),
 		defaultMaxHistory: defaultMaxHistory,
 	}
 }
@@ -29,9 +87,9 @@ func NewRoomManager(defaultMaxHistory int) *RoomManager {
 // GetOrCreateRoom obtém uma sala existente ou cria uma nova
 func (rm *RoomManager) GetOrCreateRoom(name string) *Room {
 	rm.mu.Lock()
-	defer rm.mu.Unlock()
 
 	if room, exists := rm.rooms[name]; exists {
+		rm.mu.Unlock()
 		return room
 	}
 
@@ -39,6 +97,15 @@ func (rm *RoomManager) GetOrCreateRoom(name string) *Room {
 	room := NewRoom(name, rm.defaultMaxHistory)
 	rm.rooms[name] = room
 	log.Printf("Sala criada: %s", name)
+	rm.mu.Unlock()
+
+	// Primeira referência local: passa a receber eventos da sala de outras instâncias
+	rm.syncRoomTopic(name)
+
+	// Carrega o histórico compartilhado (mensagens de outras instâncias ou anteriores ao reinício)
+	// Fora de rm.mu: um Redis lento não trava as demais salas
+	rm.hydrateRoom(room)
+
 	return room
 }
 
@@ -52,31 +119,46 @@ func (rm *RoomManager) GetRoom(name string) *Room {
 // RemoveRoom remove uma sala (geralmente quando está vazia)
 func (rm *RoomManager) RemoveRoom(name string) {
 	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
+	removed := false
 	if room, exists := rm.rooms[name]; exists {
 		if room.IsEmpty() {
 			delete(rm.rooms, name)
+			removed = true
 			log.Printf("Sala removida: %s", name)
 		}
 	}
+	rm.mu.Unlock()
+
+	if removed {
+		rm.syncRoomTopic(name)
+	}
 }
 
 // CleanupEmptyRooms remove todas as salas vazias
 func (rm *RoomManager) CleanupEmptyRooms() {
 	rm.mu.Lock()
-	defer rm.mu.Unlock()
-
+	removed := make([]string, 0)
 	for name, room := range rm.rooms {
 		if room.IsEmpty() {
 			delete(rm.rooms, name)
+			removed = append(removed, name)
 			log.Printf("Sala vazia removida: %s", name)
 		}
 	}
+	rm.mu.Unlock()
+
+	for _, name := range removed {
+		rm.syncRoomTopic(name)
+	}
 }
 
 // Subscribe inscreve um cliente em uma sala
 func (rm *RoomManager) Subscribe(client *Client, roomName string, options SubscribeOptions) error {
+	// Verifica antes de criar a sala: nomes proibidos não chegam a existir
+	if !rm.authorize(client, roomName, ActionSubscribe) {
+		return nil
+	}
+
 	room := rm.GetOrCreateRoom(roomName)
 	room.Subscribe(client)
 
@@ -85,11 +167,13 @@ func (rm *RoomManager) Subscribe(client *Client, roomName string, options Subscr
 	client.roomSubscriptions[roomName] = true
 	client.mu.Unlock()
 
+	rm.joinPresence(roomName, client)
+
 	log.Printf("Cliente %p subscrito na sala: %s", client, roomName)
 
 	// Envia histórico se solicitado
 	if options.History {
-		history := room.GetHistory(options.Limit)
+		history := rm.getHistory(room, options.Limit)
 		if len(history) > 0 {
 			rm.sendHistoryToClient(client, roomName, history)
 		}
@@ -112,6 +196,8 @@ func (rm *RoomManager) Unsubscribe(client *Client, roomName string) {
 	delete(client.roomSubscriptions, roomName)
 	client.mu.Unlock()
 
+	rm.leavePresence(room, client)
+
 	log.Printf("Cliente %p removido da sala: %s", client, roomName)
 
 	// Remove sala se estiver vazia
@@ -128,6 +214,10 @@ func (rm *RoomManager) Publish(client *Client, roomName string, payload interfac
 		return nil
 	}
 
+	if !rm.authorize(client, roomName, ActionPublish) {
+		return nil
+	}
+
 	// Cria mensagem
 	roomMsg := &RoomMessage{
 		Payload:  payload,
@@ -135,12 +225,44 @@ func (rm *RoomManager) Publish(client *Client, roomName string, payload interfac
 		Metadata: room.GetMetadata(),
 	}
 
-	// Adiciona ao histórico
-	room.AddMessage(roomMsg)
+	return rm.deliverMessage(roomName, room, roomMsg)
+}
 
-	// Envia para todos os subscribers
-	subscribers := room.GetSubscribers()
-	rm.broadcastToClients(subscribers, roomMsg)
+// deliverMessage grava a mensagem no histórico, enfileira para persistência e
+// entrega aos subscribers locais e às demais instâncias
+// room é nil quando a sala não tem clientes nesta instância
+func (rm *RoomManager) deliverMessage(roomName string, room *Room, roomMsg *RoomMessage) error {
+	// Adiciona ao histórico local e compartilhado
+	if room != nil {
+		room.AddMessage(roomMsg)
+	} else {
+		stampMessage(roomName, roomMsg)
+	}
+	rm.appendHistory(roomName, roomMsg)
+
+	// Enfileira para persistência com o ID e o horário já atribuídos
+	rm.enqueueMessage(roomName, roomMsg)
+
+	data, err := messageFrame(roomMsg)
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem: %v", err)
+		return err
+	}
+
+	// Envia para todos os subscribers locais
+	var subscribers []*Client
+	if room != nil {
+		subscribers = room.GetSubscribers()
+		rm.sendToClients(subscribers, data, nil)
+	}
+
+	// Propaga para as demais instâncias
+	rm.publishRemote(&RoomEvent{
+		Type:    "message",
+		Room:    roomName,
+		Message: roomMsg,
+		Frame:   data,
+	})
 
 	log.Printf("Mensagem publicada na sala %s para %d clientes", roomName, len(subscribers))
 
@@ -149,6 +271,10 @@ func (rm *RoomManager) Publish(client *Client, roomName string, payload interfac
 
 // AddPresence adiciona presence tracking para um cliente em uma sala
 func (rm *RoomManager) AddPresence(client *Client, roomName string) error {
+	if !rm.authorize(client, roomName, ActionPresence) {
+		return nil
+	}
+
 	room := rm.GetOrCreateRoom(roomName)
 	room.AddPresence(client)
 
@@ -157,10 +283,12 @@ func (rm *RoomManager) AddPresence(client *Client, roomName string) error {
 	client.presenceRooms[roomName] = true
 	client.mu.Unlock()
 
+	rm.joinPresence(roomName, client)
+
 	log.Printf("Cliente %p adicionado ao presence da sala: %s", client, roomName)
 
 	// Envia lista atual de presença para o cliente
-	presenceList := room.GetPresenceList()
+	presenceList := rm.getPresenceList(room)
 	rm.sendPresenceListToClient(client, roomName, presenceList)
 
 	// Notifica outros clientes sobre a entrada
@@ -183,10 +311,14 @@ func (rm *RoomManager) RemovePresence(client *Client, roomName string) {
 	delete(client.presenceRooms, roomName)
 	client.mu.Unlock()
 
+	rm.leavePresence(room, client)
+
 	log.Printf("Cliente %p removido do presence da sala: %s", client, roomName)
 
-	// Notifica outros clientes sobre a saída
-	rm.notifyPresenceEvent(room, "user_left", client.userInfo)
+	// Notifica outros clientes sobre a saída, se era a última conexão do usuário na sala
+	if !rm.stillPresent(room, client.GetUserID()) {
+		rm.notifyPresenceEvent(room, "user_left", client.userInfo)
+	}
 
 	// Remove sala se estiver vazia
 	if room.IsEmpty() {
@@ -262,9 +394,8 @@ func (rm *RoomManager) sendPresenceListToClient(client *Client, roomName string,
 }
 
 // notifyPresenceEvent notifica evento de presença para todos os clientes
+// da sala, nesta e nas demais instâncias
 func (rm *RoomManager) notifyPresenceEvent(room *Room, eventType string, userInfo map[string]interface{}) {
-	presenceClients := room.GetPresenceClients()
-
 	data, err := json.Marshal(map[string]interface{}{
 		"type":  eventType,
 		"room":  room.name,
@@ -275,6 +406,20 @@ func (rm *RoomManager) notifyPresenceEvent(room *Room, eventType string, userInf
 		return
 	}
 
+	rm.deliverPresenceEvent(room, eventType, userInfo, data)
+
+	rm.publishRemote(&RoomEvent{
+		Type:  eventType,
+		Room:  room.name,
+		User:  userInfo,
+		Frame: data,
+	})
+}
+
+// deliverPresenceEvent entrega um evento de presença aos clientes locais com presence
+func (rm *RoomManager) deliverPresenceEvent(room *Room, eventType string, userInfo map[string]interface{}, data []byte) {
+	presenceClients := room.GetPresenceClients()
+
 	for _, client := range presenceClients {
 		// Não notifica o próprio cliente sobre sua entrada
 		if eventType == "user_joined" && client.userInfo != nil {
@@ -293,21 +438,25 @@ func (rm *RoomManager) notifyPresenceEvent(room *Room, eventType string, userInf
 	}
 }
 
-// broadcastToClients envia mensagem para uma lista de clientes
-func (rm *RoomManager) broadcastToClients(clients []*Client, msg *RoomMessage) {
-	data, err := json.Marshal(map[string]interface{}{
+// messageFrame serializa uma mensagem de sala no formato enviado aos clientes
+func messageFrame(msg *RoomMessage) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
 		"type":      "message",
 		"messageId": msg.ID,
 		"payload":   msg.Payload,
 		"user":      msg.User,
 		"metadata":  msg.Metadata,
 	})
-	if err != nil {
-		log.Printf("Erro ao serializar mensagem: %v", err)
-		return
-	}
+}
 
+// sendToClients envia um frame já serializado para uma lista de clientes
+// O cliente em except (se houver) é ignorado
+func (rm *RoomManager) sendToClients(clients []*Client, data []byte, except *Client) {
 	for _, client := range clients {
+		if client == except {
+			continue
+		}
+
 		select {
 		case client.send <- data:
 		default:
@@ -326,6 +475,28 @@ func (rm *RoomManager) GetRoomStats(roomName string) map[string]interface{} {
 	return room.GetMetadata()
 }
 
+// ListRooms retorna as estatísticas das salas ativas nesta instância, ordenadas por nome
+func (rm *RoomManager) ListRooms() []map[string]interface{} {
+	rm.mu.RLock()
+	rooms := make([]*Room, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		rooms = append(rooms, room)
+	}
+	rm.mu.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].name < rooms[j].name
+	})
+
+	stats := make([]map[string]interface{}, 0, len(rooms))
+	for _, room := range rooms {
+		meta := room.GetMetadata()
+		meta["room"] = room.name
+		stats = append(stats, meta)
+	}
+	return stats
+}
+
 // BroadcastTyping envia indicador de digitação para todos na sala
 func (rm *RoomManager) BroadcastTyping(client *Client, roomName string, isTyping bool) {
 	room := rm.GetRoom(roomName)
@@ -334,6 +505,10 @@ func (rm *RoomManager) BroadcastTyping(client *Client, roomName string, isTyping
 		return
 	}
 
+	if !rm.authorize(client, roomName, ActionTyping) {
+		return
+	}
+
 	// Obtém todos os subscribers (exceto o próprio cliente)
 	subscribers := room.GetSubscribers()
 
@@ -348,18 +523,14 @@ func (rm *RoomManager) BroadcastTyping(client *Client, roomName string, isTyping
 		return
 	}
 
-	for _, subscriber := range subscribers {
-		// Não envia para o próprio cliente
-		if subscriber == client {
-			continue
-		}
+	// Não envia para o próprio cliente
+	rm.sendToClients(subscribers, data, client)
 
-		select {
-		case subscriber.send <- data:
-		default:
-			log.Printf("Cliente %p não pode receber typing indicator", subscriber)
-		}
-	}
+	rm.publishRemote(&RoomEvent{
+		Type:  "typing",
+		Room:  roomName,
+		Frame: data,
+	})
 
 	log.Printf("Typing indicator enviado na sala %s (isTyping: %v)", roomName, isTyping)
 }
@@ -372,6 +543,11 @@ func (rm *RoomManager) SendReadReceipt(client *Client, roomName string, messageI
 		return
 	}
 
+	// Read receipts vão para os assinantes: exige a mesma permissão de assinar
+	if !rm.authorize(client, roomName, ActionSubscribe) {
+		return
+	}
+
 	// Broadcast para todos na sala (o remetente vai filtrar)
 	subscribers := room.GetSubscribers()
 
@@ -386,37 +562,43 @@ func (rm *RoomManager) SendReadReceipt(client *Client, roomName string, messageI
 		return
 	}
 
-	for _, subscriber := range subscribers {
-		select {
-		case subscriber.send <- data:
-		default:
-			log.Printf("Cliente %p não pode receber read receipt", subscriber)
-		}
-	}
+	rm.sendToClients(subscribers, data, nil)
+
+	rm.publishRemote(&RoomEvent{
+		Type:  "read_receipt",
+		Room:  roomName,
+		Frame: data,
+	})
 
 	log.Printf("Read receipt enviado na sala %s para mensagem %s", roomName, messageID)
 }
 
 // SendDirectMessage envia mensagem direta para um usuário específico
+// O destinatário recebe em todas as suas conexões, nesta e nas demais instâncias
 func (rm *RoomManager) SendDirectMessage(sender *Client, toUserID string, payload interface{}) {
-	// Procura o cliente destino em todas as salas
-	rm.mu.RLock()
-	var targetClient *Client
-	for _, room := range rm.rooms {
-		subscribers := room.GetSubscribers()
-		for _, client := range subscribers {
-			if client.GetUserID() == toUserID {
-				targetClient = client
-				break
-			}
-		}
-		if targetClient != nil {
-			break
-		}
+	// A política avalia a ação direct na sala de usuário do destinatário
+	// As salas da sessão (Rooms/authorizer) não listam destinatários e não se aplicam
+	directRoom := DirectRoomPrefix + toUserID
+	if !rm.isAllowed(sender.GetUserInfo(), directRoom, ActionDirect) {
+		log.Printf("Mensagem direta de %s para %s negada pela política", sender.GetUserID(), toUserID)
+		sendForbidden(sender, directRoom, ActionDirect)
+		return
+	}
+
+	// Envia mensagem para o destinatário
+	data, err := json.Marshal(map[string]interface{}{
+		"type":    "direct_message",
+		"payload": payload,
+		"user":    sender.GetUserInfo(),
+	})
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem direta: %v", err)
+		return
 	}
-	rm.mu.RUnlock()
 
-	if targetClient == nil {
+	localClients, remoteInstances := rm.deliverDirect(toUserID, data)
+
+	if localClients == 0 && remoteInstances == 0 {
 		log.Printf("Usuário destino não encontrado: %s", toUserID)
 		// Envia erro para o remetente
 		errorData, _ := json.Marshal(map[string]interface{}{
@@ -430,23 +612,21 @@ func (rm *RoomManager) SendDirectMessage(sender *Client, toUserID string, payloa
 		return
 	}
 
-	// Envia mensagem para o destinatário
-	data, err := json.Marshal(map[string]interface{}{
-		"type":    "direct_message",
-		"payload": payload,
-		"user":    sender.GetUserInfo(),
-	})
-	if err != nil {
-		log.Printf("Erro ao serializar mensagem direta: %v", err)
-		return
-	}
+	log.Printf("Mensagem direta enviada de %s para %s (%d conexões locais, %d instâncias remotas)",
+		sender.GetUserID(), toUserID, localClients, remoteInstances)
+}
 
-	select {
-	case targetClient.send <- data:
-		log.Printf("Mensagem direta enviada de %s para %s", sender.GetUserID(), toUserID)
-	default:
-		log.Printf("Cliente destino %s não pode receber mensagem", toUserID)
-	}
+// deliverDirect entrega um frame às conexões do usuário nesta e nas demais instâncias
+// Retorna o número de conexões locais e de instâncias remotas alcançadas
+func (rm *RoomManager) deliverDirect(toUserID string, data []byte) (int, int) {
+	// Conexões locais do destinatário
+	targetClients := rm.getLocalClients(toUserID)
+	rm.sendToClients(targetClients, data, nil)
+
+	// Conexões em outras instâncias
+	remoteInstances := rm.routeDirectMessage(toUserID, data)
+
+	return len(targetClients), remoteInstances
 }
 
 // EditMessage edita uma mensagem existente em uma sala
@@ -466,8 +646,22 @@ func (rm *RoomManager) EditMessage(client *Client, roomName string, messageID st
 		return nil
 	}
 
+	if !rm.authorize(client, roomName, ActionEdit) {
+		return nil
+	}
+
+	// Verifica autoria, moderação e janela de edição
+	original, found := room.GetMessage(messageID)
+	if found {
+		if code, reason := rm.checkEdit(client, roomName, original); code != "" {
+			log.Printf("Edição da mensagem %s negada para %s: %s", messageID, client.GetUserID(), code)
+			sendMessageError(client, code, reason, messageID)
+			return nil
+		}
+	}
+
 	// Tenta editar a mensagem
-	editedMsg, found := room.EditMessage(messageID, newPayload)
+	editedMsg, revision, found := room.EditMessage(messageID, newPayload, client.GetUserInfo())
 	if !found {
 		log.Printf("Mensagem não encontrada para edição: %s", messageID)
 		// Envia erro para o cliente
@@ -482,15 +676,20 @@ func (rm *RoomManager) EditMessage(client *Client, roomName string, messageID st
 		return nil
 	}
 
+	rm.updateHistory(roomName, editedMsg)
+	rm.enqueueRevision(roomName, revision)
+
 	// Broadcast da mensagem editada para todos os subscribers
 	subscribers := room.GetSubscribers()
 
 	data, err := json.Marshal(map[string]interface{}{
-		"type":      "message_edited",
-		"room":      roomName,
-		"messageId": messageID,
-		"payload":   editedMsg.Payload,
-		"user":      editedMsg.User,
+		"type":       "message_edited",
+		"room":       roomName,
+		"messageId":  messageID,
+		"payload":    editedMsg.Payload,
+		"user":       editedMsg.User,
+		"editedBy":   revision.EditedBy,
+		"revisionId": revision.ID,
 		"metadata": map[string]interface{}{
 			"room":      roomName,
 			"createdAt": editedMsg.CreatedAt,
@@ -503,13 +702,15 @@ func (rm *RoomManager) EditMessage(client *Client, roomName string, messageID st
 		return err
 	}
 
-	for _, subscriber := range subscribers {
-		select {
-		case subscriber.send <- data:
-		default:
-			log.Printf("Cliente %p não pode receber mensagem editada", subscriber)
-		}
-	}
+	rm.sendToClients(subscribers, data, nil)
+
+	rm.publishRemote(&RoomEvent{
+		Type:     "message_edited",
+		Room:     roomName,
+		Message:  editedMsg,
+		Revision: revision,
+		Frame:    data,
+	})
 
 	log.Printf("Mensagem %s editada na sala %s por %s", messageID, roomName, client.GetUserID())
 
@@ -0,0 +1,188 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/persistence"
+)
+
+const (
+	// Tamanho padrão e máximo de uma página de resultados
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchRequest define uma busca textual nas mensagens persistidas
+type SearchRequest struct {
+	Query  string
+	Rooms  []string  // Salas pedidas; as que o solicitante não pode ler são ignoradas
+	Author string    // ID do autor (opcional)
+	From   time.Time // Início do período (opcional)
+	To     time.Time // Fim do período, exclusivo (opcional)
+	Limit  int
+	Offset int
+}
+
+// SearchPage é uma página de resultados ordenada por relevância
+type SearchPage struct {
+	Results []persistence.SearchResult `json:"results"`
+	Rooms   []string                   `json:"rooms"`   // Salas efetivamente consultadas
+	HasMore bool                       `json:"hasMore"` // Há mais resultados após Offset+Limit
+}
+
+// SearchMessages busca mensagens persistidas nas salas em que canRead permite
+func (rm *RoomManager) SearchMessages(canRead func(roomName string) bool, req SearchRequest) (*SearchPage, error) {
+	archive := rm.messageArchive()
+	if archive == nil {
+		return nil, fmt.Errorf("busca indisponível sem PostgreSQL")
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	rooms := make([]string, 0, len(req.Rooms))
+	seen := make(map[string]bool, len(req.Rooms))
+	for _, roomName := range req.Rooms {
+		if roomName == "" || seen[roomName] {
+			continue
+		}
+		seen[roomName] = true
+		if canRead(roomName) {
+			rooms = append(rooms, roomName)
+		}
+	}
+
+	page := &SearchPage{
+		Results: []persistence.SearchResult{},
+		Rooms:   rooms,
+	}
+	if len(rooms) == 0 {
+		return page, nil
+	}
+
+	// Busca um resultado extra para saber se há próxima página
+	results, err := archive.Search(persistence.SearchQuery{
+		Text:   req.Query,
+		Rooms:  rooms,
+		UserID: req.Author,
+		From:   req.From,
+		To:     req.To,
+		Limit:  limit + 1,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+		page.HasMore = true
+	}
+	page.Results = results
+	return page, nil
+}
+
+// Search executa uma busca pedida pelo cliente e envia um frame search_results
+// Sem salas informadas, busca nas salas em que o cliente está inscrito
+func (rm *RoomManager) Search(client *Client, roomName string, options *EventOptions) {
+	req := SearchRequest{}
+	if options != nil {
+		req.Query = strings.TrimSpace(options.Query)
+		req.Rooms = options.Rooms
+		req.Author = options.Author
+		req.Limit = options.Limit
+		req.Offset = options.Offset
+
+		var err error
+		if req.From, err = ParseSearchTime(options.From); err != nil {
+			sendSearchError(client, "invalid_search", "Data inicial inválida")
+			return
+		}
+		if req.To, err = ParseSearchTime(options.To); err != nil {
+			sendSearchError(client, "invalid_search", "Data final inválida")
+			return
+		}
+	}
+
+	if req.Query == "" {
+		sendSearchError(client, "invalid_search", "Termo de busca obrigatório")
+		return
+	}
+
+	if roomName != "" {
+		req.Rooms = append(req.Rooms, roomName)
+	}
+	if len(req.Rooms) == 0 {
+		client.mu.RLock()
+		for subscribed := range client.roomSubscriptions {
+			req.Rooms = append(req.Rooms, subscribed)
+		}
+		client.mu.RUnlock()
+	}
+
+	// Salas negadas pela política ou pelo authorizer delegado são ignoradas
+	page, err := rm.SearchMessages(func(roomName string) bool {
+		return rm.allowedFor(client, roomName, ActionSubscribe)
+	}, req)
+	if err != nil {
+		log.Printf("Erro na busca de %s: %v", client.GetUserID(), err)
+		sendSearchError(client, "search_unavailable", "Busca indisponível")
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":    "search_results",
+		"query":   req.Query,
+		"rooms":   page.Rooms,
+		"results": page.Results,
+		"hasMore": page.HasMore,
+	})
+	if err != nil {
+		log.Printf("Erro ao serializar resultados da busca: %v", err)
+		return
+	}
+
+	select {
+	case client.send <- data:
+	default:
+		log.Printf("Cliente %p não pode receber resultados da busca", client)
+	}
+}
+
+// ParseSearchTime interpreta uma data de filtro (RFC3339 ou AAAA-MM-DD)
+// Valor vazio retorna o horário zero (sem filtro)
+func ParseSearchTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// sendSearchError envia um erro de busca ao cliente
+func sendSearchError(client *Client, code, reason string) {
+	errorData, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"code":  code,
+		"error": reason,
+	})
+	select {
+	case client.send <- errorData:
+	default:
+	}
+}
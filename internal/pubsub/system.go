@@ -0,0 +1,78 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Prefixo dos IDs de serviços; reservado, clientes não podem usá-lo
+const SystemIDPrefix = "system:"
+
+// SystemUser monta o usuário que assina mensagens publicadas por serviços
+// (API HTTP) em vez de clientes WebSocket
+func SystemUser(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       SystemIDPrefix + name,
+		"username": name,
+		"system":   true,
+	}
+}
+
+// isSystemUser indica se o perfil é (ou se passa por) um serviço
+func isSystemUser(user map[string]interface{}) bool {
+	if system, _ := user["system"].(bool); system {
+		return true
+	}
+	id, _ := user["id"].(string)
+	return strings.HasPrefix(id, SystemIDPrefix)
+}
+
+// PublishSystem publica uma mensagem de um serviço em uma sala
+// Segue o mesmo caminho das publicações de clientes (histórico, outras
+// instâncias e persistência), mesmo sem clientes da sala nesta instância
+// O payload é envelopado como nas publicações dos clientes (PayloadMessage)
+func (rm *RoomManager) PublishSystem(roomName string, payload interface{}, sender map[string]interface{}) (*RoomMessage, error) {
+	room := rm.GetRoom(roomName)
+
+	metadata := map[string]interface{}{}
+	if room != nil {
+		metadata = room.GetMetadata()
+	}
+	metadata["system"] = true
+
+	roomMsg := &RoomMessage{
+		Payload:  messagePayload(payload),
+		User:     sender,
+		Metadata: metadata,
+	}
+
+	if err := rm.deliverMessage(roomName, room, roomMsg); err != nil {
+		return nil, err
+	}
+	return roomMsg, nil
+}
+
+// SendSystemMessage envia uma mensagem direta de um serviço para um usuário
+// Retorna false se o usuário não tem conexões em nenhuma instância
+func (rm *RoomManager) SendSystemMessage(toUserID string, payload interface{}, sender map[string]interface{}) (bool, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":     "direct_message",
+		"payload":  payload,
+		"user":     sender,
+		"metadata": map[string]interface{}{"system": true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("erro ao serializar mensagem direta: %w", err)
+	}
+
+	localClients, remoteInstances := rm.deliverDirect(toUserID, data)
+	if localClients == 0 && remoteInstances == 0 {
+		return false, nil
+	}
+
+	log.Printf("Mensagem de sistema enviada de %v para %s (%d conexões locais, %d instâncias remotas)",
+		sender["id"], toUserID, localClients, remoteInstances)
+	return true, nil
+}
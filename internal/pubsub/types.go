@@ -1,17 +1,24 @@
 package pubsub
 
+import "encoding/json"
+
 // EventType representa os tipos de eventos suportados
 type EventType string
 
 const (
-	EventSubscribe   EventType = "subscribe"
-	EventUnsubscribe EventType = "unsubscribe"
-	EventPublish     EventType = "publish"
-	EventPresence    EventType = "presence"
-	EventTyping      EventType = "typing"       // Indicador de digitação
-	EventReadReceipt EventType = "read_receipt" // Confirmação de leitura
-	EventDirectMsg   EventType = "direct_msg"   // Mensagem direta
-	EventEditMessage EventType = "edit_message" // Edição de mensagem
+	EventSubscribe     EventType = "subscribe"
+	EventUnsubscribe   EventType = "unsubscribe"
+	EventPublish       EventType = "publish"
+	EventPresence      EventType = "presence"
+	EventTyping        EventType = "typing"         // Indicador de digitação
+	EventReadReceipt   EventType = "read_receipt"   // Confirmação de leitura
+	EventDirectMsg     EventType = "direct_msg"     // Mensagem direta
+	EventEditMessage   EventType = "edit_message"   // Edição de mensagem
+	EventGetRevisions  EventType = "get_revisions"  // Lista de revisões de uma mensagem
+	EventDeleteMessage EventType = "delete_message" // Exclusão de mensagem
+	EventFetchHistory  EventType = "fetch_history"  // Página de histórico com cursores
+	EventSearch        EventType = "search"         // Busca textual no histórico persistido
+	EventReauth        EventType = "reauth"         // Renovação da credencial
 )
 
 // ClientEvent representa um evento recebido do cliente
@@ -24,12 +31,26 @@ type ClientEvent struct {
 	ToUserID  string                 `json:"toUserId,omitempty"`  // Para mensagens diretas
 	MessageID string                 `json:"messageId,omitempty"` // Para read receipts
 	IsTyping  bool                   `json:"isTyping,omitempty"`  // Para typing indicators
+	Token     string                 `json:"token,omitempty"`     // Para reauth
 }
 
 // EventOptions contém opções para eventos
 type EventOptions struct {
 	History bool `json:"history,omitempty"`
 	Limit   int  `json:"limit,omitempty"`
+	Purge   bool `json:"purge,omitempty"` // delete_message: apaga definitivamente (moderadores)
+
+	// fetch_history: cursores por ID de mensagem (Limit é o tamanho da página)
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+
+	// search: termos, salas (padrão: salas inscritas), autor e período (RFC3339 ou AAAA-MM-DD)
+	Query  string   `json:"query,omitempty"`
+	Rooms  []string `json:"rooms,omitempty"`
+	Author string   `json:"author,omitempty"`
+	From   string   `json:"from,omitempty"`
+	To     string   `json:"to,omitempty"`
+	Offset int      `json:"offset,omitempty"`
 }
 
 // SubscribeOptions contém opções para subscribe
@@ -43,3 +64,34 @@ type PayloadMessage struct {
 	Message interface{} `json:"message"`
 	Type    string      `json:"type"` // "text", "image", "file", etc
 }
+
+// messagePayload envelopa o payload publicado como PayloadMessage
+// Maps com o campo message já estão no formato e são mantidos
+func messagePayload(payload interface{}) interface{} {
+	if payloadMap, ok := payload.(map[string]interface{}); ok {
+		if _, hasMessage := payloadMap["message"]; hasMessage {
+			return payload
+		}
+	}
+	return PayloadMessage{
+		Message: payload,
+		Type:    "text", // default
+	}
+}
+
+// RoomEvent representa um evento de sala propagado entre instâncias
+type RoomEvent struct {
+	Type     string                 `json:"type"` // message, typing, read_receipt, message_edited, message_deleted, user_joined, user_left
+	Room     string                 `json:"room"`
+	Message  *RoomMessage           `json:"message,omitempty"`  // Mensagem completa (message e message_edited)
+	User     map[string]interface{} `json:"user,omitempty"`     // Usuário do evento de presença
+	Revision *MessageRevision       `json:"revision,omitempty"` // Revisão criada (message_edited)
+	Purge    bool                   `json:"purge,omitempty"`    // Exclusão definitiva (message_deleted)
+	Frame    json.RawMessage        `json:"frame"`              // Frame já serializado para os clientes
+}
+
+// DirectEvent representa uma mensagem direta encaminhada para outra instância
+type DirectEvent struct {
+	ToUserID string          `json:"toUserId"`
+	Frame    json.RawMessage `json:"frame"` // Frame já serializado para o destinatário
+}
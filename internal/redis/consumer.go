@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -15,50 +16,98 @@ type MessageProcessor interface {
 	ProcessBatch(messages []*StreamMessage) error
 }
 
+// MessageError indica que a falha é da própria mensagem (dados inválidos,
+// violação de constraint) e não da infraestrutura. A entrada fica pendente e
+// volta pelo XAUTOCLAIM até esgotar MaxDeliveries; nas demais falhas o batch
+// fica retido no consumer e é reprocessado sem gerar novas entregas
+type MessageError struct {
+	Err error
+}
+
+func (e *MessageError) Error() string { return e.Err.Error() }
+func (e *MessageError) Unwrap() error { return e.Err }
+
+// isMessageError indica se o erro é uma falha da própria mensagem
+func isMessageError(err error) bool {
+	var messageErr *MessageError
+	return errors.As(err, &messageErr)
+}
+
 // StreamConsumer consome mensagens do Redis Stream usando Consumer Groups
 type StreamConsumer struct {
-	client       *redis.Client
-	ctx          context.Context
-	cancel       context.CancelFunc
-	consumerID   string
-	batchSize    int64
-	batchTimeout time.Duration
-	processor    MessageProcessor
+	client        redis.UniversalClient
+	ctx           context.Context
+	cancel        context.CancelFunc
+	consumerID    string
+	batchSize     int64
+	batchTimeout  time.Duration
+	claimInterval time.Duration
+	claimMinIdle  time.Duration
+	maxDeliveries int64
+	processor     MessageProcessor
+
+	// Último erro da própria mensagem por entrada pendente neste consumer,
+	// anexado ao mover a entrada para a DLQ. Acessado apenas pelo loop de consumo
+	failures map[string]string
+
+	// Batch retido por falha de infraestrutura, reprocessado no lugar do
+	// XAUTOCLAIM até a infraestrutura voltar. Acessado apenas pelo loop de consumo
+	stalled    []*StreamMessage
+	stalledIDs []string
 }
 
 // ConsumerConfig configurações do consumer
 type ConsumerConfig struct {
-	RedisURL     string
 	ConsumerID   string
 	BatchSize    int64
 	BatchTimeout time.Duration
+
+	// Intervalo entre execuções do XAUTOCLAIM (padrão DefaultClaimInterval)
+	ClaimInterval time.Duration
+
+	// Tempo mínimo sem ACK para uma entrada ser reivindicada (padrão DefaultClaimMinIdle)
+	ClaimMinIdle time.Duration
+
+	// Entregas (contador do PEL) antes de mover a entrada para a DLQ (0 = sem limite)
+	// O contador sobrevive a reinícios do worker e acompanha a entrada entre workers.
+	// Falhas de infraestrutura (ex.: PostgreSQL fora do ar) não geram novas entregas
+	MaxDeliveries int64
 }
 
-// NewStreamConsumer cria um novo consumidor de streams
-func NewStreamConsumer(config ConsumerConfig, processor MessageProcessor) (*StreamConsumer, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: config.RedisURL,
-	})
+const (
+	// Intervalo padrão entre execuções do XAUTOCLAIM
+	DefaultClaimInterval = 30 * time.Second
 
-	// Testa a conexão
-	ctx := context.Background()
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("falha ao conectar no Redis: %w", err)
-	}
+	// Tempo padrão sem ACK para reivindicar uma entrada de outro worker
+	DefaultClaimMinIdle = time.Minute
+)
 
+// NewStreamConsumer cria um novo consumidor de streams sobre o cliente compartilhado
+func NewStreamConsumer(client redis.UniversalClient, config ConsumerConfig, processor MessageProcessor) (*StreamConsumer, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	if config.ClaimInterval <= 0 {
+		config.ClaimInterval = DefaultClaimInterval
+	}
+	if config.ClaimMinIdle <= 0 {
+		config.ClaimMinIdle = DefaultClaimMinIdle
+	}
+
 	consumer := &StreamConsumer{
-		client:       client,
-		ctx:          ctx,
-		cancel:       cancel,
-		consumerID:   config.ConsumerID,
-		batchSize:    config.BatchSize,
-		batchTimeout: config.BatchTimeout,
-		processor:    processor,
+		client:        client,
+		ctx:           ctx,
+		cancel:        cancel,
+		consumerID:    config.ConsumerID,
+		batchSize:     config.BatchSize,
+		batchTimeout:  config.BatchTimeout,
+		claimInterval: config.ClaimInterval,
+		claimMinIdle:  config.ClaimMinIdle,
+		maxDeliveries: config.MaxDeliveries,
+		processor:     processor,
+		failures:      make(map[string]string),
 	}
 
-	log.Printf("[Redis Consumer] Consumidor '%s' conectado ao Redis", config.ConsumerID)
+	log.Printf("[Redis Consumer] Consumidor '%s' iniciado", config.ConsumerID)
 
 	return consumer, nil
 }
@@ -68,6 +117,9 @@ func (sc *StreamConsumer) Start() error {
 	log.Printf("[Redis Consumer] Iniciando consumo do stream '%s' com consumer group '%s'",
 		MessagesStream, PersistConsumerGroup)
 
+	// Reprocessa entradas que este worker recebeu e não confirmou antes de parar
+	sc.recoverOwnPending()
+
 	// Goroutine para processar mensagens
 	go sc.consumeLoop()
 
@@ -79,6 +131,9 @@ func (sc *StreamConsumer) consumeLoop() {
 	ticker := time.NewTicker(sc.batchTimeout)
 	defer ticker.Stop()
 
+	claimTicker := time.NewTicker(sc.claimInterval)
+	defer claimTicker.Stop()
+
 	batch := make([]*StreamMessage, 0, sc.batchSize)
 	messageIDs := make([]string, 0, sc.batchSize)
 
@@ -100,7 +155,35 @@ func (sc *StreamConsumer) consumeLoop() {
 				messageIDs = messageIDs[:0]
 			}
 
+		case <-claimTicker.C:
+			// Infraestrutura fora: reprocessa o batch retido em vez de reivindicar
+			// entradas, para não gastar entregas durante a indisponibilidade
+			if len(sc.stalled) > 0 {
+				sc.retryStalled()
+				continue
+			}
+
+			// Esquece falhas de entradas que saíram do PEL deste consumer
+			sc.pruneFailures()
+
+			// Reivindica entradas paradas (worker morto ou batch que falhou)
+			messages, ids := sc.claimIdle()
+			batch = append(batch, messages...)
+			messageIDs = append(messageIDs, ids...)
+
+			if len(batch) >= int(sc.batchSize) {
+				sc.processBatch(batch, messageIDs)
+				batch = batch[:0]
+				messageIDs = messageIDs[:0]
+			}
+
 		default:
+			// Não lê entradas novas enquanto a infraestrutura estiver fora
+			if len(sc.stalled) > 0 {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
 			// Tenta ler mensagens do stream
 			messages, ids, err := sc.readMessages()
 			if err != nil {
@@ -150,15 +233,28 @@ func (sc *StreamConsumer) readMessages() ([]*StreamMessage, []string, error) {
 		return nil, nil, nil
 	}
 
-	messages := make([]*StreamMessage, 0, len(streams[0].Messages))
-	ids := make([]string, 0, len(streams[0].Messages))
+	messages, ids := sc.parseEntries(streams[0].Messages)
+	return messages, ids, nil
+}
+
+// parseEntries converte entradas do stream, descartando as inválidas
+func (sc *StreamConsumer) parseEntries(entries []redis.XMessage) ([]*StreamMessage, []string) {
+	messages := make([]*StreamMessage, 0, len(entries))
+	ids := make([]string, 0, len(entries))
+
+	for _, msg := range entries {
+		// Entradas já removidas pelo trim voltam sem campos no PEL
+		if len(msg.Values) == 0 {
+			sc.client.XAck(sc.ctx, MessagesStream, PersistConsumerGroup, msg.ID)
+			continue
+		}
 
-	for _, msg := range streams[0].Messages {
 		streamMsg, err := sc.parseMessage(msg)
 		if err != nil {
 			log.Printf("[Redis Consumer] Erro ao parsear mensagem %s: %v", msg.ID, err)
-			// XACK mesmo com erro para não reprocessar
-			sc.client.XAck(sc.ctx, MessagesStream, PersistConsumerGroup, msg.ID)
+			// Entradas malformadas nunca vão funcionar; vão direto para a DLQ
+			attempts := sc.deliveryCounts([]redis.XMessage{msg})[msg.ID]
+			sc.deadLetter(msg, err.Error(), attempts)
 			continue
 		}
 
@@ -166,13 +262,49 @@ func (sc *StreamConsumer) readMessages() ([]*StreamMessage, []string, error) {
 		ids = append(ids, msg.ID)
 	}
 
-	return messages, ids, nil
+	return messages, ids
 }
 
 // parseMessage parseia uma mensagem do Redis Stream
 func (sc *StreamConsumer) parseMessage(msg redis.XMessage) (*StreamMessage, error) {
+	roomName, ok := msg.Values["room_name"].(string)
+	if !ok || roomName == "" {
+		return nil, fmt.Errorf("campo room_name ausente")
+	}
+
 	streamMsg := &StreamMessage{
-		RoomName: msg.Values["room_name"].(string),
+		RoomName: roomName,
+		StreamID: msg.ID,
+	}
+
+	// Entradas devolvidas da DLQ preservam o ID da entrada original
+	if sourceID, ok := msg.Values[sourceIDField].(string); ok && sourceID != "" {
+		streamMsg.StreamID = sourceID
+	}
+
+	if kind, ok := msg.Values["kind"].(string); ok {
+		streamMsg.Kind = kind
+	}
+
+	if revisionID, ok := msg.Values["revision_id"].(string); ok {
+		streamMsg.RevisionID = revisionID
+	}
+
+	if messageID, ok := msg.Values["message_id"].(string); ok {
+		streamMsg.MessageID = messageID
+	}
+
+	if instanceID, ok := msg.Values["instance_id"].(string); ok {
+		streamMsg.InstanceID = instanceID
+	}
+
+	// Entradas antigas não têm created_at; o repositório usa o horário da gravação
+	if createdAt, ok := msg.Values["created_at"].(string); ok && createdAt != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao parsear created_at: %w", err)
+		}
+		streamMsg.CreatedAt = parsed
 	}
 
 	if userID, ok := msg.Values["user_id"].(string); ok {
@@ -192,6 +324,15 @@ func (sc *StreamConsumer) parseMessage(msg redis.XMessage) (*StreamMessage, erro
 		streamMsg.Payload = payload
 	}
 
+	// Parse payload anterior (revisões)
+	if previousStr, ok := msg.Values["previous_payload"].(string); ok && previousStr != "" {
+		var previous map[string]interface{}
+		if err := json.Unmarshal([]byte(previousStr), &previous); err != nil {
+			return nil, fmt.Errorf("erro ao parsear payload anterior: %w", err)
+		}
+		streamMsg.PreviousPayload = previous
+	}
+
 	// Parse metadata JSON
 	if metadataStr, ok := msg.Values["metadata"].(string); ok && metadataStr != "" {
 		var metadata map[string]interface{}
@@ -205,34 +346,83 @@ func (sc *StreamConsumer) parseMessage(msg redis.XMessage) (*StreamMessage, erro
 }
 
 // processBatch processa um batch de mensagens
-func (sc *StreamConsumer) processBatch(batch []*StreamMessage, ids []string) {
+// Retorna false se o batch ficou sem ACK e permanece pendente
+func (sc *StreamConsumer) processBatch(batch []*StreamMessage, ids []string) bool {
 	log.Printf("[Redis Consumer] Processando batch de %d mensagens", len(batch))
 
 	// Processa através do processor
 	if err := sc.processor.ProcessBatch(batch); err != nil {
 		log.Printf("[Redis Consumer] Erro ao processar batch: %v", err)
-		// Em caso de erro, não fazemos ACK e as mensagens serão reprocessadas
-		return
+		if !isMessageError(err) {
+			// Falha de infraestrutura: sem ACK as entradas ficam pendentes e o
+			// batch fica retido para nova tentativa (ver retryStalled)
+			sc.stall(batch, ids)
+			return false
+		}
+		if len(batch) == 1 {
+			// Sem ACK: a entrada fica pendente e volta pelo XAUTOCLAIM
+			sc.recordFailure(ids[0], err)
+			return false
+		}
+		// Processa uma a uma para que uma entrada ruim não trave as demais
+		return sc.processIndividually(batch, ids)
 	}
 
 	// Faz ACK de todas as mensagens processadas com sucesso
 	if len(ids) > 0 {
 		if err := sc.client.XAck(sc.ctx, MessagesStream, PersistConsumerGroup, ids...).Err(); err != nil {
 			log.Printf("[Redis Consumer] Erro ao fazer ACK: %v", err)
-		} else {
-			log.Printf("[Redis Consumer] ACK de %d mensagens realizado", len(ids))
+			return false
+		}
+		log.Printf("[Redis Consumer] ACK de %d mensagens realizado", len(ids))
+		for _, id := range ids {
+			delete(sc.failures, id)
 		}
 	}
+	return true
+}
+
+// recordFailure guarda o erro da própria mensagem para anexá-lo na DLQ
+// A contagem de tentativas vem do PEL (ver deadLetterExhausted)
+func (sc *StreamConsumer) recordFailure(id string, err error) {
+	sc.failures[id] = err.Error()
+}
+
+// stall retém um batch que falhou por causa da infraestrutura
+// O batch recebido pode ser reaproveitado pelo chamador; aqui vai uma cópia
+func (sc *StreamConsumer) stall(batch []*StreamMessage, ids []string) {
+	sc.stalled = append(sc.stalled, batch...)
+	sc.stalledIDs = append(sc.stalledIDs, ids...)
+}
+
+// retryStalled reprocessa o batch retido por falha de infraestrutura
+// As entradas continuam no PEL deste consumer e não recebem nova entrega;
+// se a falha persistir, o batch volta a ficar retido
+func (sc *StreamConsumer) retryStalled() {
+	batch, ids := sc.stalled, sc.stalledIDs
+	sc.stalled, sc.stalledIDs = nil, nil
+
+	log.Printf("[Redis Consumer] Reprocessando %d mensagens retidas por falha de infraestrutura", len(ids))
+	sc.processBatch(batch, ids)
+}
+
+// processIndividually reprocessa um batch que falhou mensagem a mensagem
+// Retorna false se alguma mensagem continuou falhando
+func (sc *StreamConsumer) processIndividually(batch []*StreamMessage, ids []string) bool {
+	ok := true
+	for i := range batch {
+		if !sc.processBatch(batch[i:i+1], ids[i:i+1]) {
+			ok = false
+		}
+	}
+	return ok
 }
 
 // Stop para o consumidor
+// O cliente compartilhado é fechado por quem o criou
 func (sc *StreamConsumer) Stop() error {
 	sc.cancel()
 
-	if err := sc.client.Close(); err != nil {
-		return fmt.Errorf("erro ao fechar cliente Redis: %w", err)
-	}
-
 	log.Println("[Redis Consumer] Consumidor parado")
 	return nil
 }
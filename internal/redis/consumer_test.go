@@ -0,0 +1,226 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProcessor devolve os erros programados, um por chamada, e registra os batches
+type fakeProcessor struct {
+	mu      sync.Mutex
+	errs    []error
+	batches [][]*StreamMessage
+}
+
+func (p *fakeProcessor) ProcessBatch(messages []*StreamMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.batches = append(p.batches, append([]*StreamMessage(nil), messages...))
+	if len(p.errs) == 0 {
+		return nil
+	}
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+	return err
+}
+
+func (p *fakeProcessor) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.batches)
+}
+
+// testEntry grava uma mensagem válida em MessagesStream
+func testEntry(fake *FakeRedis, messageID string) string {
+	return fake.Add(MessagesStream, map[string]interface{}{
+		"room_name":  "geral",
+		"message_id": messageID,
+		"payload":    `{"text":"oi"}`,
+	})
+}
+
+func TestDeadLetterExhausted(t *testing.T) {
+	tests := []struct {
+		name       string
+		deliveries int64
+		reason     string
+		wantDLQ    bool
+		wantError  string
+	}{
+		{"abaixo do limite", 2, "", false, ""},
+		{"no limite ainda processa", 3, "erro de dados", false, ""},
+		{"acima do limite com erro registrado", 4, "erro de dados", true, "erro de dados"},
+		{"acima do limite sem registro local", 4, "", true, "4 entregas sem ACK (worker reiniciado ou entrada de outro worker)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := NewFakeRedis(t)
+			consumer, err := NewStreamConsumer(client, ConsumerConfig{ConsumerID: "w1", BatchSize: 10, MaxDeliveries: 3}, &fakeProcessor{})
+			if err != nil {
+				t.Fatalf("NewStreamConsumer: %v", err)
+			}
+
+			id := testEntry(fake, "m1")
+			fake.deliver(id, "w1", tt.deliveries, 0)
+			if tt.reason != "" {
+				consumer.recordFailure(id, errors.New(tt.reason))
+			}
+
+			kept := consumer.deadLetterExhausted(fake.Entries(MessagesStream))
+
+			dlq := fake.Entries(DeadLetterStream)
+			if tt.wantDLQ != (len(dlq) == 1) || tt.wantDLQ == (len(kept) == 1) {
+				t.Fatalf("DLQ = %d entradas, mantidas = %d; want DLQ %v", len(dlq), len(kept), tt.wantDLQ)
+			}
+			if !tt.wantDLQ {
+				return
+			}
+
+			letter := parseDeadLetter(dlq[0])
+			if letter.Error != tt.wantError || letter.Attempts != tt.deliveries || letter.SourceID != id {
+				t.Errorf("DLQ = %+v, want erro %q, %d tentativas, origem %s", letter, tt.wantError, tt.deliveries, id)
+			}
+			if _, pending := fake.Deliveries(id); pending {
+				t.Error("entrada movida para a DLQ continua pendente")
+			}
+		})
+	}
+}
+
+func TestRecoverOwnPendingCountsRestart(t *testing.T) {
+	tests := []struct {
+		name       string
+		deliveries int64
+		wantCalls  int
+		wantDLQ    int
+	}{
+		{"entrada com entregas restantes é processada", 1, 1, 0},
+		{"entrada que derrubou o worker vai para a DLQ", 3, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := NewFakeRedis(t)
+			id := testEntry(fake, "m1")
+			// Entregue a este worker antes de ele reiniciar
+			fake.deliver(id, "w1", tt.deliveries, time.Hour)
+
+			processor := &fakeProcessor{}
+			consumer, err := NewStreamConsumer(client, ConsumerConfig{ConsumerID: "w1", BatchSize: 10, MaxDeliveries: 3}, processor)
+			if err != nil {
+				t.Fatalf("NewStreamConsumer: %v", err)
+			}
+
+			consumer.recoverOwnPending()
+
+			if processor.calls() != tt.wantCalls {
+				t.Errorf("processor chamado %d vezes, want %d", processor.calls(), tt.wantCalls)
+			}
+			if got := len(fake.Entries(DeadLetterStream)); got != tt.wantDLQ {
+				t.Errorf("DLQ = %d entradas, want %d", got, tt.wantDLQ)
+			}
+			if _, pending := fake.Deliveries(id); pending {
+				t.Error("entrada continua pendente")
+			}
+		})
+	}
+}
+
+func TestClaimIdleCountsDeliveriesFromOtherWorkers(t *testing.T) {
+	fake, client := NewFakeRedis(t)
+	exhausted := testEntry(fake, "m1")
+	fresh := testEntry(fake, "m2")
+
+	// Entradas paradas no PEL de outro worker; w2 não tem registro local de falhas
+	fake.deliver(exhausted, "w1", 3, time.Hour)
+	fake.deliver(fresh, "w1", 1, time.Hour)
+
+	consumer, err := NewStreamConsumer(client, ConsumerConfig{ConsumerID: "w2", BatchSize: 10, MaxDeliveries: 3, ClaimMinIdle: time.Minute}, &fakeProcessor{})
+	if err != nil {
+		t.Fatalf("NewStreamConsumer: %v", err)
+	}
+
+	messages, ids := consumer.claimIdle()
+
+	if len(ids) != 1 || ids[0] != fresh || messages[0].MessageID != "m2" {
+		t.Errorf("reivindicadas = %v, want [%s]", ids, fresh)
+	}
+	dlq := fake.Entries(DeadLetterStream)
+	if len(dlq) != 1 || parseDeadLetter(dlq[0]).SourceID != exhausted {
+		t.Errorf("DLQ = %v, want a entrada %s", dlq, exhausted)
+	}
+	if deliveries, _ := fake.Deliveries(fresh); deliveries != 2 {
+		t.Errorf("entregas de %s = %d, want 2", fresh, deliveries)
+	}
+}
+
+func TestInfrastructureFailureRetainsBatch(t *testing.T) {
+	fake, client := NewFakeRedis(t)
+	id := testEntry(fake, "m1")
+	fake.deliver(id, "w1", 1, 0)
+
+	processor := &fakeProcessor{errs: []error{errors.New("conexão recusada"), errors.New("conexão recusada")}}
+	consumer, err := NewStreamConsumer(client, ConsumerConfig{ConsumerID: "w1", BatchSize: 10, MaxDeliveries: 1}, processor)
+	if err != nil {
+		t.Fatalf("NewStreamConsumer: %v", err)
+	}
+
+	messages, ids := consumer.parseEntries(fake.Entries(MessagesStream))
+	if consumer.processBatch(messages, ids) {
+		t.Fatal("batch com falha de infraestrutura confirmado")
+	}
+	if len(consumer.stalledIDs) != 1 {
+		t.Fatalf("batch retido = %v, want [%s]", consumer.stalledIDs, id)
+	}
+
+	// Nova falha: o batch continua retido e nenhuma entrega é gerada
+	consumer.retryStalled()
+	if len(consumer.stalledIDs) != 1 {
+		t.Fatalf("batch retido = %v após nova falha, want [%s]", consumer.stalledIDs, id)
+	}
+
+	// Infraestrutura de volta: o batch passa e recebe ACK
+	consumer.retryStalled()
+	if len(consumer.stalledIDs) != 0 {
+		t.Errorf("batch continua retido após sucesso: %v", consumer.stalledIDs)
+	}
+	if _, pending := fake.Deliveries(id); pending {
+		t.Error("entrada continua pendente após sucesso")
+	}
+	if processor.calls() != 3 {
+		t.Errorf("processor chamado %d vezes, want 3", processor.calls())
+	}
+	if len(fake.Entries(DeadLetterStream)) != 0 {
+		t.Error("falha de infraestrutura levou a entrada para a DLQ")
+	}
+}
+
+func TestMessageErrorKeepsEntryPendingWithReason(t *testing.T) {
+	fake, client := NewFakeRedis(t)
+	id := testEntry(fake, "m1")
+	fake.deliver(id, "w1", 1, 0)
+
+	processor := &fakeProcessor{errs: []error{&MessageError{Err: errors.New("violação de constraint")}}}
+	consumer, err := NewStreamConsumer(client, ConsumerConfig{ConsumerID: "w1", BatchSize: 10, MaxDeliveries: 1}, processor)
+	if err != nil {
+		t.Fatalf("NewStreamConsumer: %v", err)
+	}
+
+	messages, ids := consumer.parseEntries(fake.Entries(MessagesStream))
+	if consumer.processBatch(messages, ids) {
+		t.Fatal("batch com erro da mensagem confirmado")
+	}
+	if len(consumer.stalledIDs) != 0 {
+		t.Error("erro da mensagem reteve o batch como falha de infraestrutura")
+	}
+	if consumer.failures[id] != "violação de constraint" {
+		t.Errorf("erro registrado = %q", consumer.failures[id])
+	}
+	if _, pending := fake.Deliveries(id); !pending {
+		t.Error("entrada saiu do PEL sem ser persistida")
+	}
+}
@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Prefixo dos hashes de sessão por usuário (gosocket:sessions:{userID})
+	// Cada campo é uma instância com conexões do usuário
+	SessionKeyPrefix = "gosocket:sessions:"
+
+	// Tempo sem heartbeat após o qual uma sessão é descartada
+	DefaultSessionTTL = 30 * time.Second
+)
+
+// SessionKey retorna a chave do hash de sessões de um usuário
+func SessionKey(userID string) string {
+	return SessionKeyPrefix + userID
+}
+
+// SessionDirectory mapeia usuários para as instâncias onde estão conectados
+// As entradas são renovadas por heartbeat e expiram se a instância cair
+type SessionDirectory struct {
+	heartbeat
+
+	// Usuários com conexões nesta instância
+	local map[string]bool
+	mu    sync.Mutex
+}
+
+// NewSessionDirectory cria um novo diretório de sessões sobre o cliente compartilhado
+func NewSessionDirectory(client redis.UniversalClient, instanceID string, ttl time.Duration) (*SessionDirectory, error) {
+	directory := &SessionDirectory{
+		heartbeat: newHeartbeat(client, instanceID, ttl, DefaultSessionTTL, "[Redis Sessions]"),
+		local:     make(map[string]bool),
+	}
+
+	directory.start(directory.refresh)
+
+	log.Printf("[Redis Sessions] Diretório de sessões iniciado (instance: %s, ttl: %s)", instanceID, directory.ttl)
+
+	return directory, nil
+}
+
+// Register registra que o usuário tem conexões nesta instância
+func (sd *SessionDirectory) Register(userID string) error {
+	sd.mu.Lock()
+	sd.local[userID] = true
+	sd.mu.Unlock()
+
+	key := SessionKey(userID)
+	_, err := sd.client.Pipelined(sd.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(sd.ctx, key, sd.instanceID, time.Now().UnixMilli())
+		pipe.PExpire(sd.ctx, key, sd.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao registrar sessão: %w", err)
+	}
+
+	return nil
+}
+
+// Unregister remove o usuário desta instância
+func (sd *SessionDirectory) Unregister(userID string) error {
+	sd.mu.Lock()
+	delete(sd.local, userID)
+	sd.mu.Unlock()
+
+	if err := sd.client.HDel(sd.ctx, SessionKey(userID), sd.instanceID).Err(); err != nil {
+		return fmt.Errorf("erro ao remover sessão: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup retorna as outras instâncias onde o usuário está conectado
+func (sd *SessionDirectory) Lookup(userID string) ([]string, error) {
+	key := SessionKey(userID)
+
+	entries, err := sd.client.HGetAll(sd.ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar sessões: %w", err)
+	}
+
+	cutoff := time.Now().Add(-sd.ttl).UnixMilli()
+	instances := make([]string, 0, len(entries))
+	stale := make([]string, 0)
+
+	for instanceID, value := range entries {
+		seenAt, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seenAt < cutoff {
+			stale = append(stale, instanceID)
+			continue
+		}
+		if instanceID == sd.instanceID {
+			continue
+		}
+		instances = append(instances, instanceID)
+	}
+
+	// Remove sessões de instâncias que pararam de renovar
+	if len(stale) > 0 {
+		if err := sd.client.HDel(sd.ctx, key, stale...).Err(); err != nil {
+			log.Printf("[Redis Sessions] Erro ao remover sessões expiradas de %s: %v", userID, err)
+		}
+	}
+
+	return instances, nil
+}
+
+// refresh enfileira a regravação de todas as sessões locais com o timestamp atual
+func (sd *SessionDirectory) refresh(ctx context.Context, pipe redis.Pipeliner) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	for userID := range sd.local {
+		key := SessionKey(userID)
+		pipe.HSet(ctx, key, sd.instanceID, now)
+		pipe.PExpire(ctx, key, sd.ttl)
+	}
+	return nil
+}
+
+// Close remove as sessões desta instância
+// O cliente compartilhado é fechado por quem o criou
+func (sd *SessionDirectory) Close() error {
+	sd.mu.Lock()
+	local := sd.local
+	sd.local = make(map[string]bool)
+	sd.mu.Unlock()
+
+	sd.stop(func(ctx context.Context, pipe redis.Pipeliner) error {
+		for userID := range local {
+			pipe.HDel(ctx, SessionKey(userID), sd.instanceID)
+		}
+		return nil
+	})
+
+	log.Println("[Redis Sessions] Diretório de sessões fechado")
+	return nil
+}
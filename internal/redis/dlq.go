@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Stream com as entradas que o worker não conseguiu persistir
+	DeadLetterStream = "gosocket:messages:dlq"
+
+	// Campos adicionados às entradas movidas para a DLQ
+	dlqSourceIDField = "dlq_source_id"
+	dlqErrorField    = "dlq_error"
+	dlqAttemptsField = "dlq_attempts"
+	dlqConsumerField = "dlq_consumer"
+	dlqFailedAtField = "dlq_failed_at"
+
+	// ID original preservado ao devolver uma entrada ao stream principal
+	sourceIDField = "source_id"
+)
+
+// DeadLetter representa uma entrada da DLQ
+type DeadLetter struct {
+	ID       string                 // ID da entrada na DLQ
+	SourceID string                 // ID original em MessagesStream
+	Error    string                 // Último erro conhecido
+	Attempts int64                  // Entregas até desistir
+	Consumer string                 // Worker que moveu a entrada
+	FailedAt time.Time              // Momento em que foi movida
+	Values   map[string]interface{} // Campos originais da entrada
+}
+
+// deadLetter move uma entrada para a DLQ e faz ACK no stream principal
+// A entrada só sai do PEL depois de gravada na DLQ
+func (sc *StreamConsumer) deadLetter(entry redis.XMessage, reason string, attempts int64) {
+	values := make(map[string]interface{}, len(entry.Values)+5)
+	for field, value := range entry.Values {
+		values[field] = value
+	}
+	values[dlqSourceIDField] = entry.ID
+	if sourceID, ok := entry.Values[sourceIDField].(string); ok && sourceID != "" {
+		// Entrada já devolvida antes: mantém o ID mais antigo
+		values[dlqSourceIDField] = sourceID
+	}
+	values[dlqErrorField] = reason
+	values[dlqAttemptsField] = attempts
+	values[dlqConsumerField] = sc.consumerID
+	values[dlqFailedAtField] = time.Now().UnixMilli()
+
+	if err := sc.client.XAdd(sc.ctx, &redis.XAddArgs{
+		Stream: DeadLetterStream,
+		Values: values,
+	}).Err(); err != nil {
+		log.Printf("[Redis Consumer] Erro ao mover %s para a DLQ: %v", entry.ID, err)
+		return
+	}
+
+	if err := sc.client.XAck(sc.ctx, MessagesStream, PersistConsumerGroup, entry.ID).Err(); err != nil {
+		log.Printf("[Redis Consumer] Erro ao fazer ACK de %s: %v", entry.ID, err)
+	}
+
+	delete(sc.failures, entry.ID)
+
+	log.Printf("[Redis Consumer] Entrada %s movida para %s após %d tentativas: %s",
+		entry.ID, DeadLetterStream, attempts, reason)
+}
+
+// ListDeadLetters retorna as entradas mais antigas da DLQ
+func ListDeadLetters(ctx context.Context, client redis.UniversalClient, count int64) ([]DeadLetter, error) {
+	entries, err := client.XRangeN(ctx, DeadLetterStream, "-", "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler DLQ: %w", err)
+	}
+
+	letters := make([]DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		letters = append(letters, parseDeadLetter(entry))
+	}
+	return letters, nil
+}
+
+// RedriveDeadLetters devolve entradas da DLQ ao stream principal
+// Sem IDs, devolve até count entradas mais antigas; retorna quantas foram devolvidas
+func RedriveDeadLetters(ctx context.Context, client redis.UniversalClient, count int64, ids ...string) (int, error) {
+	var entries []redis.XMessage
+
+	if len(ids) == 0 {
+		found, err := client.XRangeN(ctx, DeadLetterStream, "-", "+", count).Result()
+		if err != nil {
+			return 0, fmt.Errorf("erro ao ler DLQ: %w", err)
+		}
+		entries = found
+	} else {
+		for _, id := range ids {
+			found, err := client.XRange(ctx, DeadLetterStream, id, id).Result()
+			if err != nil {
+				return 0, fmt.Errorf("erro ao ler entrada %s da DLQ: %w", id, err)
+			}
+			entries = append(entries, found...)
+		}
+	}
+
+	redriven := 0
+	for _, entry := range entries {
+		letter := parseDeadLetter(entry)
+		if letter.SourceID != "" {
+			// Mantém a chave de deduplicação da entrada original
+			letter.Values[sourceIDField] = letter.SourceID
+		}
+
+		if err := client.XAdd(ctx, &redis.XAddArgs{
+			Stream: MessagesStream,
+			Values: letter.Values,
+		}).Err(); err != nil {
+			return redriven, fmt.Errorf("erro ao devolver %s ao stream: %w", entry.ID, err)
+		}
+
+		if err := client.XDel(ctx, DeadLetterStream, entry.ID).Err(); err != nil {
+			return redriven, fmt.Errorf("erro ao remover %s da DLQ: %w", entry.ID, err)
+		}
+
+		redriven++
+	}
+
+	return redriven, nil
+}
+
+// parseDeadLetter separa os campos da DLQ dos campos originais
+func parseDeadLetter(entry redis.XMessage) DeadLetter {
+	letter := DeadLetter{
+		ID:     entry.ID,
+		Values: make(map[string]interface{}, len(entry.Values)),
+	}
+
+	for field, value := range entry.Values {
+		str, _ := value.(string)
+
+		switch field {
+		case dlqSourceIDField:
+			letter.SourceID = str
+		case dlqErrorField:
+			letter.Error = str
+		case dlqAttemptsField:
+			letter.Attempts, _ = strconv.ParseInt(str, 10, 64)
+		case dlqConsumerField:
+			letter.Consumer = str
+		case dlqFailedAtField:
+			if ms, err := strconv.ParseInt(str, 10, 64); err == nil {
+				letter.FailedAt = time.UnixMilli(ms)
+			}
+		default:
+			letter.Values[field] = value
+		}
+	}
+
+	return letter
+}
@@ -0,0 +1,59 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/5ucr4m/go-socket/internal/persistence"
+	"github.com/5ucr4m/go-socket/internal/redis"
+)
+
+// Uma revisão sem message_id falha na validação do repositório em todas as
+// entregas e precisa terminar na DLQ em vez de ficar no PEL para sempre
+func TestMalformedRevisionReachesDeadLetterStream(t *testing.T) {
+	fake, client := redis.NewFakeRedis(t)
+	id := fake.Add(redis.MessagesStream, map[string]interface{}{
+		"room_name":   "geral",
+		"kind":        redis.KindRevision,
+		"revision_id": "r1",
+		"payload":     `{"text":"editado"}`,
+	})
+
+	consumer, err := redis.NewStreamConsumer(client, redis.ConsumerConfig{
+		ConsumerID:    "w1",
+		BatchSize:     10,
+		BatchTimeout:  5 * time.Millisecond,
+		ClaimInterval: 10 * time.Millisecond,
+		ClaimMinIdle:  time.Millisecond,
+		MaxDeliveries: 2,
+	}, &persistence.MessageRepository{})
+	if err != nil {
+		t.Fatalf("NewStreamConsumer: %v", err)
+	}
+	if err := consumer.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer consumer.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.Entries(redis.DeadLetterStream)) == 0 {
+		if time.Now().After(deadline) {
+			deliveries, _ := fake.Deliveries(id)
+			t.Fatalf("revisão não chegou à DLQ (%d entregas)", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	consumer.Stop()
+
+	letters := fake.Entries(redis.DeadLetterStream)
+	if len(letters) != 1 {
+		t.Fatalf("DLQ = %d entradas, want 1", len(letters))
+	}
+	values := letters[0].Values
+	if values["dlq_source_id"] != id || values["dlq_error"] != "revisão sem ID (room: geral)" || values["dlq_attempts"] != "3" {
+		t.Errorf("DLQ = %v, want origem %s, erro de validação e 3 tentativas", values, id)
+	}
+	if _, pending := fake.Deliveries(id); pending {
+		t.Error("revisão continua pendente")
+	}
+}
@@ -0,0 +1,352 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FakeRedis simula, por um hook do go-redis, os comandos de stream usados pelo
+// consumer: XADD, XACK, XREADGROUP (">"), XPENDING, XCLAIM e XAUTOCLAIM
+// Há um único consumer group e nenhum comando chega à rede
+// Exportado para os testes do pacote redis_test
+type FakeRedis struct {
+	mu            sync.Mutex
+	streams       map[string][]redis.XMessage
+	pending       map[string]*fakePending
+	lastDelivered string
+	seq           int64
+}
+
+// fakePending é uma entrada no PEL do grupo
+type fakePending struct {
+	consumer    string
+	deliveries  int64
+	deliveredAt time.Time
+}
+
+// NewFakeRedis cria o simulador e um cliente ligado a ele
+func NewFakeRedis(t testing.TB) (*FakeRedis, redis.UniversalClient) {
+	t.Helper()
+
+	fake := &FakeRedis{
+		streams:       make(map[string][]redis.XMessage),
+		pending:       make(map[string]*fakePending),
+		lastDelivered: "0-0",
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "fake:0", MaxRetries: -1})
+	client.AddHook(fake)
+	t.Cleanup(func() { client.Close() })
+
+	return fake, client
+}
+
+// Add grava uma entrada no stream e retorna seu ID
+func (f *FakeRedis) Add(stream string, values map[string]interface{}) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.add(stream, values)
+}
+
+// Entries retorna as entradas do stream
+func (f *FakeRedis) Entries(stream string) []redis.XMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]redis.XMessage(nil), f.streams[stream]...)
+}
+
+// Deliveries retorna o contador de entregas de uma entrada pendente
+func (f *FakeRedis) Deliveries(id string) (int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.pending[id]
+	if !ok {
+		return 0, false
+	}
+	return entry.deliveries, true
+}
+
+// deliver coloca uma entrada no PEL de um consumer, como se já tivesse sido
+// entregue deliveries vezes há idle
+func (f *FakeRedis) deliver(id, consumer string, deliveries int64, idle time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pending[id] = &fakePending{consumer: consumer, deliveries: deliveries, deliveredAt: time.Now().Add(-idle)}
+	if compareStreamIDs(id, f.lastDelivered) > 0 {
+		f.lastDelivered = id
+	}
+}
+
+func (f *FakeRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("FakeRedis não abre conexões")
+	}
+}
+
+func (f *FakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		f.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (f *FakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			f.process(cmd)
+		}
+		return nil
+	}
+}
+
+// process executa um comando sobre o estado em memória
+func (f *FakeRedis) process(cmd redis.Cmder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	args := make([]string, 0, len(cmd.Args()))
+	for _, arg := range cmd.Args() {
+		args = append(args, fmt.Sprint(arg))
+	}
+
+	switch c := cmd.(type) {
+	case *redis.StringCmd:
+		if args[0] == "xadd" {
+			c.SetVal(f.xadd(args))
+			return
+		}
+	case *redis.IntCmd:
+		if args[0] == "xack" {
+			c.SetVal(f.xack(args[3:]))
+			return
+		}
+	case *redis.XStreamSliceCmd:
+		if args[0] == "xreadgroup" {
+			f.xreadgroup(c, args)
+			return
+		}
+	case *redis.XPendingExtCmd:
+		c.SetVal(f.xpending(args))
+		return
+	case *redis.XMessageSliceCmd:
+		if args[0] == "xclaim" {
+			c.SetVal(f.claim(args[1], args[3], args[5:]))
+			return
+		}
+	case *redis.XAutoClaimCmd:
+		f.xautoclaim(c, args)
+		return
+	}
+
+	cmd.SetErr(fmt.Errorf("FakeRedis: comando não suportado: %s", strings.Join(args, " ")))
+}
+
+// add grava uma entrada. Deve ser chamado com f.mu travado
+func (f *FakeRedis) add(stream string, values map[string]interface{}) string {
+	f.seq++
+	id := fmt.Sprintf("%d-0", f.seq)
+
+	stored := make(map[string]interface{}, len(values))
+	for field, value := range values {
+		stored[field] = fmt.Sprint(value)
+	}
+	f.streams[stream] = append(f.streams[stream], redis.XMessage{ID: id, Values: stored})
+	return id
+}
+
+// xadd trata XADD stream [opções] * campo valor ...
+func (f *FakeRedis) xadd(args []string) string {
+	i := 2
+	for args[i] != "*" {
+		i++
+	}
+
+	values := make(map[string]interface{})
+	for j := i + 1; j+1 < len(args); j += 2 {
+		values[args[j]] = args[j+1]
+	}
+	return f.add(args[1], values)
+}
+
+// xack remove as entradas do PEL
+func (f *FakeRedis) xack(ids []string) int64 {
+	var acked int64
+	for _, id := range ids {
+		if _, ok := f.pending[id]; ok {
+			delete(f.pending, id)
+			acked++
+		}
+	}
+	return acked
+}
+
+// xreadgroup entrega as entradas novas (">") de MessagesStream
+func (f *FakeRedis) xreadgroup(cmd *redis.XStreamSliceCmd, args []string) {
+	consumer := args[3]
+	count := optionInt(args, "count")
+
+	streams := indexOf(args, "streams")
+	stream, id := args[streams+1], args[streams+2]
+	if id != ">" {
+		cmd.SetErr(fmt.Errorf("FakeRedis: XREADGROUP só com >"))
+		return
+	}
+
+	messages := make([]redis.XMessage, 0)
+	for _, entry := range f.streams[stream] {
+		if compareStreamIDs(entry.ID, f.lastDelivered) <= 0 {
+			continue
+		}
+		if count > 0 && int64(len(messages)) >= count {
+			break
+		}
+		messages = append(messages, entry)
+		f.lastDelivered = entry.ID
+		f.pending[entry.ID] = &fakePending{consumer: consumer, deliveries: 1, deliveredAt: time.Now()}
+	}
+
+	if len(messages) == 0 {
+		cmd.SetErr(redis.Nil)
+		return
+	}
+	cmd.SetVal([]redis.XStream{{Stream: stream, Messages: messages}})
+}
+
+// xpending trata XPENDING stream grupo início fim quantidade [consumer]
+func (f *FakeRedis) xpending(args []string) []redis.XPendingExt {
+	start, end := args[3], args[4]
+	count, _ := strconv.ParseInt(args[5], 10, 64)
+	consumer := ""
+	if len(args) > 6 {
+		consumer = args[6]
+	}
+
+	result := make([]redis.XPendingExt, 0)
+	for _, id := range f.pendingIDs() {
+		entry := f.pending[id]
+		if !inRange(id, start, end) || (consumer != "" && entry.consumer != consumer) {
+			continue
+		}
+		if int64(len(result)) >= count {
+			break
+		}
+		result = append(result, redis.XPendingExt{
+			ID:         id,
+			Consumer:   entry.consumer,
+			Idle:       time.Since(entry.deliveredAt),
+			RetryCount: entry.deliveries,
+		})
+	}
+	return result
+}
+
+// xautoclaim trata XAUTOCLAIM stream grupo consumer ocioso início [COUNT n]
+func (f *FakeRedis) xautoclaim(cmd *redis.XAutoClaimCmd, args []string) {
+	minIdle, _ := strconv.ParseInt(args[4], 10, 64)
+	count := optionInt(args, "count")
+
+	ids := make([]string, 0)
+	for _, id := range f.pendingIDs() {
+		if compareStreamIDs(id, args[5]) < 0 {
+			continue
+		}
+		if time.Since(f.pending[id].deliveredAt) < time.Duration(minIdle)*time.Millisecond {
+			continue
+		}
+		if count > 0 && int64(len(ids)) >= count {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	cmd.SetVal(f.claim(args[1], args[3], ids), "0-0")
+}
+
+// claim transfere as entradas para o consumer e conta uma nova entrega
+// Entradas que não existem mais no stream saem do PEL, como no Redis 7
+func (f *FakeRedis) claim(stream, consumer string, ids []string) []redis.XMessage {
+	claimed := make([]redis.XMessage, 0, len(ids))
+	for _, id := range ids {
+		entry, ok := f.pending[id]
+		if !ok {
+			continue
+		}
+
+		message, found := f.find(stream, id)
+		if !found {
+			delete(f.pending, id)
+			continue
+		}
+
+		entry.consumer = consumer
+		entry.deliveries++
+		entry.deliveredAt = time.Now()
+		claimed = append(claimed, message)
+	}
+	return claimed
+}
+
+// find busca uma entrada do stream pelo ID
+func (f *FakeRedis) find(stream, id string) (redis.XMessage, bool) {
+	for _, entry := range f.streams[stream] {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return redis.XMessage{}, false
+}
+
+// pendingIDs retorna os IDs do PEL em ordem
+func (f *FakeRedis) pendingIDs() []string {
+	ids := make([]string, 0, len(f.pending))
+	for id := range f.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return compareStreamIDs(ids[i], ids[j]) < 0 })
+	return ids
+}
+
+// inRange indica se o ID está no intervalo do XPENDING ("-", "+" e "(id" exclusivo)
+func inRange(id, start, end string) bool {
+	switch {
+	case start == "-":
+	case strings.HasPrefix(start, "("):
+		if compareStreamIDs(id, start[1:]) <= 0 {
+			return false
+		}
+	case compareStreamIDs(id, start) < 0:
+		return false
+	}
+	return end == "+" || compareStreamIDs(id, end) <= 0
+}
+
+// indexOf retorna a posição de um argumento (-1 se ausente)
+func indexOf(args []string, name string) int {
+	for i, arg := range args {
+		if arg == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// optionInt lê o valor de uma opção como COUNT (0 se ausente)
+func optionInt(args []string, name string) int64 {
+	i := indexOf(args, name)
+	if i < 0 || i+1 >= len(args) {
+		return 0
+	}
+	value, _ := strconv.ParseInt(args[i+1], 10, 64)
+	return value
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// heartbeat renova periodicamente as entradas que uma instância mantém no Redis
+// com TTL (presença das salas, diretório de sessões); entradas de instâncias
+// que param de renovar expiram sozinhas
+type heartbeat struct {
+	client     redis.UniversalClient
+	instanceID string
+	ttl        time.Duration
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// Prefixo dos logs, ex.: "[Redis Presence]"
+	logPrefix string
+}
+
+// newHeartbeat cria o heartbeat sem iniciá-lo (ttl <= 0 usa defaultTTL)
+func newHeartbeat(client redis.UniversalClient, instanceID string, ttl, defaultTTL time.Duration, logPrefix string) heartbeat {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return heartbeat{
+		client:     client,
+		instanceID: instanceID,
+		ttl:        ttl,
+		ctx:        ctx,
+		cancel:     cancel,
+		logPrefix:  logPrefix,
+	}
+}
+
+// start regrava as entradas locais a cada ttl/3 até stop
+// write enfileira no pipeline os comandos de renovação
+func (h *heartbeat) start(write func(ctx context.Context, pipe redis.Pipeliner) error) {
+	go func() {
+		ticker := time.NewTicker(h.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := h.pipelined(h.ctx, write); err != nil {
+					log.Printf("%s Erro ao renovar entradas: %v", h.logPrefix, err)
+				}
+			}
+		}
+	}()
+}
+
+// stop encerra as renovações e remove as entradas locais em vez de esperar o TTL
+// O cliente compartilhado é fechado por quem o criou
+func (h *heartbeat) stop(remove func(ctx context.Context, pipe redis.Pipeliner) error) {
+	h.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.pipelined(ctx, remove); err != nil {
+		log.Printf("%s Erro ao remover entradas locais: %v", h.logPrefix, err)
+	}
+}
+
+// pipelined executa os comandos enfileirados por fill (nenhum comando = nenhuma chamada)
+func (h *heartbeat) pipelined(ctx context.Context, fill func(ctx context.Context, pipe redis.Pipeliner) error) error {
+	_, err := h.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		return fill(ctx, pipe)
+	})
+	return err
+}
@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// recordingHook registra os comandos enviados em pipeline sem abrir conexões
+type recordingHook struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (h *recordingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("recordingHook não abre conexões")
+	}
+}
+
+func (h *recordingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.record(cmd)
+		return nil
+	}
+}
+
+func (h *recordingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.record(cmd)
+		}
+		return nil
+	}
+}
+
+func (h *recordingHook) record(cmd redis.Cmder) {
+	args := make([]string, 0, len(cmd.Args()))
+	for _, arg := range cmd.Args() {
+		args = append(args, fmt.Sprint(arg))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commands = append(h.commands, strings.Join(args, " "))
+}
+
+// count retorna quantos comandos começam com o prefixo
+func (h *recordingHook) count(prefix string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, command := range h.commands {
+		if strings.HasPrefix(command, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHeartbeatRefreshesAndRemovesEntries(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// open cria o registro e adiciona uma entrada local
+		open    func(client redis.UniversalClient) (func() error, error)
+		refresh string
+		remove  string
+	}{
+		{
+			name: "presença das salas",
+			open: func(client redis.UniversalClient) (func() error, error) {
+				registry, err := NewPresenceRegistry(client, "i1", ttl)
+				if err != nil {
+					return nil, err
+				}
+				return registry.Close, registry.Join("geral", "c1", map[string]interface{}{"id": "alice"})
+			},
+			refresh: "hset " + PresenceKey("geral") + " i1:c1",
+			remove:  "hdel " + PresenceKey("geral") + " i1:c1",
+		},
+		{
+			name: "diretório de sessões",
+			open: func(client redis.UniversalClient) (func() error, error) {
+				directory, err := NewSessionDirectory(client, "i1", ttl)
+				if err != nil {
+					return nil, err
+				}
+				return directory.Close, directory.Register("alice")
+			},
+			refresh: "hset " + SessionKey("alice") + " i1",
+			remove:  "hdel " + SessionKey("alice") + " i1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &recordingHook{}
+			client := redis.NewClient(&redis.Options{Addr: "fake:0", MaxRetries: -1})
+			client.AddHook(hook)
+			t.Cleanup(func() { client.Close() })
+
+			closeRegistry, err := tt.open(client)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+
+			// A entrada é regravada a cada ttl/3, além do registro inicial
+			deadline := time.Now().Add(2 * time.Second)
+			for hook.count(tt.refresh) < 3 {
+				if time.Now().After(deadline) {
+					closeRegistry()
+					t.Fatalf("entrada renovada %d vezes, want ao menos 3", hook.count(tt.refresh))
+				}
+				time.Sleep(ttl / 3)
+			}
+
+			if err := closeRegistry(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if got := hook.count(tt.remove); got != 1 {
+				t.Errorf("entrada removida %d vezes no Close, want 1", got)
+			}
+
+			// Após o Close não há novas renovações
+			refreshed := hook.count(tt.refresh)
+			time.Sleep(ttl)
+			if got := hook.count(tt.refresh); got != refreshed {
+				t.Errorf("entrada renovada após o Close (%d -> %d)", refreshed, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Prefixo dos streams de histórico por sala (gosocket:history:{nome})
+	HistoryKeyPrefix = "gosocket:history:"
+
+	// Sufixo do hash com a versão mais recente das mensagens alteradas após o XADD
+	historyUpdatesSuffix = ":updates"
+)
+
+// HistoryKey retorna a chave do stream de histórico de uma sala
+func HistoryKey(roomName string) string {
+	return HistoryKeyPrefix + roomName
+}
+
+// historyUpdatesKey retorna a chave do hash de mensagens alteradas de uma sala
+func historyUpdatesKey(roomName string) string {
+	return HistoryKeyPrefix + roomName + historyUpdatesSuffix
+}
+
+// HistoryStore mantém o histórico recente de cada sala em um Redis Stream
+// Compartilhado entre instâncias e preservado entre reinícios. Como entradas
+// de stream são imutáveis, edições ficam em um hash que sobrepõe a leitura.
+type HistoryStore struct {
+	client redis.UniversalClient
+	maxLen int64
+	ctx    context.Context
+}
+
+// NewHistoryStore cria um novo armazenamento de histórico
+// maxLen limita (aproximadamente) o número de mensagens por sala
+func NewHistoryStore(client redis.UniversalClient, maxLen int64) *HistoryStore {
+	return &HistoryStore{
+		client: client,
+		maxLen: maxLen,
+		ctx:    context.Background(),
+	}
+}
+
+// Append adiciona uma mensagem serializada ao histórico da sala
+func (hs *HistoryStore) Append(roomName, messageID string, data []byte) error {
+	args := &redis.XAddArgs{
+		Stream: HistoryKey(roomName),
+		Values: map[string]interface{}{
+			"id":   messageID,
+			"data": string(data),
+		},
+	}
+	if hs.maxLen > 0 {
+		args.MaxLen = hs.maxLen
+		args.Approx = true
+	}
+
+	if err := hs.client.XAdd(hs.ctx, args).Err(); err != nil {
+		return fmt.Errorf("erro ao adicionar ao histórico: %w", err)
+	}
+	return nil
+}
+
+// Update registra a versão mais recente de uma mensagem já adicionada
+// Quando o hash passa do dobro de maxLen, descarta as versões de mensagens
+// que o trim do stream já removeu
+func (hs *HistoryStore) Update(roomName, messageID string, data []byte) error {
+	key := historyUpdatesKey(roomName)
+
+	pipe := hs.client.Pipeline()
+	pipe.HSet(hs.ctx, key, messageID, string(data))
+	size := pipe.HLen(hs.ctx, key)
+	if _, err := pipe.Exec(hs.ctx); err != nil {
+		return fmt.Errorf("erro ao atualizar histórico: %w", err)
+	}
+
+	if hs.maxLen > 0 && size.Val() > 2*hs.maxLen {
+		return hs.pruneUpdates(roomName)
+	}
+	return nil
+}
+
+// pruneUpdates remove do hash de edições as mensagens que não estão mais no stream
+func (hs *HistoryStore) pruneUpdates(roomName string) error {
+	entries, err := hs.client.XRange(hs.ctx, HistoryKey(roomName), "-", "+").Result()
+	if err != nil {
+		return fmt.Errorf("erro ao ler histórico: %w", err)
+	}
+
+	retained := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if id, ok := entry.Values["id"].(string); ok {
+			retained[id] = true
+		}
+	}
+
+	key := historyUpdatesKey(roomName)
+	fields, err := hs.client.HKeys(hs.ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("erro ao ler edições do histórico: %w", err)
+	}
+
+	stale := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !retained[field] {
+			stale = append(stale, field)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	if err := hs.client.HDel(hs.ctx, key, stale...).Err(); err != nil {
+		return fmt.Errorf("erro ao descartar edições antigas do histórico: %w", err)
+	}
+	return nil
+}
+
+// Recent retorna as últimas mensagens da sala em ordem cronológica
+// limit <= 0 retorna todo o histórico retido
+func (hs *HistoryStore) Recent(roomName string, limit int) ([][]byte, error) {
+	var entries []redis.XMessage
+	var err error
+	if limit > 0 {
+		entries, err = hs.client.XRevRangeN(hs.ctx, HistoryKey(roomName), "+", "-", int64(limit)).Result()
+	} else {
+		entries, err = hs.client.XRevRange(hs.ctx, HistoryKey(roomName), "+", "-").Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler histórico: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	// Busca as versões atualizadas das mensagens lidas
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i], _ = entry.Values["id"].(string)
+	}
+	updates, err := hs.client.HMGet(hs.ctx, historyUpdatesKey(roomName), ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler edições do histórico: %w", err)
+	}
+
+	// XREVRANGE retorna da mais nova para a mais antiga
+	messages := make([][]byte, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if updated, ok := updates[i].(string); ok && updated != "" {
+			messages = append(messages, []byte(updated))
+			continue
+		}
+		if data, ok := entries[i].Values["data"].(string); ok {
+			messages = append(messages, []byte(data))
+		}
+	}
+
+	return messages, nil
+}
+
+// Remove apaga definitivamente uma mensagem do histórico da sala
+// Percorre o stream retido (limitado por maxLen) procurando o ID da mensagem
+func (hs *HistoryStore) Remove(roomName, messageID string) error {
+	entries, err := hs.client.XRange(hs.ctx, HistoryKey(roomName), "-", "+").Result()
+	if err != nil {
+		return fmt.Errorf("erro ao ler histórico: %w", err)
+	}
+
+	streamIDs := make([]string, 0, 1)
+	for _, entry := range entries {
+		if id, _ := entry.Values["id"].(string); id == messageID {
+			streamIDs = append(streamIDs, entry.ID)
+		}
+	}
+
+	if len(streamIDs) > 0 {
+		if err := hs.client.XDel(hs.ctx, HistoryKey(roomName), streamIDs...).Err(); err != nil {
+			return fmt.Errorf("erro ao remover do histórico: %w", err)
+		}
+	}
+
+	if err := hs.client.HDel(hs.ctx, historyUpdatesKey(roomName), messageID).Err(); err != nil {
+		return fmt.Errorf("erro ao remover edições do histórico: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Prefixo dos hashes de presença por sala (gosocket:presence:{nome})
+	PresenceKeyPrefix = "gosocket:presence:"
+
+	// Tempo sem heartbeat após o qual uma entrada de presença é descartada
+	DefaultPresenceTTL = 30 * time.Second
+)
+
+// PresenceKey retorna a chave do hash de presença de uma sala
+func PresenceKey(roomName string) string {
+	return PresenceKeyPrefix + roomName
+}
+
+// presenceEntry representa uma conexão presente em uma sala
+type presenceEntry struct {
+	User     map[string]interface{} `json:"user"`
+	Instance string                 `json:"instance"`
+	SeenAt   int64                  `json:"seen_at"` // Último heartbeat (unix ms)
+}
+
+// PresenceRegistry mantém a presença das salas em hashes do Redis
+// Cada instância renova periodicamente as entradas dos seus clientes; entradas
+// de instâncias que pararam de renovar expiram sozinhas
+type PresenceRegistry struct {
+	heartbeat
+
+	// Entradas locais (sala -> id do cliente -> usuário) renovadas no heartbeat
+	local map[string]map[string]map[string]interface{}
+	mu    sync.Mutex
+}
+
+// NewPresenceRegistry cria um novo registro de presença sobre o cliente compartilhado
+func NewPresenceRegistry(client redis.UniversalClient, instanceID string, ttl time.Duration) (*PresenceRegistry, error) {
+	registry := &PresenceRegistry{
+		heartbeat: newHeartbeat(client, instanceID, ttl, DefaultPresenceTTL, "[Redis Presence]"),
+		local:     make(map[string]map[string]map[string]interface{}),
+	}
+
+	registry.start(registry.refresh)
+
+	log.Printf("[Redis Presence] Registro de presença iniciado (instance: %s, ttl: %s)", instanceID, registry.ttl)
+
+	return registry, nil
+}
+
+// field retorna o campo do hash para um cliente desta instância
+func (pr *PresenceRegistry) field(clientID string) string {
+	return pr.instanceID + ":" + clientID
+}
+
+// Join registra um cliente como presente em uma sala
+func (pr *PresenceRegistry) Join(roomName, clientID string, user map[string]interface{}) error {
+	pr.mu.Lock()
+	if pr.local[roomName] == nil {
+		pr.local[roomName] = make(map[string]map[string]interface{})
+	}
+	pr.local[roomName][clientID] = user
+	pr.mu.Unlock()
+
+	data, err := pr.encode(user)
+	if err != nil {
+		return err
+	}
+
+	key := PresenceKey(roomName)
+	_, err = pr.client.Pipelined(pr.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(pr.ctx, key, pr.field(clientID), data)
+		pipe.PExpire(pr.ctx, key, pr.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao registrar presença: %w", err)
+	}
+
+	return nil
+}
+
+// Leave remove a presença de um cliente em uma sala
+func (pr *PresenceRegistry) Leave(roomName, clientID string) error {
+	pr.mu.Lock()
+	if clients, ok := pr.local[roomName]; ok {
+		delete(clients, clientID)
+		if len(clients) == 0 {
+			delete(pr.local, roomName)
+		}
+	}
+	pr.mu.Unlock()
+
+	if err := pr.client.HDel(pr.ctx, PresenceKey(roomName), pr.field(clientID)).Err(); err != nil {
+		return fmt.Errorf("erro ao remover presença: %w", err)
+	}
+
+	return nil
+}
+
+// List retorna os usuários presentes em uma sala em todas as instâncias
+// Usuários com mais de uma conexão aparecem uma única vez
+func (pr *PresenceRegistry) List(roomName string) ([]map[string]interface{}, error) {
+	key := PresenceKey(roomName)
+
+	entries, err := pr.client.HGetAll(pr.ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar presença: %w", err)
+	}
+
+	cutoff := time.Now().Add(-pr.ttl).UnixMilli()
+	presence := make([]map[string]interface{}, 0, len(entries))
+	seen := make(map[string]bool)
+	stale := make([]string, 0)
+
+	for field, value := range entries {
+		var entry presenceEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil || entry.SeenAt < cutoff {
+			stale = append(stale, field)
+			continue
+		}
+		if entry.User == nil {
+			continue
+		}
+
+		if id, ok := entry.User["id"].(string); ok && id != "" {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+
+		presence = append(presence, entry.User)
+	}
+
+	// Remove entradas de instâncias que pararam de renovar
+	if len(stale) > 0 {
+		if err := pr.client.HDel(pr.ctx, key, stale...).Err(); err != nil {
+			log.Printf("[Redis Presence] Erro ao remover entradas expiradas de %s: %v", roomName, err)
+		}
+	}
+
+	return presence, nil
+}
+
+// encode serializa uma entrada de presença com o timestamp atual
+func (pr *PresenceRegistry) encode(user map[string]interface{}) (string, error) {
+	data, err := json.Marshal(presenceEntry{
+		User:     user,
+		Instance: pr.instanceID,
+		SeenAt:   time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("erro ao serializar presença: %w", err)
+	}
+	return string(data), nil
+}
+
+// refresh enfileira a regravação de todas as entradas locais com o timestamp atual
+func (pr *PresenceRegistry) refresh(ctx context.Context, pipe redis.Pipeliner) error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	for roomName, clients := range pr.local {
+		key := PresenceKey(roomName)
+		for clientID, user := range clients {
+			data, err := pr.encode(user)
+			if err != nil {
+				return err
+			}
+			pipe.HSet(ctx, key, pr.field(clientID), data)
+		}
+		pipe.PExpire(ctx, key, pr.ttl)
+	}
+	return nil
+}
+
+// Close remove as entradas desta instância
+// O cliente compartilhado é fechado por quem o criou
+func (pr *PresenceRegistry) Close() error {
+	pr.mu.Lock()
+	local := pr.local
+	pr.local = make(map[string]map[string]map[string]interface{})
+	pr.mu.Unlock()
+
+	pr.stop(func(ctx context.Context, pipe redis.Pipeliner) error {
+		for roomName, clients := range local {
+			for clientID := range clients {
+				pipe.HDel(ctx, PresenceKey(roomName), pr.field(clientID))
+			}
+		}
+		return nil
+	})
+
+	log.Println("[Redis Presence] Registro de presença fechado")
+	return nil
+}
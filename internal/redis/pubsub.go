@@ -5,81 +5,168 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/5ucr4m/go-socket/internal/backplane"
 	"github.com/redis/go-redis/v9"
 )
 
 const (
-	// Canal para broadcast de mensagens entre instâncias
-	BroadcastChannel = "gosocket:broadcast"
+	// Prefixo dos canais Pub/Sub: o tópico "room:geral" vira "gosocket:room:geral"
+	ChannelPrefix = "gosocket:"
+
+	// Máximo de publicações mantidas em buffer enquanto o Redis está fora
+	DefaultOutboxSize = 1000
+
+	// Intervalo entre verificações de conexão
+	healthCheckInterval = 2 * time.Second
 )
 
 // MessageHandler é uma função que processa mensagens recebidas do Redis Pub/Sub
-type MessageHandler func(payload []byte) error
+type MessageHandler = backplane.Handler
+
+// Garante que o adaptador implementa o backplane
+var _ backplane.Backplane = (*PubSubAdapter)(nil)
+
+// ConnectionState representa o estado da conexão com o Redis
+type ConnectionState string
+
+const (
+	StateConnected    ConnectionState = "connected"
+	StateDisconnected ConnectionState = "disconnected"
+)
+
+// pendingPublish é uma publicação aguardando a volta do Redis
+type pendingPublish struct {
+	channel string
+	data    []byte
+}
 
 // PubSubAdapter gerencia comunicação entre instâncias via Redis Pub/Sub
+// Detecta quedas do Redis, refaz as inscrições na reconexão e mantém as
+// publicações em um buffer limitado durante a indisponibilidade
 type PubSubAdapter struct {
-	client     *redis.Client
+	client     redis.UniversalClient
 	instanceID string
 	pubsub     *redis.PubSub
 	ctx        context.Context
 	cancel     context.CancelFunc
-}
 
-// NewPubSubAdapter cria um novo adaptador de Pub/Sub
-func NewPubSubAdapter(redisURL string, instanceID string) (*PubSubAdapter, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
+	// Handlers por canal inscrito
+	handlers map[string]MessageHandler
+	mu       sync.RWMutex
 
-	// Testa a conexão
-	ctx := context.Background()
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("falha ao conectar no Redis: %w", err)
-	}
+	// Estado da conexão
+	connected atomic.Bool
 
-	log.Printf("[Redis Pub/Sub] Conectado ao Redis em %s (instance: %s)", redisURL, instanceID)
+	// Publicações pendentes durante a indisponibilidade (FIFO limitada)
+	// Enquanto flushing, novas publicações também entram no buffer
+	outbox     []pendingPublish
+	outboxSize int
+	dropped    int64
+	flushing   bool
+	outboxMu   sync.Mutex
+}
+
+// NewPubSubAdapter cria um novo adaptador de Pub/Sub sobre o cliente compartilhado
+func NewPubSubAdapter(client redis.UniversalClient, instanceID string) (*PubSubAdapter, error) {
+	log.Printf("[Redis Pub/Sub] Adaptador iniciado (instance: %s)", instanceID)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &PubSubAdapter{
+	adapter := &PubSubAdapter{
 		client:     client,
 		instanceID: instanceID,
 		ctx:        ctx,
 		cancel:     cancel,
-	}, nil
+		handlers:   make(map[string]MessageHandler),
+		outbox:     make([]pendingPublish, 0),
+		outboxSize: DefaultOutboxSize,
+	}
+	adapter.connected.Store(true)
+
+	go adapter.monitorConnection()
+
+	return adapter, nil
 }
 
-// Subscribe se inscreve no canal de broadcast e processa mensagens
-func (p *PubSubAdapter) Subscribe(handler MessageHandler) error {
-	// Se inscreve no canal
-	p.pubsub = p.client.Subscribe(p.ctx, BroadcastChannel)
+// Subscribe se inscreve no canal do tópico e processa suas mensagens com o
+// handler informado. Todos os canais compartilham a mesma conexão Pub/Sub.
+// Com o Redis fora, o canal é registrado e inscrito na reconexão.
+func (p *PubSubAdapter) Subscribe(topic string, handler MessageHandler) error {
+	channel := ChannelPrefix + topic
 
-	// Aguarda confirmação da inscrição
-	_, err := p.pubsub.Receive(p.ctx)
-	if err != nil {
-		return fmt.Errorf("falha ao se inscrever no canal: %w", err)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers[channel] = handler
+
+	// Primeira inscrição: cria a conexão e inicia o consumidor
+	if p.pubsub == nil {
+		pubsub := p.client.Subscribe(p.ctx, channel)
+
+		// Aguarda confirmação da inscrição
+		if _, err := pubsub.Receive(p.ctx); err != nil {
+			delete(p.handlers, channel)
+			pubsub.Close()
+			return fmt.Errorf("falha ao se inscrever no canal: %w", err)
+		}
+
+		p.pubsub = pubsub
+
+		// Inicia goroutine para processar mensagens
+		go p.consumeMessages(pubsub.Channel())
+	} else if err := p.pubsub.Subscribe(p.ctx, channel); err != nil {
+		// Mantém o handler: o canal será inscrito quando a conexão voltar
+		log.Printf("[Redis Pub/Sub] Inscrição em %s adiada até a reconexão: %v", channel, err)
+		p.markDisconnected(err)
+		return nil
+	}
+
+	log.Printf("[Redis Pub/Sub] Inscrito no canal: %s", channel)
+	return nil
+}
+
+// Unsubscribe cancela a inscrição no canal do tópico
+func (p *PubSubAdapter) Unsubscribe(topic string) error {
+	channel := ChannelPrefix + topic
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.handlers[channel]; !ok {
+		return nil
 	}
+	delete(p.handlers, channel)
 
-	log.Printf("[Redis Pub/Sub] Inscrito no canal: %s", BroadcastChannel)
+	if p.pubsub == nil || !p.IsConnected() {
+		// Sem conexão: o canal simplesmente não é refeito na reconexão
+		return nil
+	}
 
-	// Inicia goroutine para processar mensagens
-	go p.consumeMessages(handler)
+	if err := p.pubsub.Unsubscribe(p.ctx, channel); err != nil {
+		return fmt.Errorf("falha ao cancelar inscrição no canal: %w", err)
+	}
 
+	log.Printf("[Redis Pub/Sub] Inscrição cancelada no canal: %s", channel)
 	return nil
 }
 
 // consumeMessages processa mensagens recebidas do Redis Pub/Sub
-func (p *PubSubAdapter) consumeMessages(handler MessageHandler) {
-	ch := p.pubsub.Channel()
-
+// Termina quando o contexto é cancelado ou a conexão Pub/Sub é substituída
+func (p *PubSubAdapter) consumeMessages(ch <-chan *redis.Message) {
 	for {
 		select {
 		case <-p.ctx.Done():
 			log.Println("[Redis Pub/Sub] Consumidor finalizado")
 			return
 
-		case msg := <-ch:
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
 			if msg == nil {
 				continue
 			}
@@ -96,7 +183,15 @@ func (p *PubSubAdapter) consumeMessages(handler MessageHandler) {
 				continue
 			}
 
-			log.Printf("[Redis Pub/Sub] Mensagem recebida de %s", envelope.InstanceID)
+			p.mu.RLock()
+			handler := p.handlers[msg.Channel]
+			p.mu.RUnlock()
+
+			if handler == nil {
+				continue
+			}
+
+			log.Printf("[Redis Pub/Sub] Mensagem recebida de %s (canal: %s)", envelope.InstanceID, msg.Channel)
 
 			// Processa a mensagem
 			if err := handler(envelope.Payload); err != nil {
@@ -106,8 +201,12 @@ func (p *PubSubAdapter) consumeMessages(handler MessageHandler) {
 	}
 }
 
-// Publish publica uma mensagem no canal de broadcast
-func (p *PubSubAdapter) Publish(payload []byte) error {
+// Publish publica uma mensagem no canal do tópico
+// Com o Redis fora, a mensagem vai para o buffer e é publicada na reconexão
+// Enquanto o buffer não esvazia, as novas entram no fim dele para manter a ordem
+func (p *PubSubAdapter) Publish(topic string, payload []byte) error {
+	channel := ChannelPrefix + topic
+
 	// Cria envelope com ID da instância
 	envelope := MessageEnvelope{
 		InstanceID: p.instanceID,
@@ -120,26 +219,211 @@ func (p *PubSubAdapter) Publish(payload []byte) error {
 		return fmt.Errorf("erro ao serializar envelope: %w", err)
 	}
 
+	if p.deferPublish(channel, data) {
+		return nil
+	}
+
 	// Publica no canal
-	if err := p.client.Publish(p.ctx, BroadcastChannel, data).Err(); err != nil {
-		return fmt.Errorf("erro ao publicar mensagem: %w", err)
+	if err := p.client.Publish(p.ctx, channel, data).Err(); err != nil {
+		if p.ctx.Err() != nil {
+			return fmt.Errorf("erro ao publicar mensagem: %w", err)
+		}
+		p.markDisconnected(err)
+		p.enqueue(channel, data)
 	}
 
 	return nil
 }
 
-// Close fecha a conexão com o Redis
+// deferPublish enfileira a publicação se o Redis está fora ou se o buffer
+// ainda tem mensagens pendentes. Retorna false se ela pode ser publicada direto
+func (p *PubSubAdapter) deferPublish(channel string, data []byte) bool {
+	p.outboxMu.Lock()
+	defer p.outboxMu.Unlock()
+
+	if p.IsConnected() && !p.flushing && len(p.outbox) == 0 {
+		return false
+	}
+
+	p.enqueueLocked(channel, data)
+	return true
+}
+
+// enqueue guarda uma publicação no buffer, descartando a mais antiga se cheio
+func (p *PubSubAdapter) enqueue(channel string, data []byte) {
+	p.outboxMu.Lock()
+	defer p.outboxMu.Unlock()
+
+	p.enqueueLocked(channel, data)
+}
+
+// enqueueLocked é enqueue com outboxMu já travado
+func (p *PubSubAdapter) enqueueLocked(channel string, data []byte) {
+	if len(p.outbox) >= p.outboxSize {
+		p.outbox = p.outbox[1:]
+		p.dropped++
+		if p.dropped%100 == 1 {
+			log.Printf("[Redis Pub/Sub] Buffer cheio, %d mensagens descartadas até agora", p.dropped)
+		}
+	}
+
+	p.outbox = append(p.outbox, pendingPublish{channel: channel, data: data})
+}
+
+// flushOutbox publica as mensagens acumuladas no buffer
+// Publicações feitas durante o envio entram no fim do buffer e saem na mesma
+// passagem, depois das antigas. Em caso de nova falha, as restantes voltam
+// para o início do buffer
+func (p *PubSubAdapter) flushOutbox() {
+	published := 0
+
+	for {
+		p.outboxMu.Lock()
+		pending := p.outbox
+		p.outbox = make([]pendingPublish, 0)
+		p.flushing = len(pending) > 0
+		p.outboxMu.Unlock()
+
+		if len(pending) == 0 {
+			break
+		}
+
+		for i, item := range pending {
+			if err := p.client.Publish(p.ctx, item.channel, item.data).Err(); err != nil {
+				p.markDisconnected(err)
+
+				p.outboxMu.Lock()
+				p.outbox = append(pending[i:], p.outbox...)
+				if len(p.outbox) > p.outboxSize {
+					p.dropped += int64(len(p.outbox) - p.outboxSize)
+					p.outbox = p.outbox[len(p.outbox)-p.outboxSize:]
+				}
+				p.flushing = false
+				p.outboxMu.Unlock()
+				return
+			}
+		}
+		published += len(pending)
+	}
+
+	if published > 0 {
+		log.Printf("[Redis Pub/Sub] %d mensagens do buffer publicadas após reconexão", published)
+	}
+}
+
+// monitorConnection verifica periodicamente a conexão com o Redis
+// Na volta da conexão, refaz as inscrições e esvazia o buffer
+func (p *PubSubAdapter) monitorConnection() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(p.ctx, healthCheckInterval)
+			err := p.client.Ping(ctx).Err()
+			cancel()
+
+			if err != nil {
+				if p.ctx.Err() == nil {
+					p.markDisconnected(err)
+				}
+				continue
+			}
+
+			if !p.IsConnected() {
+				if err := p.resubscribe(); err != nil {
+					log.Printf("[Redis Pub/Sub] Erro ao refazer inscrições: %v", err)
+					continue
+				}
+				p.connected.Store(true)
+				log.Println("[Redis Pub/Sub] Conexão com o Redis restabelecida")
+			}
+
+			p.flushOutbox()
+		}
+	}
+}
+
+// markDisconnected registra a queda da conexão
+func (p *PubSubAdapter) markDisconnected(err error) {
+	if p.connected.CompareAndSwap(true, false) {
+		log.Printf("[Redis Pub/Sub] Conexão com o Redis perdida, operando em modo degradado: %v", err)
+	}
+}
+
+// resubscribe recria a conexão Pub/Sub com todos os canais ativos
+func (p *PubSubAdapter) resubscribe() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	channels := make([]string, 0, len(p.handlers))
+	for channel := range p.handlers {
+		channels = append(channels, channel)
+	}
+
+	old := p.pubsub
+	p.pubsub = nil
+
+	if len(channels) > 0 {
+		pubsub := p.client.Subscribe(p.ctx, channels...)
+		if _, err := pubsub.Receive(p.ctx); err != nil {
+			pubsub.Close()
+			p.pubsub = old
+			return err
+		}
+
+		p.pubsub = pubsub
+		go p.consumeMessages(pubsub.Channel())
+	}
+
+	// Fechar a conexão antiga encerra o consumidor anterior
+	if old != nil {
+		old.Close()
+	}
+
+	log.Printf("[Redis Pub/Sub] Inscrições refeitas em %d canais", len(channels))
+	return nil
+}
+
+// IsConnected indica se a conexão com o Redis está ativa
+func (p *PubSubAdapter) IsConnected() bool {
+	return p.connected.Load()
+}
+
+// State retorna o estado atual da conexão
+func (p *PubSubAdapter) State() ConnectionState {
+	if p.IsConnected() {
+		return StateConnected
+	}
+	return StateDisconnected
+}
+
+// BufferedCount retorna o número de publicações aguardando no buffer
+func (p *PubSubAdapter) BufferedCount() int {
+	p.outboxMu.Lock()
+	defer p.outboxMu.Unlock()
+	return len(p.outbox)
+}
+
+// Close encerra as inscrições
+// O cliente compartilhado é fechado por quem o criou
 func (p *PubSubAdapter) Close() error {
 	p.cancel()
 
+	p.mu.Lock()
 	if p.pubsub != nil {
 		if err := p.pubsub.Close(); err != nil {
 			log.Printf("[Redis Pub/Sub] Erro ao fechar pubsub: %v", err)
 		}
 	}
+	p.mu.Unlock()
 
-	if err := p.client.Close(); err != nil {
-		return fmt.Errorf("erro ao fechar cliente Redis: %w", err)
+	if pending := p.BufferedCount(); pending > 0 {
+		log.Printf("[Redis Pub/Sub] %d mensagens do buffer descartadas no encerramento", pending)
 	}
 
 	log.Println("[Redis Pub/Sub] Conexão fechada")
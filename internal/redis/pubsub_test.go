@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newOfflineAdapter cria um adaptador apontando para um Redis inexistente,
+// sem o monitor de conexão
+func newOfflineAdapter(t *testing.T, connected bool) *PubSubAdapter {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	adapter := &PubSubAdapter{
+		client:     client,
+		instanceID: "test",
+		ctx:        ctx,
+		cancel:     cancel,
+		handlers:   make(map[string]MessageHandler),
+		outbox:     make([]pendingPublish, 0),
+		outboxSize: DefaultOutboxSize,
+	}
+	adapter.connected.Store(connected)
+	return adapter
+}
+
+// outboxChannels retorna os canais do buffer, na ordem
+func outboxChannels(p *PubSubAdapter) []string {
+	p.outboxMu.Lock()
+	defer p.outboxMu.Unlock()
+
+	channels := make([]string, 0, len(p.outbox))
+	for _, item := range p.outbox {
+		channels = append(channels, item.channel)
+	}
+	return channels
+}
+
+func TestDeferPublish(t *testing.T) {
+	tests := []struct {
+		name      string
+		connected bool
+		flushing  bool
+		backlog   []string
+		want      bool
+	}{
+		{"conectado e buffer vazio", true, false, nil, false},
+		{"desconectado", false, false, nil, true},
+		{"conectado com buffer pendente", true, false, []string{"a"}, true},
+		{"conectado durante o envio do buffer", true, true, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newOfflineAdapter(t, tt.connected)
+			p.flushing = tt.flushing
+			for _, channel := range tt.backlog {
+				p.enqueue(channel, nil)
+			}
+
+			if got := p.deferPublish("novo", nil); got != tt.want {
+				t.Fatalf("deferPublish = %v, want %v", got, tt.want)
+			}
+
+			want := append([]string{}, tt.backlog...)
+			if tt.want {
+				want = append(want, "novo")
+			}
+			if got := outboxChannels(p); !reflect.DeepEqual(got, want) {
+				t.Errorf("buffer = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFlushOutboxKeepsOrderOnFailure(t *testing.T) {
+	p := newOfflineAdapter(t, true)
+	for _, channel := range []string{"a", "b", "c"} {
+		p.enqueue(channel, nil)
+	}
+
+	p.flushOutbox()
+
+	if p.IsConnected() {
+		t.Error("falha ao publicar não marcou a conexão como perdida")
+	}
+	if got := outboxChannels(p); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("buffer = %v, want [a b c]", got)
+	}
+	if p.flushing {
+		t.Error("flushing continua ativo após a falha")
+	}
+
+	// Publicações seguintes vão para o fim do buffer
+	if err := p.Publish("d", []byte(`{}`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got := outboxChannels(p); !reflect.DeepEqual(got, []string{"a", "b", "c", ChannelPrefix + "d"}) {
+		t.Errorf("buffer = %v, want [a b c %sd]", got, ChannelPrefix)
+	}
+}
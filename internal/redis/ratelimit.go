@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefixo dos buckets de limite de taxa (gosocket:ratelimit:{escopo}:{evento}:{id})
+const RateLimitKeyPrefix = "gosocket:ratelimit:"
+
+// tokenBucketScript consome um token do bucket e devolve {permitido, espera em ms}
+// O relógio é o do Redis, então todas as instâncias enxergam o mesmo reabastecimento
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, retry}
+`)
+
+// RateLimiter aplica limites de taxa (token bucket) compartilhados entre instâncias
+type RateLimiter struct {
+	client redis.UniversalClient
+	ctx    context.Context
+}
+
+// NewRateLimiter cria um limitador de taxa no Redis
+func NewRateLimiter(client redis.UniversalClient) *RateLimiter {
+	return &RateLimiter{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
+// Allow consome um token do bucket key (rate tokens/s, até burst acumulados)
+// Sem token disponível, retorna quanto tempo esperar pelo próximo
+func (rl *RateLimiter) Allow(key string, rate float64, burst int) (bool, time.Duration, error) {
+	result, err := tokenBucketScript.Run(rl.ctx, rl.client, []string{RateLimitKeyPrefix + key}, rate, burst).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("erro ao consultar limite de taxa: %w", err)
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("resposta inesperada do limite de taxa: %v", result)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,225 @@
+package redis
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// recoverOwnPending reprocessa as entradas pendentes deste consumer
+// Executado no início para que um worker reiniciado termine o que começou.
+// As entradas são reivindicadas com XCLAIM, que conta uma nova entrega: uma
+// entrada que derruba o worker esgota MaxDeliveries e vai para a DLQ
+func (sc *StreamConsumer) recoverOwnPending() {
+	recovered := 0
+	start := "-"
+
+	for sc.ctx.Err() == nil {
+		pending, err := sc.client.XPendingExt(sc.ctx, &redis.XPendingExtArgs{
+			Stream:   MessagesStream,
+			Group:    PersistConsumerGroup,
+			Start:    start,
+			End:      "+",
+			Count:    sc.batchSize,
+			Consumer: sc.consumerID,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				log.Printf("[Redis Consumer] Erro ao ler pendências próprias: %v", err)
+			}
+			break
+		}
+		if len(pending) == 0 {
+			break
+		}
+		// O cursor avança mesmo em falhas; o que falhar volta pelo XAUTOCLAIM
+		start = "(" + pending[len(pending)-1].ID
+
+		ids := make([]string, 0, len(pending))
+		for _, entry := range pending {
+			ids = append(ids, entry.ID)
+		}
+
+		claimed, err := sc.client.XClaim(sc.ctx, &redis.XClaimArgs{
+			Stream:   MessagesStream,
+			Group:    PersistConsumerGroup,
+			Consumer: sc.consumerID,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			log.Printf("[Redis Consumer] Erro ao reivindicar pendências próprias: %v", err)
+			break
+		}
+
+		entries := sc.deadLetterExhausted(claimed)
+		if len(entries) == 0 {
+			continue
+		}
+
+		messages, messageIDs := sc.parseEntries(entries)
+		if len(messages) == 0 {
+			continue
+		}
+
+		// As que falharem ficam no PEL e serão reivindicadas pelo XAUTOCLAIM
+		sc.processBatch(messages, messageIDs)
+		recovered += len(messageIDs)
+
+		// Infraestrutura fora: o restante fica no PEL até o batch retido passar
+		if len(sc.stalled) > 0 {
+			break
+		}
+	}
+
+	if recovered > 0 {
+		log.Printf("[Redis Consumer] %d entradas pendentes do próprio consumer processadas", recovered)
+	}
+}
+
+// claimIdle reivindica entradas paradas há mais de claimMinIdle no PEL
+// de qualquer consumer do grupo (incluindo este)
+func (sc *StreamConsumer) claimIdle() ([]*StreamMessage, []string) {
+	claimed := make([]redis.XMessage, 0)
+	start := "0-0"
+
+	for sc.ctx.Err() == nil {
+		entries, next, err := sc.client.XAutoClaim(sc.ctx, &redis.XAutoClaimArgs{
+			Stream:   MessagesStream,
+			Group:    PersistConsumerGroup,
+			Consumer: sc.consumerID,
+			MinIdle:  sc.claimMinIdle,
+			Start:    start,
+			Count:    sc.batchSize,
+		}).Result()
+		if err != nil {
+			log.Printf("[Redis Consumer] Erro no XAUTOCLAIM: %v", err)
+			break
+		}
+
+		claimed = append(claimed, entries...)
+
+		// Limita a um batch por execução; o restante fica para a próxima
+		if next == "0-0" || int64(len(claimed)) >= sc.batchSize {
+			break
+		}
+		start = next
+	}
+
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	log.Printf("[Redis Consumer] %d entradas paradas reivindicadas", len(claimed))
+
+	return sc.parseEntries(sc.deadLetterExhausted(claimed))
+}
+
+// deadLetterExhausted move para a DLQ as entradas entregues mais de
+// maxDeliveries vezes segundo o contador do PEL, que sobrevive a reinícios e
+// acompanha a entrada entre workers. O erro anexado é o último visto por este
+// worker; sem registro local, a DLQ informa apenas o número de entregas
+func (sc *StreamConsumer) deadLetterExhausted(entries []redis.XMessage) []redis.XMessage {
+	if sc.maxDeliveries <= 0 || len(entries) == 0 {
+		return entries
+	}
+
+	counts := sc.deliveryCounts(entries)
+
+	kept := make([]redis.XMessage, 0, len(entries))
+	for _, entry := range entries {
+		deliveries := counts[entry.ID]
+		if deliveries <= sc.maxDeliveries {
+			kept = append(kept, entry)
+			continue
+		}
+
+		reason, ok := sc.failures[entry.ID]
+		if !ok {
+			reason = fmt.Sprintf("%d entregas sem ACK (worker reiniciado ou entrada de outro worker)", deliveries)
+		}
+		sc.deadLetter(entry, reason, deliveries)
+	}
+
+	return kept
+}
+
+// pruneFailures descarta os erros de entradas que não estão mais pendentes
+// neste consumer (confirmadas, movidas para a DLQ ou reivindicadas por outro worker)
+func (sc *StreamConsumer) pruneFailures() {
+	if len(sc.failures) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(sc.failures))
+	for id := range sc.failures {
+		ids = append(ids, id)
+	}
+
+	pending, err := sc.pendingEntries(ids)
+	if err != nil {
+		log.Printf("[Redis Consumer] Erro ao consultar pendências: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if entry, ok := pending[id]; !ok || entry.Consumer != sc.consumerID {
+			delete(sc.failures, id)
+		}
+	}
+}
+
+// deliveryCounts retorna o número de entregas de cada entrada (XPENDING)
+func (sc *StreamConsumer) deliveryCounts(entries []redis.XMessage) map[string]int64 {
+	counts := make(map[string]int64, len(entries))
+	if len(entries) == 0 {
+		return counts
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	pending, err := sc.pendingEntries(ids)
+	if err != nil {
+		log.Printf("[Redis Consumer] Erro ao consultar contagem de entregas: %v", err)
+		return counts
+	}
+
+	for id, entry := range pending {
+		counts[id] = entry.RetryCount
+	}
+	return counts
+}
+
+// pendingEntries consulta o PEL de cada ID individualmente (em pipeline)
+// IDs que não estão pendentes ficam fora do resultado
+func (sc *StreamConsumer) pendingEntries(ids []string) (map[string]redis.XPendingExt, error) {
+	pipe := sc.client.Pipeline()
+	cmds := make([]*redis.XPendingExtCmd, 0, len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipe.XPendingExt(sc.ctx, &redis.XPendingExtArgs{
+			Stream: MessagesStream,
+			Group:  PersistConsumerGroup,
+			Start:  id,
+			End:    id,
+			Count:  1,
+		}))
+	}
+	if _, err := pipe.Exec(sc.ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("erro no XPENDING: %w", err)
+	}
+
+	pending := make(map[string]redis.XPendingExt, len(ids))
+	for _, cmd := range cmds {
+		entries, err := cmd.Result()
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			pending[entry.ID] = entry
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,230 @@
+package redis
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Intervalo padrão entre execuções do trim periódico
+	DefaultTrimInterval = 30 * time.Second
+
+	// Máximo de entradas examinadas por execução para calcular o corte por tamanho
+	maxTrimScan = 10000
+)
+
+// RetentionConfig define a retenção do stream de persistência
+// Com MaxLen e MaxAge zerados o stream não é aparado
+type RetentionConfig struct {
+	// Número aproximado de entradas mantidas (0 = sem limite)
+	MaxLen int64
+
+	// Idade máxima aproximada das entradas (0 = sem limite)
+	MaxAge time.Duration
+
+	// Modo seguro: nunca remove entradas ainda não confirmadas (XACK) pelo
+	// PersistConsumerGroup. O trim passa a ser feito apenas periodicamente
+	SafeTrim bool
+
+	// Intervalo do trim periódico (padrão DefaultTrimInterval)
+	TrimInterval time.Duration
+}
+
+// Enabled indica se há alguma política de retenção configurada
+func (rc RetentionConfig) Enabled() bool {
+	return rc.MaxLen > 0 || rc.MaxAge > 0
+}
+
+// applyToXAdd aplica o trim aproximado no próprio XADD (modo não seguro)
+func (rc RetentionConfig) applyToXAdd(args *redis.XAddArgs) {
+	if rc.SafeTrim {
+		return
+	}
+
+	switch {
+	case rc.MaxLen > 0:
+		args.MaxLen = rc.MaxLen
+		args.Approx = true
+	case rc.MaxAge > 0:
+		args.MinID = minIDForAge(rc.MaxAge)
+		args.Approx = true
+	}
+}
+
+// needsTrimLoop indica se o trim periódico é necessário
+// No modo não seguro o XADD já apara por tamanho; a idade só precisa do
+// trim periódico quando os dois limites estão configurados
+func (rc RetentionConfig) needsTrimLoop() bool {
+	if rc.SafeTrim {
+		return rc.Enabled()
+	}
+	return rc.MaxLen > 0 && rc.MaxAge > 0
+}
+
+// minIDForAge retorna o menor ID de stream dentro da idade máxima
+func minIDForAge(maxAge time.Duration) string {
+	return fmt.Sprintf("%d-0", time.Now().Add(-maxAge).UnixMilli())
+}
+
+// trimLoop apara o stream periodicamente
+func (sp *StreamProducer) trimLoop() {
+	interval := sp.retention.TrimInterval
+	if interval <= 0 {
+		interval = DefaultTrimInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sp.ctx.Done():
+			return
+		case <-ticker.C:
+			trimmed, err := sp.Trim()
+			if err != nil {
+				log.Printf("[Redis Streams] Erro ao aparar stream: %v", err)
+			} else if trimmed > 0 {
+				log.Printf("[Redis Streams] %d entradas removidas do stream por retenção", trimmed)
+			}
+		}
+	}
+}
+
+// Trim aplica a política de retenção ao stream de persistência
+// Retorna o número de entradas removidas
+func (sp *StreamProducer) Trim() (int64, error) {
+	cutoff, err := sp.retentionCutoff()
+	if err != nil || cutoff == "" {
+		return 0, err
+	}
+
+	if sp.retention.SafeTrim {
+		safe, err := sp.safeTrimBound()
+		if err != nil {
+			return 0, err
+		}
+		if safe == "" {
+			return 0, nil
+		}
+		if compareStreamIDs(safe, cutoff) < 0 {
+			cutoff = safe
+		}
+	}
+
+	return sp.client.XTrimMinIDApprox(sp.ctx, MessagesStream, cutoff, 0).Result()
+}
+
+// retentionCutoff calcula o menor ID que deve permanecer no stream
+// Com os dois limites, vale o que remove mais entradas
+func (sp *StreamProducer) retentionCutoff() (string, error) {
+	cutoff := ""
+
+	if sp.retention.MaxAge > 0 {
+		cutoff = minIDForAge(sp.retention.MaxAge)
+	}
+
+	// No modo não seguro o tamanho já é controlado pelo XADD
+	if sp.retention.MaxLen > 0 && sp.retention.SafeTrim {
+		byLength, err := sp.lengthCutoff()
+		if err != nil {
+			return "", err
+		}
+		if byLength != "" && (cutoff == "" || compareStreamIDs(byLength, cutoff) > 0) {
+			cutoff = byLength
+		}
+	}
+
+	return cutoff, nil
+}
+
+// lengthCutoff retorna o ID da primeira entrada que cabe em MaxLen
+// Examina no máximo maxTrimScan entradas por execução; excessos maiores
+// são removidos ao longo das próximas execuções
+func (sp *StreamProducer) lengthCutoff() (string, error) {
+	length, err := sp.client.XLen(sp.ctx, MessagesStream).Result()
+	if err != nil {
+		return "", err
+	}
+
+	excess := length - sp.retention.MaxLen
+	if excess <= 0 {
+		return "", nil
+	}
+	if excess > maxTrimScan {
+		excess = maxTrimScan
+	}
+
+	// A entrada seguinte às excedentes é a primeira a permanecer
+	entries, err := sp.client.XRangeN(sp.ctx, MessagesStream, "-", "+", excess+1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	return entries[len(entries)-1].ID, nil
+}
+
+// safeTrimBound retorna o menor ID que ainda não foi confirmado pelo
+// PersistConsumerGroup: a entrada pendente mais antiga ou, sem pendências,
+// a última entregue. Retorna vazio se não for possível determinar
+func (sp *StreamProducer) safeTrimBound() (string, error) {
+	pending, err := sp.client.XPending(sp.ctx, MessagesStream, PersistConsumerGroup).Result()
+	if err != nil {
+		return "", fmt.Errorf("erro ao consultar pendências: %w", err)
+	}
+	if pending.Count > 0 {
+		return pending.Lower, nil
+	}
+
+	groups, err := sp.client.XInfoGroups(sp.ctx, MessagesStream).Result()
+	if err != nil {
+		return "", fmt.Errorf("erro ao consultar consumer groups: %w", err)
+	}
+	for _, group := range groups {
+		if group.Name == PersistConsumerGroup {
+			if group.LastDeliveredID == "0-0" {
+				return "", nil
+			}
+			return group.LastDeliveredID, nil
+		}
+	}
+
+	// Sem consumer group não há como saber o que foi persistido
+	return "", nil
+}
+
+// compareStreamIDs compara dois IDs de stream ("ms-seq")
+// Retorna -1, 0 ou 1
+func compareStreamIDs(a, b string) int {
+	aMs, aSeq := splitStreamID(a)
+	bMs, bSeq := splitStreamID(b)
+
+	switch {
+	case aMs < bMs:
+		return -1
+	case aMs > bMs:
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// splitStreamID separa as partes de um ID de stream
+func splitStreamID(id string) (uint64, uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msPart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
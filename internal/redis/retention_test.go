@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCompareStreamIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"iguais", "1700000000000-0", "1700000000000-0", 0},
+		{"ms menor", "1700000000000-5", "1700000000001-0", -1},
+		{"ms maior", "1700000000001-0", "1700000000000-9", 1},
+		{"seq menor", "1700000000000-1", "1700000000000-2", -1},
+		{"seq maior", "1700000000000-10", "1700000000000-9", 1},
+		{"sem seq equivale a seq 0", "1700000000000", "1700000000000-0", 0},
+		{"comparação numérica, não lexicográfica", "999-0", "1000-0", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareStreamIDs(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareStreamIDs(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     RetentionConfig
+		enabled    bool
+		trimLoop   bool
+		wantMaxLen int64
+		wantMinID  bool
+	}{
+		{"sem retenção", RetentionConfig{}, false, false, 0, false},
+		{"tamanho no XADD", RetentionConfig{MaxLen: 1000}, true, false, 1000, false},
+		{"idade no XADD", RetentionConfig{MaxAge: time.Hour}, true, false, 0, true},
+		{"tamanho e idade", RetentionConfig{MaxLen: 1000, MaxAge: time.Hour}, true, true, 1000, false},
+		{"modo seguro só apara no loop", RetentionConfig{MaxLen: 1000, SafeTrim: true}, true, true, 0, false},
+		{"modo seguro sem limites", RetentionConfig{SafeTrim: true}, false, false, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Enabled(); got != tt.enabled {
+				t.Errorf("Enabled() = %v, want %v", got, tt.enabled)
+			}
+			if got := tt.config.needsTrimLoop(); got != tt.trimLoop {
+				t.Errorf("needsTrimLoop() = %v, want %v", got, tt.trimLoop)
+			}
+
+			args := &redis.XAddArgs{}
+			tt.config.applyToXAdd(args)
+			if args.MaxLen != tt.wantMaxLen {
+				t.Errorf("MaxLen = %d, want %d", args.MaxLen, tt.wantMaxLen)
+			}
+			if (args.MinID != "") != tt.wantMinID {
+				t.Errorf("MinID = %q, want set: %v", args.MinID, tt.wantMinID)
+			}
+			if (args.MaxLen > 0 || args.MinID != "") && !args.Approx {
+				t.Errorf("trim no XADD deve ser aproximado")
+			}
+		})
+	}
+}
+
+func TestMinIDForAge(t *testing.T) {
+	before := time.Now().Add(-time.Hour).UnixMilli()
+	id := minIDForAge(time.Hour)
+	after := time.Now().Add(-time.Hour).UnixMilli()
+
+	msPart, seq, ok := strings.Cut(id, "-")
+	if !ok || seq != "0" {
+		t.Fatalf("minIDForAge = %q, want formato ms-0", id)
+	}
+	ms, err := strconv.ParseInt(msPart, 10, 64)
+	if err != nil {
+		t.Fatalf("minIDForAge = %q: %v", id, err)
+	}
+	if ms < before || ms > after {
+		t.Errorf("minIDForAge = %d, want entre %d e %d", ms, before, after)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -15,38 +16,79 @@ const (
 
 	// Consumer group para workers
 	PersistConsumerGroup = "persist-workers"
+
+	// Tipos de entrada do stream de persistência
+	KindMessage  = "message"
+	KindRevision = "revision"
+	KindDeletion = "deletion" // Exclusão lógica (tombstone)
+	KindPurge    = "purge"    // Exclusão definitiva
 )
 
 // StreamMessage representa uma mensagem a ser persistida
 type StreamMessage struct {
-	RoomName string                 `json:"room_name"`
-	UserID   string                 `json:"user_id,omitempty"`
-	Username string                 `json:"username,omitempty"`
-	Payload  map[string]interface{} `json:"payload"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Kind       string                 `json:"kind,omitempty"`        // KindMessage (padrão), KindRevision, KindDeletion ou KindPurge
+	MessageID  string                 `json:"message_id,omitempty"`  // ID da mensagem visto pelos clientes
+	CreatedAt  time.Time              `json:"created_at,omitempty"`  // Criação no servidor de origem
+	InstanceID string                 `json:"instance_id,omitempty"` // Instância que recebeu a mensagem
+	RoomName   string                 `json:"room_name"`
+	UserID     string                 `json:"user_id,omitempty"`
+	Username   string                 `json:"username,omitempty"`
+	Payload    map[string]interface{} `json:"payload"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+
+	// Revisões: ID da revisão e payload anterior à edição
+	// (UserID/Username identificam quem editou e CreatedAt o momento da edição)
+	RevisionID      string                 `json:"revision_id,omitempty"`
+	PreviousPayload map[string]interface{} `json:"previous_payload,omitempty"`
+
+	// Exclusões usam UserID/Username para quem apagou e CreatedAt para o momento
+	// Leituras do PostgreSQL preenchem EditedAt/DeletedAt em mensagens alteradas
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// ID da entrada de origem no stream (preenchido pelo consumer)
+	// Entradas devolvidas da DLQ mantêm o ID original
+	StreamID string `json:"-"`
+}
+
+// DedupKey retorna a chave usada para ignorar mensagens já persistidas
+// Entradas antigas, sem ID de mensagem, usam o ID da entrada no stream
+func (m *StreamMessage) DedupKey() string {
+	if m.IsRevision() && m.RevisionID != "" {
+		return m.RevisionID
+	}
+	if m.MessageID != "" {
+		return m.MessageID
+	}
+	return m.StreamID
+}
+
+// IsRevision indica se a entrada é uma revisão de mensagem
+func (m *StreamMessage) IsRevision() bool {
+	return m.Kind == KindRevision
 }
 
 // StreamProducer publica mensagens no Redis Stream
 type StreamProducer struct {
-	client *redis.Client
-	ctx    context.Context
+	client     redis.UniversalClient
+	instanceID string
+	ctx        context.Context
+	cancel     context.CancelFunc
+	retention  RetentionConfig
 }
 
-// NewStreamProducer cria um novo produtor de streams
-func NewStreamProducer(redisURL string) (*StreamProducer, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisURL,
-	})
-
-	// Testa a conexão
-	ctx := context.Background()
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("falha ao conectar no Redis: %w", err)
-	}
+// NewStreamProducer cria um novo produtor de streams sobre o cliente compartilhado
+// instanceID é gravado como origem das mensagens; retention define o trim
+// aproximado do stream por tamanho e/ou idade
+func NewStreamProducer(client redis.UniversalClient, instanceID string, retention RetentionConfig) (*StreamProducer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
 
 	producer := &StreamProducer{
-		client: client,
-		ctx:    ctx,
+		client:     client,
+		instanceID: instanceID,
+		ctx:        ctx,
+		cancel:     cancel,
+		retention:  retention,
 	}
 
 	// Garante que o consumer group existe
@@ -54,7 +96,12 @@ func NewStreamProducer(redisURL string) (*StreamProducer, error) {
 		log.Printf("[Redis Streams] Aviso ao criar consumer group: %v", err)
 	}
 
-	log.Printf("[Redis Streams] Producer conectado ao Redis em %s", redisURL)
+	if retention.needsTrimLoop() {
+		go producer.trimLoop()
+	}
+
+	log.Printf("[Redis Streams] Producer iniciado (retenção: maxLen=%d, maxAge=%s, modo seguro=%v)",
+		retention.MaxLen, retention.MaxAge, retention.SafeTrim)
 
 	return producer, nil
 }
@@ -84,33 +131,58 @@ func (sp *StreamProducer) Publish(msg *StreamMessage) error {
 		return fmt.Errorf("erro ao serializar metadata: %w", err)
 	}
 
+	if msg.Kind == "" {
+		msg.Kind = KindMessage
+	}
+	if msg.InstanceID == "" {
+		msg.InstanceID = sp.instanceID
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+
 	// Adiciona ao stream
 	values := map[string]interface{}{
-		"room_name": msg.RoomName,
-		"user_id":   msg.UserID,
-		"username":  msg.Username,
-		"payload":   string(payloadJSON),
-		"metadata":  string(metadataJSON),
+		"kind":        msg.Kind,
+		"message_id":  msg.MessageID,
+		"created_at":  msg.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"instance_id": msg.InstanceID,
+		"room_name":   msg.RoomName,
+		"user_id":     msg.UserID,
+		"username":    msg.Username,
+		"payload":     string(payloadJSON),
+		"metadata":    string(metadataJSON),
 	}
 
-	id, err := sp.client.XAdd(sp.ctx, &redis.XAddArgs{
+	if msg.IsRevision() {
+		previousJSON, err := json.Marshal(msg.PreviousPayload)
+		if err != nil {
+			return fmt.Errorf("erro ao serializar payload anterior: %w", err)
+		}
+		values["revision_id"] = msg.RevisionID
+		values["previous_payload"] = string(previousJSON)
+	}
+
+	args := &redis.XAddArgs{
 		Stream: MessagesStream,
 		Values: values,
-	}).Result()
+	}
+	sp.retention.applyToXAdd(args)
+
+	id, err := sp.client.XAdd(sp.ctx, args).Result()
 
 	if err != nil {
 		return fmt.Errorf("erro ao adicionar ao stream: %w", err)
 	}
 
-	log.Printf("[Redis Streams] Mensagem adicionada ao stream: %s (room: %s)", id, msg.RoomName)
+	log.Printf("[Redis Streams] Mensagem adicionada ao stream: %s (room: %s, id: %s)", id, msg.RoomName, msg.MessageID)
 	return nil
 }
 
-// Close fecha a conexão com o Redis
+// Close finaliza o producer
+// O cliente compartilhado é fechado por quem o criou
 func (sp *StreamProducer) Close() error {
-	if err := sp.client.Close(); err != nil {
-		return fmt.Errorf("erro ao fechar cliente Redis: %w", err)
-	}
+	sp.cancel()
 	log.Println("[Redis Streams] Producer fechado")
 	return nil
 }
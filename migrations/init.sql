@@ -7,15 +7,42 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 -- Tabela de mensagens persistidas
 CREATE TABLE IF NOT EXISTS messages (
     id BIGSERIAL PRIMARY KEY,
+    message_id VARCHAR(255) NOT NULL,
+    instance_id VARCHAR(255),
     room_name VARCHAR(255) NOT NULL,
     user_id VARCHAR(255),
     username VARCHAR(255),
     payload JSONB NOT NULL,
     metadata JSONB,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    edited_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    deleted_by VARCHAR(255),
+    search_language REGCONFIG NOT NULL DEFAULT 'simple',
+    placeholder BOOLEAN NOT NULL DEFAULT FALSE,
     processed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Bancos criados antes da chave de deduplicação: adiciona a coluna e
+-- preenche as linhas antigas com uma chave derivada do id
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS message_id VARCHAR(255);
+UPDATE messages SET message_id = 'legacy-' || id WHERE message_id IS NULL;
+ALTER TABLE messages ALTER COLUMN message_id SET NOT NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS instance_id VARCHAR(255);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_language REGCONFIG NOT NULL DEFAULT 'simple';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS placeholder BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Busca textual sobre o texto da mensagem (payload.message), recalculada em edições
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector(search_language, COALESCE(payload->>'message', ''))) STORED;
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
+
+-- Garante que reprocessar o stream não duplica mensagens
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_message_id ON messages(message_id);
+
 -- Índices para otimizar queries
 CREATE INDEX IF NOT EXISTS idx_messages_room_name ON messages(room_name);
 CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC);
@@ -25,6 +52,28 @@ CREATE INDEX IF NOT EXISTS idx_messages_room_created ON messages(room_name, crea
 -- Índice GIN para busca no payload JSON
 CREATE INDEX IF NOT EXISTS idx_messages_payload ON messages USING GIN (payload);
 
+-- Histórico de edições (cada edição gera uma revisão)
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    revision_id VARCHAR(255) NOT NULL UNIQUE,
+    message_id VARCHAR(255) NOT NULL,
+    room_name VARCHAR(255) NOT NULL,
+    editor_id VARCHAR(255),
+    editor_name VARCHAR(255),
+    payload JSONB NOT NULL,
+    previous_payload JSONB,
+    edited_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_revisions_message ON message_revisions(message_id, edited_at);
+
+-- Mensagens apagadas definitivamente (purge): impede que uma entrada
+-- atrasada do stream grave a mensagem de novo
+CREATE TABLE IF NOT EXISTS purged_messages (
+    message_id VARCHAR(255) PRIMARY KEY,
+    purged_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
 -- Tabela de métricas de rooms (opcional, para analytics)
 CREATE TABLE IF NOT EXISTS room_stats (
     room_name VARCHAR(255) PRIMARY KEY,
@@ -44,16 +93,27 @@ BEGIN
     ON CONFLICT (room_name)
     DO UPDATE SET
         total_messages = room_stats.total_messages + 1,
-        last_activity = NEW.created_at,
+        last_activity = GREATEST(room_stats.last_activity, NEW.created_at),
         updated_at = NOW();
     RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
 
--- Trigger para atualizar stats
+-- Triggers para atualizar stats
+-- Placeholders (exclusão gravada antes da mensagem) não contam; a mensagem
+-- conta quando o SaveBatch preenche o placeholder, com o created_at real
+DROP TRIGGER IF EXISTS trigger_update_room_stats ON messages;
 CREATE TRIGGER trigger_update_room_stats
 AFTER INSERT ON messages
 FOR EACH ROW
+WHEN (NOT NEW.placeholder)
+EXECUTE FUNCTION update_room_stats();
+
+DROP TRIGGER IF EXISTS trigger_update_room_stats_filled ON messages;
+CREATE TRIGGER trigger_update_room_stats_filled
+AFTER UPDATE OF placeholder ON messages
+FOR EACH ROW
+WHEN (OLD.placeholder AND NOT NEW.placeholder)
 EXECUTE FUNCTION update_room_stats();
 
 -- View para facilitar queries de histórico
@@ -69,12 +129,21 @@ SELECT
     rs.total_messages as room_total_messages
 FROM messages m
 LEFT JOIN room_stats rs ON m.room_name = rs.room_name
+WHERE NOT m.placeholder
 ORDER BY m.created_at DESC
 LIMIT 1000;
 
 -- Comentários para documentação
 COMMENT ON TABLE messages IS 'Armazena todas as mensagens enviadas através do sistema pub/sub';
+COMMENT ON TABLE message_revisions IS 'Revisões de mensagens editadas (conteúdo antes e depois de cada edição)';
+COMMENT ON TABLE purged_messages IS 'IDs de mensagens apagadas definitivamente (purge)';
 COMMENT ON TABLE room_stats IS 'Estatísticas agregadas por sala para analytics';
+COMMENT ON COLUMN messages.message_id IS 'ID da mensagem usado pelos clientes (messageId); entradas antigas usam o ID do Redis Stream';
+COMMENT ON COLUMN messages.instance_id IS 'Instância do servidor que recebeu a mensagem';
+COMMENT ON COLUMN messages.deleted_at IS 'Exclusão lógica (tombstone); o conteúdo só é removido por purge';
+COMMENT ON COLUMN messages.placeholder IS 'Tombstone gravado antes da mensagem chegar do stream; preenchido quando ela chega';
+COMMENT ON COLUMN messages.search_language IS 'Configuração de idioma usada para indexar a mensagem (SEARCH_LANGUAGE)';
+COMMENT ON COLUMN messages.created_at IS 'Criação da mensagem no servidor de origem';
 COMMENT ON COLUMN messages.payload IS 'Conteúdo da mensagem em formato JSON';
 COMMENT ON COLUMN messages.metadata IS 'Metadados adicionais (timestamp, sala info, etc)';
 